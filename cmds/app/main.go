@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"go-rengan/dep"
 	config "go-rengan/pkg/config"
 	logger "go-rengan/pkg/logger"
@@ -13,48 +14,71 @@ import (
 )
 
 func main() {
-	logger := logger.New()
+	os.Exit(run())
+}
 
-	// Config
-	config.New()
+// run - run the server until SIGINT or SIGTERM, the exit code is 1 when it fails to start, run or stop
+func run() int {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "optional yaml config file, the environment overrides it")
+	broker := flag.String("broker", "", "message broker, amqp or memory, overrides AMQP_BROKER")
+	flag.Parse()
 
-	// Validator
-	validator.New()
+	ctx := context.Background()
 
-	// Server
-	server, err := dep.InitializeServer()
+	if *broker != "" {
+		os.Setenv("AMQP_BROKER", *broker)
+	}
+
+	// Config
+	cfg, err := config.New(*configFile)
 	if err != nil {
-		logger.Error(err)
+		logger.Default().Error(ctx, "invalid config", "error", err)
+		return 1
 	}
 
-	defer server.Tracing.ShutDown()
-	defer server.AMQP.Get().Close()
+	// Logger
+	log, err := logger.New(cfg.App, cfg.Log, cfg.OTLP)
+	if err != nil {
+		logger.Default().Error(ctx, "logger initialization failed", "error", err)
+		return 1
+	}
+	logger.SetDefault(log)
 	defer func() {
-		if err := server.MongoDB.Disconnect(); err != nil {
-			logger.Error(err)
+		// ship the last entries
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := log.Shutdown(ctx); err != nil {
+			logger.Default().Error(ctx, "logger shutdown failed", "error", err)
 		}
 	}()
 
-	go func() {
-		err := server.Run()
-		if err != nil {
-			logger.Error(err)
+	// Live settings are applied again when the config is reloaded
+	watcher := config.NewWatcher(*configFile, cfg, log)
+	watcher.Subscribe(func(cfg *config.Config) {
+		if err := log.SetLevel(cfg.Log.Level); err != nil {
+			log.Error(ctx, "log level change failed", "error", err)
 		}
-	}()
+	})
 
-	// catch shutdown
-	done := make(chan bool, 1)
-	go func() {
-		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-		<-sig
+	// Validator
+	validator.New()
 
-		// graceful shutdown
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		server.GracefulStop(ctx, done)
-	}()
+	// Server
+	server, err := dep.InitializeServer(cfg, watcher, log)
+	if err != nil {
+		log.Error(ctx, "server initialization failed", "error", err)
+		return 1
+	}
+
+	// The logger shutdown above needs a ctx which the signal does not cancel
+	runCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = server.Run(runCtx)
+	if err != nil {
+		log.Error(ctx, "server failed", "error", err)
+		return 1
+	}
 
-	// wait for graceful shutdown
-	<-done
+	return 0
 }
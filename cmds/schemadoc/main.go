@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	schema "go-rengan/pkg/schema"
+	"go-rengan/todo/events"
+	"os"
+)
+
+// Generate the markdown documentation of the message schemas
+func main() {
+	out := flag.String("out", "docs/events.md", "output file, - for stdout")
+	flag.Parse()
+
+	registry, err := schema.New(events.Schemas)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	docs := schema.Markdown(registry.Schemas())
+	if *out == "-" {
+		_, err = os.Stdout.Write(docs)
+	} else {
+		err = os.WriteFile(*out, docs, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
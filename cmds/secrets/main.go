@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	config "go-rengan/pkg/config"
+	"io"
+	"os"
+)
+
+// Generate the key of the secrets file, encrypt a .env file of secrets into it or decrypt it,
+// the key is read from SECRETS_KEY
+func main() {
+	in := flag.String("in", "-", "input file, - for stdin")
+	out := flag.String("out", "-", "output file, - for stdout")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: secrets [-in file] [-out file] keygen|encrypt|decrypt")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var output []byte
+	var err error
+	switch flag.Arg(0) {
+	case "keygen":
+		var key string
+		key, err = config.GenerateKey()
+		output = []byte(key + "\n")
+	case "encrypt":
+		output, err = run(*in, config.Encrypt)
+	case "decrypt":
+		output, err = run(*in, config.Decrypt)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *out == "-" {
+		_, err = os.Stdout.Write(output)
+	} else {
+		err = os.WriteFile(*out, output, 0600)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(in string, fn func(key string, data []byte) ([]byte, error)) ([]byte, error) {
+	key := os.Getenv(config.SecretsKeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("%s is required", config.SecretsKeyEnv)
+	}
+
+	var data []byte
+	var err error
+	if in == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(in)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return fn(key, data)
+}
@@ -5,14 +5,31 @@
 package dep
 
 import (
+	"io/fs"
+
 	amqp "go-rengan/pkg/amqp"
+	scheduler "go-rengan/pkg/amqp/scheduler"
+	apikey "go-rengan/pkg/apikey"
+	auth "go-rengan/pkg/auth"
+	broker "go-rengan/pkg/broker"
+	config "go-rengan/pkg/config"
+	dedup "go-rengan/pkg/dedup"
+	health "go-rengan/pkg/health"
+	idempotency "go-rengan/pkg/idempotency"
 	logger "go-rengan/pkg/logger"
+	mailer "go-rengan/pkg/mailer"
+	metrics "go-rengan/pkg/metrics"
 	mongodb "go-rengan/pkg/mongodb"
+	ratelimit "go-rengan/pkg/ratelimit"
+	schema "go-rengan/pkg/schema"
 	server "go-rengan/pkg/server"
 	httpserver "go-rengan/pkg/server/http"
 	tracing "go-rengan/pkg/tracing"
 	todoamqpdelivery "go-rengan/todo/delivery/amqp"
 	todohttpdelivery "go-rengan/todo/delivery/http"
+	events "go-rengan/todo/events"
+	notification "go-rengan/todo/notification"
+	policy "go-rengan/todo/policy"
 	todoamqpservice "go-rengan/todo/publisher"
 	repository "go-rengan/todo/repository"
 	service "go-rengan/todo/service"
@@ -20,17 +37,39 @@ import (
 	"github.com/google/wire"
 )
 
-func InitializeServer() (*server.ServerImpl, error) {
+func InitializeServer(cfg *config.Config, watcher config.Watcher, logger logger.Logger) (*server.ServerImpl, error) {
 	wire.Build(
+		wire.FieldsOf(new(*config.Config), "App", "Database", "Tracer", "Metrics", "Health", "Auth", "RateLimit", "Tenant", "Idempotency", "OTLP", "AMQP", "Mail", "Notification"),
 		amqp.New,
+		wire.InterfaceValue(new(fs.FS), events.Schemas),
+		schema.New,
+		wire.Bind(new(broker.Validator), new(schema.Registry)),
+		broker.New,
+		wire.Bind(new(broker.Publisher), new(broker.Broker)),
+		wire.Bind(new(broker.Subscriber), new(broker.Broker)),
 		tracing.New,
-		logger.New,
+		metrics.New,
+		health.New,
+		auth.New,
+		apikey.NewMongoStore,
+		apikey.New,
+		ratelimit.NewStore,
+		idempotency.NewMongoStore,
+		idempotency.New,
 		mongodb.New,
+		mongodb.NewIsolation,
+		dedup.New,
+		scheduler.NewMongoStore,
+		scheduler.New,
+		mailer.New,
+		notification.New,
 		repository.New,
+		policy.New,
 		service.New,
 		httpserver.New,
 		todohttpdelivery.New,
 		todoamqpdelivery.New,
+		todoamqpdelivery.NewRPCResponder,
 		todoamqpservice.New,
 		server.NewServer,
 	)
@@ -8,13 +8,28 @@ package dep
 
 import (
 	"go-rengan/pkg/amqp"
+	"go-rengan/pkg/amqp/scheduler"
+	"go-rengan/pkg/apikey"
+	"go-rengan/pkg/auth"
+	"go-rengan/pkg/broker"
+	"go-rengan/pkg/config"
+	"go-rengan/pkg/dedup"
+	"go-rengan/pkg/health"
+	"go-rengan/pkg/idempotency"
 	"go-rengan/pkg/logger"
+	"go-rengan/pkg/mailer"
+	"go-rengan/pkg/metrics"
 	"go-rengan/pkg/mongodb"
+	"go-rengan/pkg/ratelimit"
+	"go-rengan/pkg/schema"
 	"go-rengan/pkg/server"
 	"go-rengan/pkg/server/http"
 	"go-rengan/pkg/tracing"
 	"go-rengan/todo/delivery/amqp"
 	"go-rengan/todo/delivery/http"
+	"go-rengan/todo/events"
+	"go-rengan/todo/notification"
+	"go-rengan/todo/policy"
 	"go-rengan/todo/publisher"
 	"go-rengan/todo/repository"
 	"go-rengan/todo/service"
@@ -22,26 +37,94 @@ import (
 
 // Injectors from wire.go:
 
-func InitializeServer() (*server.ServerImpl, error) {
-	tracingTracing, err := tracing.New()
+func InitializeServer(cfg *config.Config, watcher config.Watcher, logger2 logger.Logger) (*server.ServerImpl, error) {
+	appConfig := cfg.App
+	healthConfig := cfg.Health
+	healthHealth := health.New(healthConfig)
+	tracerConfig := cfg.Tracer
+	otlpConfig := cfg.OTLP
+	tracingTracing, err := tracing.New(appConfig, tracerConfig, otlpConfig)
 	if err != nil {
 		return nil, err
 	}
-	loggerLogger := logger.New()
-	amqpAMQP, err := amqp.New()
+	metricsConfig := cfg.Metrics
+	metricsMetrics, err := metrics.New(appConfig, metricsConfig, otlpConfig)
 	if err != nil {
 		return nil, err
 	}
-	amqpConsumer := amqpdelivery.New(loggerLogger, tracingTracing, amqpAMQP)
-	mongoDB, err := mongodb.New(loggerLogger)
+	amqpConfig := cfg.AMQP
+	amqpAMQP, err := amqp.New(amqpConfig)
 	if err != nil {
 		return nil, err
 	}
-	repositoryRepository := repository.New(mongoDB)
-	amqpPublisher := amqppublisher.New(loggerLogger, tracingTracing, amqpAMQP)
-	serviceService := service.New(tracingTracing, repositoryRepository, amqpPublisher)
-	httpHandler := httpdelivery.New(tracingTracing, serviceService)
-	httpServer := httpserver.New(loggerLogger, httpHandler)
-	serverImpl := server.NewServer(tracingTracing, loggerLogger, amqpAMQP, amqpConsumer, mongoDB, httpServer)
+	fs := _wireFSValue
+	registry, err := schema.New(fs)
+	if err != nil {
+		return nil, err
+	}
+	brokerBroker, err := broker.New(amqpConfig, logger2, metricsMetrics, amqpAMQP, registry)
+	if err != nil {
+		return nil, err
+	}
+	notificationConfig := cfg.Notification
+	databaseConfig := cfg.Database
+	mongoDB, err := mongodb.New(databaseConfig, logger2, metricsMetrics)
+	if err != nil {
+		return nil, err
+	}
+	deduplicator, err := dedup.New(amqpConfig, mongoDB)
+	if err != nil {
+		return nil, err
+	}
+	mailConfig := cfg.Mail
+	mailerMailer := mailer.New(mailConfig)
+	notificationNotification, err := notification.New(appConfig, tracingTracing, mailerMailer)
+	if err != nil {
+		return nil, err
+	}
+	amqpConsumer := amqpdelivery.New(notificationConfig, watcher, logger2, tracingTracing, brokerBroker, deduplicator, notificationNotification)
+	policyPolicy := policy.New()
+	tenantConfig := cfg.Tenant
+	isolation := mongodb.NewIsolation(tenantConfig, mongoDB)
+	repositoryRepository, err := repository.New(isolation)
+	if err != nil {
+		return nil, err
+	}
+	amqpPublisher := amqppublisher.New(appConfig, logger2, tracingTracing, brokerBroker)
+	serviceService := service.New(tracingTracing, metricsMetrics, policyPolicy, repositoryRepository, amqpPublisher)
+	rpcResponder := amqpdelivery.NewRPCResponder(logger2, tracingTracing, amqpAMQP, serviceService)
+	store, err := scheduler.NewMongoStore(mongoDB)
+	if err != nil {
+		return nil, err
+	}
+	schedulerScheduler := scheduler.New(amqpConfig, logger2, tracingTracing, amqpAMQP, store)
+	authConfig := cfg.Auth
+	authenticator, err := auth.New(authConfig)
+	if err != nil {
+		return nil, err
+	}
+	apikeyStore, err := apikey.NewMongoStore(mongoDB)
+	if err != nil {
+		return nil, err
+	}
+	keys := apikey.New(logger2, apikeyStore)
+	rateLimitConfig := cfg.RateLimit
+	ratelimitStore, err := ratelimit.NewStore(rateLimitConfig, mongoDB)
+	if err != nil {
+		return nil, err
+	}
+	idempotencyConfig := cfg.Idempotency
+	idempotencyStore, err := idempotency.NewMongoStore(mongoDB)
+	if err != nil {
+		return nil, err
+	}
+	idempotencyIdempotency := idempotency.New(idempotencyConfig, logger2, idempotencyStore)
+	httpHandler := httpdelivery.New(tracingTracing, serviceService, idempotencyIdempotency)
+	httpServer := httpserver.New(appConfig, watcher, logger2, metricsMetrics, healthHealth, authenticator, keys, ratelimitStore, httpHandler)
+	serverImpl := server.NewServer(appConfig, healthConfig, healthHealth, tracingTracing, metricsMetrics, logger2, amqpAMQP, brokerBroker, amqpConsumer, rpcResponder, schedulerScheduler, watcher, mongoDB, httpServer)
 	return serverImpl, nil
 }
+
+var (
+	_wireFSValue = events.Schemas
+)
@@ -1,21 +1,66 @@
 package amqp
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+
+	"go-rengan/pkg/amqp/memory"
+	config "go-rengan/pkg/config"
 
 	"github.com/streadway/amqp"
 )
 
+// Broker - message broker backend of the amqp channel
+type Broker string
+
+const (
+	// BrokerAMQP - RabbitMQ at AMQP_URL
+	BrokerAMQP Broker = "amqp"
+	// BrokerMemory - in-process broker, for tests and local development
+	BrokerMemory Broker = "memory"
+)
+
+// Channel - amqp channel operations used by the publishers and consumers, implemented by *amqp.Channel
+type Channel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	Close() error
+}
+
+// ErrClosed - the connection to the broker is closed
+var ErrClosed = errors.New("amqp connection is closed")
+
 type AMQP interface {
-	Get() *amqp.Channel
+	Get() Channel
+	// Ping checks the connection to the broker is open
+	Ping(ctx context.Context) error
+	// Close closes the channel and the connection
+	Close() error
 }
 
 type AMQPImpl struct {
-	channel *amqp.Channel
+	connection *amqp.Connection
+	channel    Channel
 }
 
-func New() (AMQP, error) {
-	connection, err := amqp.Dial(os.Getenv("AMQP_URL"))
+// New - make amqp channel of the configured broker
+func New(cfg config.AMQPConfig) (AMQP, error) {
+	broker := Broker(cfg.Broker)
+	switch broker {
+	case BrokerMemory:
+		return NewWithChannel(memory.New()), nil
+	case BrokerAMQP, "":
+	default:
+		return nil, fmt.Errorf("unknown broker %q", broker)
+	}
+
+	connection, err := amqp.Dial(cfg.URL.Value())
 	if err != nil {
 		return nil, err
 	}
@@ -26,10 +71,38 @@ func New() (AMQP, error) {
 	}
 
 	return &AMQPImpl{
-		channel: channel,
+		connection: connection,
+		channel:    channel,
 	}, err
 }
 
-func (a *AMQPImpl) Get() *amqp.Channel {
+// NewWithChannel - make amqp from an already opened channel
+func NewWithChannel(channel Channel) AMQP {
+	return &AMQPImpl{
+		channel: channel,
+	}
+}
+
+func (a *AMQPImpl) Get() Channel {
 	return a.channel
 }
+
+// Ping - the in-process broker has no connection and is always open
+func (a *AMQPImpl) Ping(ctx context.Context) error {
+	if a.connection != nil && a.connection.IsClosed() {
+		return ErrClosed
+	}
+
+	return nil
+}
+
+func (a *AMQPImpl) Close() error {
+	err := a.channel.Close()
+	if a.connection != nil && !a.connection.IsClosed() {
+		if connErr := a.connection.Close(); err == nil {
+			err = connErr
+		}
+	}
+
+	return err
+}
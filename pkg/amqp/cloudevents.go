@@ -0,0 +1,340 @@
+package amqp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// CloudEvents 1.0 AMQP protocol binding
+// https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/bindings/amqp-protocol-binding.md
+const (
+	CloudEventsSpecVersion = "1.0"
+
+	// CloudEventsContentType - content type of the structured mode JSON envelope
+	CloudEventsContentType = "application/cloudevents+json; charset=utf-8"
+
+	// CloudEventsPrefix - application-properties prefix of the binary mode attributes
+	CloudEventsPrefix = "cloudEvents:"
+
+	// cloudEventsAltPrefix - alternative prefix allowed by the binding for JMS 2.0 compatibility
+	cloudEventsAltPrefix = "cloudEvents_"
+)
+
+var ErrCloudEventMode = errors.New("message is not a cloudevent")
+
+// CloudEvent - cloudevent context attributes and data
+type CloudEvent struct {
+	ID              string
+	Source          string
+	SpecVersion     string
+	Type            string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	Time            time.Time
+	Extensions      map[string]string
+	Data            []byte
+}
+
+// Validate - check the required context attributes
+func (e *CloudEvent) Validate() error {
+	missing := []string{}
+	if e.ID == "" {
+		missing = append(missing, "id")
+	}
+	if e.Source == "" {
+		missing = append(missing, "source")
+	}
+	if e.SpecVersion == "" {
+		missing = append(missing, "specversion")
+	}
+	if e.Type == "" {
+		missing = append(missing, "type")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("cloudevent: missing required attribute %s", strings.Join(missing, ", "))
+	}
+
+	if e.SpecVersion != CloudEventsSpecVersion {
+		return fmt.Errorf("cloudevent: unsupported specversion %s", e.SpecVersion)
+	}
+
+	return nil
+}
+
+// Context - extract the distributed tracing extension of the event into ctx
+func (e *CloudEvent) Context(ctx context.Context) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(e.Extensions))
+}
+
+// attributes - optional and extension attributes which are set, keyed by attribute name
+func (e *CloudEvent) attributes() map[string]string {
+	attrs := map[string]string{}
+	for k, v := range e.Extensions {
+		attrs[k] = v
+	}
+
+	attrs["id"] = e.ID
+	attrs["source"] = e.Source
+	attrs["specversion"] = e.SpecVersion
+	attrs["type"] = e.Type
+	if e.DataSchema != "" {
+		attrs["dataschema"] = e.DataSchema
+	}
+	if e.Subject != "" {
+		attrs["subject"] = e.Subject
+	}
+	if !e.Time.IsZero() {
+		attrs["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	return attrs
+}
+
+// setAttribute - set context attribute by name, unknown names become extensions
+func (e *CloudEvent) setAttribute(name string, value string) error {
+	switch name {
+	case "id":
+		e.ID = value
+	case "source":
+		e.Source = value
+	case "specversion":
+		e.SpecVersion = value
+	case "type":
+		e.Type = value
+	case "datacontenttype":
+		e.DataContentType = value
+	case "dataschema":
+		e.DataSchema = value
+	case "subject":
+		e.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("cloudevent: invalid time %q: %w", value, err)
+		}
+		e.Time = t
+	default:
+		if e.Extensions == nil {
+			e.Extensions = map[string]string{}
+		}
+		e.Extensions[name] = value
+	}
+
+	return nil
+}
+
+// injectTracing - put the trace context of ctx into the event and the amqp headers
+func (e *CloudEvent) injectTracing(ctx context.Context) map[string]interface{} {
+	headers := InjectAMQPHeaders(ctx)
+	for k, v := range headers {
+		if e.Extensions == nil {
+			e.Extensions = map[string]string{}
+		}
+		e.Extensions[k] = v.(string)
+	}
+
+	return headers
+}
+
+// EncodeBinary - encode the event in binary content mode, attributes are sent as application-properties
+func EncodeBinary(ctx context.Context, e *CloudEvent) (amqp.Publishing, error) {
+	if err := e.Validate(); err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	headers := e.injectTracing(ctx)
+	for k, v := range e.attributes() {
+		headers[CloudEventsPrefix+k] = v
+	}
+
+	return amqp.Publishing{
+		Headers:     headers,
+		ContentType: e.DataContentType,
+		MessageId:   e.ID,
+		Type:        e.Type,
+		Timestamp:   e.Time,
+		Body:        e.Data,
+	}, nil
+}
+
+// EncodeStructured - encode the event in structured content mode as JSON envelope
+func EncodeStructured(ctx context.Context, e *CloudEvent) (amqp.Publishing, error) {
+	if err := e.Validate(); err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	headers := e.injectTracing(ctx)
+
+	envelope := map[string]interface{}{}
+	for k, v := range e.attributes() {
+		envelope[k] = v
+	}
+
+	if e.DataContentType != "" {
+		envelope["datacontenttype"] = e.DataContentType
+	}
+
+	if e.Data != nil {
+		if isJSONContentType(e.DataContentType) && json.Valid(e.Data) {
+			envelope["data"] = json.RawMessage(e.Data)
+		} else {
+			envelope["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	return amqp.Publishing{
+		Headers:     headers,
+		ContentType: CloudEventsContentType,
+		MessageId:   e.ID,
+		Type:        e.Type,
+		Timestamp:   e.Time,
+		Body:        body,
+	}, nil
+}
+
+// DecodeCloudEvent - decode the delivery in either binary or structured content mode
+func DecodeCloudEvent(d amqp.Delivery) (*CloudEvent, error) {
+	if strings.HasPrefix(d.ContentType, "application/cloudevents") {
+		return decodeStructured(d)
+	}
+
+	if _, ok := cloudEventsHeader(d.Headers, "specversion"); ok {
+		return decodeBinary(d)
+	}
+
+	return nil, ErrCloudEventMode
+}
+
+func decodeBinary(d amqp.Delivery) (*CloudEvent, error) {
+	e := &CloudEvent{
+		DataContentType: d.ContentType,
+		Data:            d.Body,
+	}
+
+	for k, v := range d.Headers {
+		var name string
+		switch {
+		case strings.HasPrefix(k, CloudEventsPrefix):
+			name = strings.TrimPrefix(k, CloudEventsPrefix)
+		case strings.HasPrefix(k, cloudEventsAltPrefix):
+			name = strings.TrimPrefix(k, cloudEventsAltPrefix)
+		default:
+			continue
+		}
+
+		value, err := headerString(v)
+		if err != nil {
+			return nil, fmt.Errorf("cloudevent: attribute %s: %w", name, err)
+		}
+
+		if err := e.setAttribute(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func decodeStructured(d amqp.Delivery) (*CloudEvent, error) {
+	envelope := map[string]json.RawMessage{}
+	if err := json.Unmarshal(d.Body, &envelope); err != nil {
+		return nil, fmt.Errorf("cloudevent: invalid structured envelope: %w", err)
+	}
+
+	e := &CloudEvent{}
+	for k, raw := range envelope {
+		switch k {
+		case "data":
+			e.Data = []byte(raw)
+		case "data_base64":
+			var encoded string
+			if err := json.Unmarshal(raw, &encoded); err != nil {
+				return nil, fmt.Errorf("cloudevent: invalid data_base64: %w", err)
+			}
+
+			data, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("cloudevent: invalid data_base64: %w", err)
+			}
+			e.Data = data
+		default:
+			var value interface{}
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, err
+			}
+
+			if err := e.setAttribute(k, fmt.Sprint(value)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// data of a JSON content type is a JSON value, a JSON string is the string itself
+	if _, ok := envelope["data"]; ok && !isJSONContentType(e.DataContentType) {
+		var s string
+		if err := json.Unmarshal(e.Data, &s); err == nil {
+			e.Data = []byte(s)
+		}
+	}
+
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// cloudEventsHeader - get binary mode attribute with either of the allowed prefixes
+func cloudEventsHeader(headers amqp.Table, name string) (interface{}, bool) {
+	if v, ok := headers[CloudEventsPrefix+name]; ok {
+		return v, true
+	}
+
+	v, ok := headers[cloudEventsAltPrefix+name]
+	return v, ok
+}
+
+// headerString - canonical string representation of an application-property
+func headerString(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case []byte:
+		return string(value), nil
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano), nil
+	case bool, int, int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(value), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,238 @@
+package amqp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pkgamqp "go-rengan/pkg/amqp"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var specTime = time.Date(2018, 4, 5, 3, 56, 24, 0, time.UTC)
+
+func tracedContext() context.Context {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+
+	return trace.ContextWithRemoteSpanContext(context.Background(), sc)
+}
+
+// TestDecodeCloudEventSpecExamples - examples of the AMQP protocol binding spec, section 3
+func TestDecodeCloudEventSpecExamples(t *testing.T) {
+	t.Run("binary content mode", func(t *testing.T) {
+		d := amqp.Delivery{
+			ContentType: "application/json; charset=utf-8",
+			Headers: amqp.Table{
+				"cloudEvents:specversion": "1.0",
+				"cloudEvents:type":        "com.example.someevent",
+				"cloudEvents:time":        "2018-04-05T03:56:24Z",
+				"cloudEvents:id":          "1234-1234-1234",
+				"cloudEvents:source":      "/mycontext/subcontext",
+			},
+			Body: []byte(`{"key":"value"}`),
+		}
+
+		e, err := pkgamqp.DecodeCloudEvent(d)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.0", e.SpecVersion)
+		assert.Equal(t, "com.example.someevent", e.Type)
+		assert.Equal(t, specTime, e.Time)
+		assert.Equal(t, "1234-1234-1234", e.ID)
+		assert.Equal(t, "/mycontext/subcontext", e.Source)
+		assert.Equal(t, "application/json; charset=utf-8", e.DataContentType)
+		assert.Equal(t, []byte(`{"key":"value"}`), e.Data)
+	})
+
+	t.Run("binary content mode with jms compatible prefix", func(t *testing.T) {
+		d := amqp.Delivery{
+			ContentType: "text/plain",
+			Headers: amqp.Table{
+				"cloudEvents_specversion":          "1.0",
+				"cloudEvents_type":                 "com.example.someevent",
+				"cloudEvents_id":                   "1234-1234-1234",
+				"cloudEvents_source":               "/mycontext/subcontext",
+				"cloudEvents_comexampleextension1": "value",
+			},
+			Body: []byte("hello"),
+		}
+
+		e, err := pkgamqp.DecodeCloudEvent(d)
+		assert.NoError(t, err)
+		assert.Equal(t, "1234-1234-1234", e.ID)
+		assert.Equal(t, "value", e.Extensions["comexampleextension1"])
+		assert.Equal(t, []byte("hello"), e.Data)
+	})
+
+	t.Run("structured content mode", func(t *testing.T) {
+		d := amqp.Delivery{
+			ContentType: "application/cloudevents+json; charset=utf-8",
+			Body: []byte(`{
+				"specversion" : "1.0",
+				"type" : "com.example.someevent",
+				"source" : "/mycontext/subcontext",
+				"id" : "1234-1234-1234",
+				"time" : "2018-04-05T03:56:24Z",
+				"comexampleextension1" : "value",
+				"datacontenttype" : "application/json",
+				"data" : {"key":"value"}
+			}`),
+		}
+
+		e, err := pkgamqp.DecodeCloudEvent(d)
+		assert.NoError(t, err)
+		assert.Equal(t, "com.example.someevent", e.Type)
+		assert.Equal(t, specTime, e.Time)
+		assert.Equal(t, "application/json", e.DataContentType)
+		assert.Equal(t, "value", e.Extensions["comexampleextension1"])
+		assert.JSONEq(t, `{"key":"value"}`, string(e.Data))
+	})
+
+	t.Run("structured content mode with data_base64", func(t *testing.T) {
+		d := amqp.Delivery{
+			ContentType: "application/cloudevents+json",
+			Body: []byte(`{
+				"specversion" : "1.0",
+				"type" : "com.example.someevent",
+				"source" : "/mycontext/subcontext",
+				"id" : "1234-1234-1234",
+				"datacontenttype" : "application/octet-stream",
+				"data_base64" : "aGVsbG8="
+			}`),
+		}
+
+		e, err := pkgamqp.DecodeCloudEvent(d)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("hello"), e.Data)
+	})
+
+	t.Run("error when missing required attribute", func(t *testing.T) {
+		d := amqp.Delivery{
+			Headers: amqp.Table{
+				"cloudEvents:specversion": "1.0",
+				"cloudEvents:type":        "com.example.someevent",
+			},
+		}
+
+		_, err := pkgamqp.DecodeCloudEvent(d)
+		assert.EqualError(t, err, "cloudevent: missing required attribute id, source")
+	})
+
+	t.Run("error when not a cloudevent", func(t *testing.T) {
+		_, err := pkgamqp.DecodeCloudEvent(amqp.Delivery{ContentType: "text/plain", Body: []byte("example.com")})
+		assert.ErrorIs(t, err, pkgamqp.ErrCloudEventMode)
+	})
+}
+
+func TestEncodeCloudEvent(t *testing.T) {
+	newEvent := func() *pkgamqp.CloudEvent {
+		return &pkgamqp.CloudEvent{
+			ID:              "1234-1234-1234",
+			Source:          "/mycontext/subcontext",
+			SpecVersion:     pkgamqp.CloudEventsSpecVersion,
+			Type:            "com.example.someevent",
+			DataContentType: "application/json",
+			Subject:         "todo",
+			Time:            specTime,
+			Data:            []byte(`{"key":"value"}`),
+		}
+	}
+
+	t.Run("binary content mode round trip", func(t *testing.T) {
+		msg, err := pkgamqp.EncodeBinary(context.Background(), newEvent())
+		assert.NoError(t, err)
+		assert.Equal(t, "application/json", msg.ContentType)
+		assert.Equal(t, "1.0", msg.Headers["cloudEvents:specversion"])
+		assert.Equal(t, "2018-04-05T03:56:24Z", msg.Headers["cloudEvents:time"])
+		assert.Equal(t, "1234-1234-1234", msg.MessageId)
+
+		e, err := pkgamqp.DecodeCloudEvent(amqp.Delivery{Headers: msg.Headers, ContentType: msg.ContentType, Body: msg.Body})
+		assert.NoError(t, err)
+		assert.Equal(t, newEvent(), e)
+	})
+
+	t.Run("structured content mode round trip", func(t *testing.T) {
+		msg, err := pkgamqp.EncodeStructured(context.Background(), newEvent())
+		assert.NoError(t, err)
+		assert.Equal(t, pkgamqp.CloudEventsContentType, msg.ContentType)
+		assert.JSONEq(t, `{
+			"id": "1234-1234-1234",
+			"source": "/mycontext/subcontext",
+			"specversion": "1.0",
+			"type": "com.example.someevent",
+			"datacontenttype": "application/json",
+			"subject": "todo",
+			"time": "2018-04-05T03:56:24Z",
+			"data": {"key":"value"}
+		}`, string(msg.Body))
+
+		e, err := pkgamqp.DecodeCloudEvent(amqp.Delivery{Headers: msg.Headers, ContentType: msg.ContentType, Body: msg.Body})
+		assert.NoError(t, err)
+		assert.Equal(t, newEvent(), e)
+	})
+
+	t.Run("structured content mode with binary data", func(t *testing.T) {
+		event := newEvent()
+		event.DataContentType = "application/octet-stream"
+		event.Data = []byte{0x00, 0x01}
+
+		msg, err := pkgamqp.EncodeStructured(context.Background(), event)
+		assert.NoError(t, err)
+		assert.Contains(t, string(msg.Body), `"data_base64":"AAE="`)
+
+		e, err := pkgamqp.DecodeCloudEvent(amqp.Delivery{ContentType: msg.ContentType, Body: msg.Body})
+		assert.NoError(t, err)
+		assert.Equal(t, event.Data, e.Data)
+	})
+
+	t.Run("error when invalid event", func(t *testing.T) {
+		_, err := pkgamqp.EncodeBinary(context.Background(), &pkgamqp.CloudEvent{ID: "1", Source: "/", Type: "t", SpecVersion: "0.3"})
+		assert.EqualError(t, err, "cloudevent: unsupported specversion 0.3")
+	})
+}
+
+func TestCloudEventTracePropagation(t *testing.T) {
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	t.Run("binary content mode", func(t *testing.T) {
+		msg, err := pkgamqp.EncodeBinary(tracedContext(), &pkgamqp.CloudEvent{ID: "1", Source: "/", Type: "t", SpecVersion: "1.0"})
+		assert.NoError(t, err)
+		assert.Equal(t, traceparent, msg.Headers["traceparent"])
+		assert.Equal(t, traceparent, msg.Headers["cloudEvents:traceparent"])
+
+		ctx := pkgamqp.ExtractAMQPHeaders(context.Background(), msg.Headers)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace.SpanContextFromContext(ctx).TraceID().String())
+	})
+
+	t.Run("binary content mode from a cloudevents sdk producer", func(t *testing.T) {
+		headers := amqp.Table{"cloudEvents:traceparent": traceparent}
+
+		ctx := pkgamqp.ExtractAMQPHeaders(tracedContext(), headers)
+		assert.Equal(t, "00f067aa0ba902b7", trace.SpanContextFromContext(ctx).SpanID().String())
+	})
+
+	t.Run("structured content mode", func(t *testing.T) {
+		msg, err := pkgamqp.EncodeStructured(tracedContext(), &pkgamqp.CloudEvent{ID: "1", Source: "/", Type: "t", SpecVersion: "1.0"})
+		assert.NoError(t, err)
+		assert.Contains(t, string(msg.Body), `"traceparent":"`+traceparent+`"`)
+
+		e, err := pkgamqp.DecodeCloudEvent(amqp.Delivery{ContentType: msg.ContentType, Body: msg.Body})
+		assert.NoError(t, err)
+
+		ctx := e.Context(context.Background())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace.SpanContextFromContext(ctx).TraceID().String())
+	})
+}
@@ -0,0 +1,584 @@
+// Package memory is an in-process stand-in of a RabbitMQ channel.
+//
+// It supports the default, direct, fanout and topic exchanges, server-named, auto-delete
+// and dead-lettered queues, per-message expiration, acks, nacks and redelivery. Deliveries
+// are plain amqp.Delivery values, so consumers written against streadway/amqp run unchanged.
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-rengan/pkg/amqp/topic"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+var (
+	ErrExchangeNotFound = errors.New("memory broker: exchange not found")
+	ErrQueueNotFound    = errors.New("memory broker: queue not found")
+	ErrExchangeKind     = errors.New("memory broker: unsupported exchange kind")
+	ErrUnknownTag       = errors.New("memory broker: unknown delivery tag")
+	ErrExpiration       = errors.New("memory broker: invalid expiration")
+)
+
+type Broker struct {
+	mu        sync.Mutex
+	exchanges map[string]*exchange
+	queues    map[string]*queue
+	consumers map[string]*consumer
+	unacked   map[uint64]*unacked
+	tag       uint64
+	closed    bool
+}
+
+type exchange struct {
+	kind     string
+	bindings []binding
+}
+
+type binding struct {
+	queue string
+	key   string
+}
+
+type queue struct {
+	name       string
+	autoDelete bool
+	args       amqp.Table
+	messages   []*message
+	consumers  int
+	cond       *sync.Cond
+}
+
+type message struct {
+	publishing  amqp.Publishing
+	exchange    string
+	routingKey  string
+	redelivered bool
+	// expiresAt is zero without expiration, the message is dead-lettered once it is past
+	expiresAt time.Time
+}
+
+type consumer struct {
+	tag   string
+	queue *queue
+	done  chan struct{}
+}
+
+type unacked struct {
+	queue   *queue
+	message *message
+}
+
+// New - make empty broker with the default exchange
+func New() *Broker {
+	return &Broker{
+		exchanges: map[string]*exchange{"": {kind: amqp.ExchangeDirect}},
+		queues:    map[string]*queue{},
+		consumers: map[string]*consumer{},
+		unacked:   map[uint64]*unacked{},
+	}
+}
+
+// ExchangeDeclare - declare exchange, redeclaring with the same kind is a no-op
+func (b *Broker) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return amqp.ErrClosed
+	}
+
+	switch kind {
+	case amqp.ExchangeDirect, amqp.ExchangeFanout, amqp.ExchangeTopic:
+	default:
+		return fmt.Errorf("%w: %s", ErrExchangeKind, kind)
+	}
+
+	if e, ok := b.exchanges[name]; ok {
+		if e.kind != kind {
+			return fmt.Errorf("memory broker: exchange %s redeclared as %s, was %s", name, kind, e.kind)
+		}
+		return nil
+	}
+
+	b.exchanges[name] = &exchange{kind: kind}
+	return nil
+}
+
+// QueueDeclare - declare queue, an empty name declares a server-named queue
+func (b *Broker) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return amqp.Queue{}, amqp.ErrClosed
+	}
+
+	if name == "" {
+		name = "amq.gen-" + uuid.NewString()
+	}
+
+	q, ok := b.queues[name]
+	if !ok {
+		q = &queue{
+			name:       name,
+			autoDelete: autoDelete,
+			args:       args,
+			cond:       sync.NewCond(&b.mu),
+		}
+		b.queues[name] = q
+	}
+
+	b.expire(q)
+
+	return amqp.Queue{Name: name, Messages: len(q.messages), Consumers: q.consumers}, nil
+}
+
+// QueueBind - bind queue to exchange with the routing key
+func (b *Broker) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return amqp.ErrClosed
+	}
+
+	e, ok := b.exchanges[exchange]
+	if !ok || exchange == "" {
+		return fmt.Errorf("%w: %s", ErrExchangeNotFound, exchange)
+	}
+
+	if _, ok := b.queues[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrQueueNotFound, name)
+	}
+
+	for _, bd := range e.bindings {
+		if bd.queue == name && bd.key == key {
+			return nil
+		}
+	}
+	e.bindings = append(e.bindings, binding{queue: name, key: key})
+
+	return nil
+}
+
+// Qos - prefetch is not limited by the memory broker
+func (b *Broker) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return nil
+}
+
+// Publish - route the message to every matching queue, unroutable messages are dropped
+func (b *Broker) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return amqp.ErrClosed
+	}
+
+	return b.route(exchange, key, msg)
+}
+
+// Consume - start delivering messages of the queue on the returned channel
+func (b *Broker) Consume(queueName, consumerTag string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, amqp.ErrClosed
+	}
+
+	q, ok := b.queues[queueName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrQueueNotFound, queueName)
+	}
+
+	if consumerTag == "" {
+		consumerTag = "ctag-" + uuid.NewString()
+	}
+
+	c := &consumer{
+		tag:   consumerTag,
+		queue: q,
+		done:  make(chan struct{}),
+	}
+	b.consumers[consumerTag] = c
+	q.consumers++
+
+	deliveries := make(chan amqp.Delivery)
+	go b.deliver(c, autoAck, deliveries)
+
+	return deliveries, nil
+}
+
+// Cancel - stop the consumer, its delivery channel is closed
+func (b *Broker) Cancel(consumerTag string, noWait bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.consumers[consumerTag]
+	if !ok {
+		return nil
+	}
+
+	b.cancel(c)
+	return nil
+}
+
+// Close - stop every consumer, later operations fail with amqp.ErrClosed
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return amqp.ErrClosed
+	}
+	b.closed = true
+
+	for _, c := range b.consumers {
+		b.cancel(c)
+	}
+
+	return nil
+}
+
+// Ack - acknowledge the delivery, multiple acknowledges every earlier unacked delivery too
+func (b *Broker) Ack(tag uint64, multiple bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tags, err := b.pending(tag, multiple)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tags {
+		delete(b.unacked, t)
+	}
+
+	return nil
+}
+
+// Nack - negatively acknowledge the delivery, it is requeued or dead-lettered
+func (b *Broker) Nack(tag uint64, multiple bool, requeue bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tags, err := b.pending(tag, multiple)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tags {
+		u := b.unacked[t]
+		delete(b.unacked, t)
+
+		if requeue {
+			u.message.redelivered = true
+			u.queue.messages = append([]*message{u.message}, u.queue.messages...)
+			u.queue.cond.Signal()
+			continue
+		}
+
+		b.deadLetter(u.queue, u.message, "rejected")
+	}
+
+	return nil
+}
+
+// Reject - negatively acknowledge a single delivery
+func (b *Broker) Reject(tag uint64, requeue bool) error {
+	return b.Nack(tag, false, requeue)
+}
+
+// QueueLength - number of ready messages in the queue
+func (b *Broker) QueueLength(name string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[name]
+	if !ok {
+		return 0
+	}
+
+	b.expire(q)
+
+	return len(q.messages)
+}
+
+// route - push the message to the queues bound to the exchange, b.mu must be held
+func (b *Broker) route(exchangeName, key string, msg amqp.Publishing) error {
+	e, ok := b.exchanges[exchangeName]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrExchangeNotFound, exchangeName)
+	}
+
+	// Like RabbitMQ the expiration counts from the time the message is queued
+	var expiresAt time.Time
+	if msg.Expiration != "" {
+		ttl, err := strconv.ParseInt(msg.Expiration, 10, 64)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("%w: %s", ErrExpiration, msg.Expiration)
+		}
+		expiresAt = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+	}
+
+	targets := []string{}
+	if exchangeName == "" {
+		targets = append(targets, key)
+	}
+
+	for _, bd := range e.bindings {
+		if matches(e.kind, bd.key, key) {
+			targets = append(targets, bd.queue)
+		}
+	}
+
+	routed := map[string]bool{}
+	for _, name := range targets {
+		q, ok := b.queues[name]
+		if !ok || routed[name] {
+			continue
+		}
+		routed[name] = true
+
+		q.messages = append(q.messages, &message{
+			publishing: copyPublishing(msg),
+			exchange:   exchangeName,
+			routingKey: key,
+			expiresAt:  expiresAt,
+		})
+		q.cond.Signal()
+
+		if !expiresAt.IsZero() {
+			// Expire the message even when the queue has no consumer, it may be a delay queue
+			time.AfterFunc(time.Until(expiresAt)+time.Millisecond, func() {
+				b.mu.Lock()
+				defer b.mu.Unlock()
+
+				if !b.closed && b.queues[q.name] == q {
+					b.expire(q)
+				}
+			})
+		}
+	}
+
+	return nil
+}
+
+// deadLetter - republish to the x-dead-letter-exchange of the queue, or drop, b.mu must be held
+func (b *Broker) deadLetter(q *queue, m *message, reason string) {
+	dlx, ok := q.args["x-dead-letter-exchange"].(string)
+	if !ok {
+		return
+	}
+
+	key := m.routingKey
+	if dlk, ok := q.args["x-dead-letter-routing-key"].(string); ok {
+		key = dlk
+	}
+
+	msg := copyPublishing(m.publishing)
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+
+	// Like RabbitMQ the entry of the queue and reason counts the deaths and moves first,
+	// the expiration is moved to the entry so the message does not expire again
+	death := amqp.Table{
+		"queue":        q.name,
+		"reason":       reason,
+		"exchange":     m.exchange,
+		"routing-keys": []interface{}{m.routingKey},
+		"time":         time.Now(),
+		"count":        int64(1),
+	}
+	if msg.Expiration != "" {
+		death["original-expiration"] = msg.Expiration
+		msg.Expiration = ""
+	}
+
+	deaths := []interface{}{death}
+	previous, _ := msg.Headers["x-death"].([]interface{})
+	for _, p := range previous {
+		entry, ok := p.(amqp.Table)
+		if ok && entry["queue"] == q.name && entry["reason"] == reason {
+			count, _ := entry["count"].(int64)
+			death["count"] = count + 1
+			continue
+		}
+		deaths = append(deaths, p)
+	}
+	msg.Headers["x-death"] = deaths
+
+	// An unknown dead letter exchange drops the message, like RabbitMQ does
+	_ = b.route(dlx, key, msg)
+}
+
+// deliver - consumer loop, sends ready messages until the consumer is cancelled
+func (b *Broker) deliver(c *consumer, autoAck bool, deliveries chan amqp.Delivery) {
+	defer close(deliveries)
+
+	for {
+		b.mu.Lock()
+		b.expire(c.queue)
+		for len(c.queue.messages) == 0 && !isDone(c.done) {
+			c.queue.cond.Wait()
+			b.expire(c.queue)
+		}
+
+		if isDone(c.done) {
+			b.mu.Unlock()
+			return
+		}
+
+		m := c.queue.messages[0]
+		c.queue.messages = c.queue.messages[1:]
+
+		b.tag++
+		tag := b.tag
+		if !autoAck {
+			b.unacked[tag] = &unacked{queue: c.queue, message: m}
+		}
+		b.mu.Unlock()
+
+		d := delivery(b, c.tag, tag, m)
+		select {
+		case deliveries <- d:
+		case <-c.done:
+			// Nobody will receive it anymore, give the message back to the queue
+			if !autoAck {
+				_ = b.Nack(tag, false, true)
+			}
+			return
+		}
+	}
+}
+
+// cancel - stop the consumer and delete its auto-delete queue when it was the last one, b.mu must be held
+func (b *Broker) cancel(c *consumer) {
+	if isDone(c.done) {
+		return
+	}
+
+	close(c.done)
+	delete(b.consumers, c.tag)
+	c.queue.cond.Broadcast()
+
+	c.queue.consumers--
+	if c.queue.autoDelete && c.queue.consumers == 0 {
+		delete(b.queues, c.queue.name)
+		for _, e := range b.exchanges {
+			bindings := e.bindings[:0]
+			for _, bd := range e.bindings {
+				if bd.queue != c.queue.name {
+					bindings = append(bindings, bd)
+				}
+			}
+			e.bindings = bindings
+		}
+	}
+}
+
+// expire - dead-letter the expired ready messages of the queue, b.mu must be held.
+// The unacked messages do not expire, like on RabbitMQ
+func (b *Broker) expire(q *queue) {
+	now := time.Now()
+	messages := q.messages[:0]
+	for _, m := range q.messages {
+		if !m.expiresAt.IsZero() && now.After(m.expiresAt) {
+			b.deadLetter(q, m, "expired")
+			continue
+		}
+		messages = append(messages, m)
+	}
+	q.messages = messages
+}
+
+// pending - unacked tags acknowledged by tag, b.mu must be held
+func (b *Broker) pending(tag uint64, multiple bool) ([]uint64, error) {
+	if !multiple {
+		if _, ok := b.unacked[tag]; !ok {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownTag, tag)
+		}
+		return []uint64{tag}, nil
+	}
+
+	tags := []uint64{}
+	for t := range b.unacked {
+		if t <= tag {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags, nil
+}
+
+func delivery(b *Broker, consumerTag string, tag uint64, m *message) amqp.Delivery {
+	msg := copyPublishing(m.publishing)
+
+	return amqp.Delivery{
+		Acknowledger:    b,
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		ConsumerTag:     consumerTag,
+		DeliveryTag:     tag,
+		Redelivered:     m.redelivered,
+		Exchange:        m.exchange,
+		RoutingKey:      m.routingKey,
+		Body:            msg.Body,
+	}
+}
+
+// copyPublishing - copy headers and body so publishers and consumers never share them
+func copyPublishing(msg amqp.Publishing) amqp.Publishing {
+	if msg.Headers != nil {
+		headers := amqp.Table{}
+		for k, v := range msg.Headers {
+			headers[k] = v
+		}
+		msg.Headers = headers
+	}
+
+	msg.Body = append([]byte(nil), msg.Body...)
+
+	return msg
+}
+
+// matches - whether the binding key matches the routing key for the exchange kind
+func matches(kind, bindingKey, routingKey string) bool {
+	switch kind {
+	case amqp.ExchangeFanout:
+		return true
+	case amqp.ExchangeTopic:
+		return topic.Match(bindingKey, routingKey)
+	default:
+		return bindingKey == routingKey
+	}
+}
+
+func isDone(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
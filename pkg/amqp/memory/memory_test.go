@@ -0,0 +1,260 @@
+package memory_test
+
+import (
+	"testing"
+	"time"
+
+	"go-rengan/pkg/amqp/memory"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(t *testing.T, deliveries <-chan amqp.Delivery) amqp.Delivery {
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("no delivery received")
+		return amqp.Delivery{}
+	}
+}
+
+func consume(t *testing.T, b *memory.Broker, queue string, autoAck bool) <-chan amqp.Delivery {
+	deliveries, err := b.Consume(queue, "", autoAck, false, false, false, nil)
+	assert.NoError(t, err)
+
+	return deliveries
+}
+
+func TestPublish(t *testing.T) {
+	t.Run("success when publish to the default exchange", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		q, err := b.QueueDeclare("send_email", true, false, false, false, nil)
+		assert.NoError(t, err)
+
+		err = b.Publish("", q.Name, false, false, amqp.Publishing{
+			Headers:   amqp.Table{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			MessageId: "1",
+			Body:      []byte("example.com"),
+		})
+		assert.NoError(t, err)
+
+		d := receive(t, consume(t, b, q.Name, true))
+		assert.Equal(t, "1", d.MessageId)
+		assert.Equal(t, "send_email", d.RoutingKey)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", d.Headers["traceparent"])
+		assert.Equal(t, []byte("example.com"), d.Body)
+	})
+
+	t.Run("success when route by topic", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		assert.NoError(t, b.ExchangeDeclare("todo.events", amqp.ExchangeTopic, true, false, false, false, nil))
+		for name, key := range map[string]string{"created": "todo.created", "all": "todo.*", "everything": "#", "nested": "todo.*.v1"} {
+			_, err := b.QueueDeclare(name, true, false, false, false, nil)
+			assert.NoError(t, err)
+			assert.NoError(t, b.QueueBind(name, key, "todo.events", false, nil))
+		}
+
+		assert.NoError(t, b.Publish("todo.events", "todo.created", false, false, amqp.Publishing{}))
+		assert.NoError(t, b.Publish("todo.events", "todo.deleted", false, false, amqp.Publishing{}))
+
+		assert.Equal(t, 1, b.QueueLength("created"))
+		assert.Equal(t, 2, b.QueueLength("all"))
+		assert.Equal(t, 2, b.QueueLength("everything"))
+		assert.Equal(t, 0, b.QueueLength("nested"))
+	})
+
+	t.Run("success when route by fanout", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		assert.NoError(t, b.ExchangeDeclare("broadcast", amqp.ExchangeFanout, true, false, false, false, nil))
+		for _, name := range []string{"a", "b"} {
+			_, err := b.QueueDeclare(name, true, false, false, false, nil)
+			assert.NoError(t, err)
+			assert.NoError(t, b.QueueBind(name, "", "broadcast", false, nil))
+		}
+
+		assert.NoError(t, b.Publish("broadcast", "anything", false, false, amqp.Publishing{}))
+		assert.Equal(t, 1, b.QueueLength("a"))
+		assert.Equal(t, 1, b.QueueLength("b"))
+	})
+
+	t.Run("error when exchange not found", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		err := b.Publish("missing", "key", false, false, amqp.Publishing{})
+		assert.ErrorIs(t, err, memory.ErrExchangeNotFound)
+	})
+
+	t.Run("error when closed", func(t *testing.T) {
+		b := memory.New()
+		assert.NoError(t, b.Close())
+
+		err := b.Publish("", "key", false, false, amqp.Publishing{})
+		assert.ErrorIs(t, err, amqp.ErrClosed)
+	})
+}
+
+func TestAcknowledge(t *testing.T) {
+	t.Run("success when nack requeues for redelivery", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		_, err := b.QueueDeclare("q", true, false, false, false, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, b.Publish("", "q", false, false, amqp.Publishing{Body: []byte("1")}))
+
+		deliveries := consume(t, b, "q", false)
+		d := receive(t, deliveries)
+		assert.False(t, d.Redelivered)
+		assert.NoError(t, d.Nack(false, true))
+
+		d = receive(t, deliveries)
+		assert.True(t, d.Redelivered)
+		assert.Equal(t, []byte("1"), d.Body)
+		assert.NoError(t, d.Ack(false))
+
+		assert.ErrorIs(t, d.Ack(false), memory.ErrUnknownTag)
+	})
+
+	t.Run("success when reject dead-letters", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		assert.NoError(t, b.ExchangeDeclare("dlx", amqp.ExchangeDirect, true, false, false, false, nil))
+		_, err := b.QueueDeclare("dead", true, false, false, false, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, b.QueueBind("dead", "q", "dlx", false, nil))
+
+		_, err = b.QueueDeclare("q", true, false, false, false, amqp.Table{"x-dead-letter-exchange": "dlx"})
+		assert.NoError(t, err)
+		assert.NoError(t, b.Publish("", "q", false, false, amqp.Publishing{Body: []byte("1")}))
+
+		d := receive(t, consume(t, b, "q", false))
+		assert.NoError(t, d.Reject(false))
+
+		dead := receive(t, consume(t, b, "dead", true))
+		assert.Equal(t, []byte("1"), dead.Body)
+		death := dead.Headers["x-death"].([]interface{})[0].(amqp.Table)
+		assert.Equal(t, "q", death["queue"])
+		assert.Equal(t, "rejected", death["reason"])
+	})
+
+	t.Run("success when dead-lettered again counts the deaths", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		// q and retry dead-letter to each other through the default exchange
+		_, err := b.QueueDeclare("q", true, false, false, false, amqp.Table{"x-dead-letter-exchange": "", "x-dead-letter-routing-key": "retry"})
+		assert.NoError(t, err)
+		_, err = b.QueueDeclare("retry", true, false, false, false, amqp.Table{"x-dead-letter-exchange": "", "x-dead-letter-routing-key": "q"})
+		assert.NoError(t, err)
+		assert.NoError(t, b.Publish("", "q", false, false, amqp.Publishing{Body: []byte("1")}))
+
+		deliveries := consume(t, b, "q", false)
+		retries := consume(t, b, "retry", false)
+		assert.NoError(t, receive(t, deliveries).Reject(false))
+		assert.NoError(t, receive(t, retries).Reject(false))
+		assert.NoError(t, receive(t, deliveries).Reject(false))
+
+		d := receive(t, retries)
+		deaths := d.Headers["x-death"].([]interface{})
+		assert.Len(t, deaths, 2)
+		assert.Equal(t, "q", deaths[0].(amqp.Table)["queue"])
+		assert.Equal(t, int64(2), deaths[0].(amqp.Table)["count"])
+		assert.Equal(t, "retry", deaths[1].(amqp.Table)["queue"])
+		assert.Equal(t, int64(1), deaths[1].(amqp.Table)["count"])
+	})
+
+	t.Run("success when cancel requeues the unreceived delivery", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		_, err := b.QueueDeclare("q", true, false, false, false, nil)
+		assert.NoError(t, err)
+
+		deliveries, err := b.Consume("q", "c1", false, false, false, false, nil)
+		assert.NoError(t, err)
+
+		assert.NoError(t, b.Publish("", "q", false, false, amqp.Publishing{Body: []byte("1")}))
+		assert.NoError(t, b.Cancel("c1", false))
+		for range deliveries {
+		}
+
+		d := receive(t, consume(t, b, "q", true))
+		assert.Equal(t, []byte("1"), d.Body)
+	})
+}
+
+func TestExpiration(t *testing.T) {
+	t.Run("success when expired message is dead-lettered without consumer", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		_, err := b.QueueDeclare("delay", true, false, false, false, amqp.Table{"x-dead-letter-exchange": "", "x-dead-letter-routing-key": "q"})
+		assert.NoError(t, err)
+		_, err = b.QueueDeclare("q", true, false, false, false, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, b.Publish("", "delay", false, false, amqp.Publishing{Expiration: "10", Body: []byte("1")}))
+
+		d := receive(t, consume(t, b, "q", true))
+		assert.Equal(t, []byte("1"), d.Body)
+		assert.Empty(t, d.Expiration)
+		death := d.Headers["x-death"].([]interface{})[0].(amqp.Table)
+		assert.Equal(t, "expired", death["reason"])
+		assert.Equal(t, "10", death["original-expiration"])
+		assert.Equal(t, 0, b.QueueLength("delay"))
+	})
+
+	t.Run("success when expired message is dropped", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		_, err := b.QueueDeclare("q", true, false, false, false, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, b.Publish("", "q", false, false, amqp.Publishing{Expiration: "1", Body: []byte("1")}))
+		assert.NoError(t, b.Publish("", "q", false, false, amqp.Publishing{Expiration: "60000", Body: []byte("2")}))
+		time.Sleep(10 * time.Millisecond)
+
+		assert.Equal(t, 1, b.QueueLength("q"))
+		d := receive(t, consume(t, b, "q", true))
+		assert.Equal(t, []byte("2"), d.Body)
+	})
+
+	t.Run("error when expiration is invalid", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		_, err := b.QueueDeclare("q", true, false, false, false, nil)
+		assert.NoError(t, err)
+
+		err = b.Publish("", "q", false, false, amqp.Publishing{Expiration: "soon"})
+		assert.ErrorIs(t, err, memory.ErrExpiration)
+		assert.Equal(t, 0, b.QueueLength("q"))
+	})
+}
+
+func TestQueueDeclare(t *testing.T) {
+	t.Run("success when server-named auto-delete queue", func(t *testing.T) {
+		b := memory.New()
+		defer b.Close()
+
+		q, err := b.QueueDeclare("", false, true, true, false, nil)
+		assert.NoError(t, err)
+		assert.Contains(t, q.Name, "amq.gen-")
+
+		_, err = b.Consume(q.Name, "c1", true, false, false, false, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, b.Cancel("c1", false))
+
+		_, err = b.Consume(q.Name, "c2", true, false, false, false, nil)
+		assert.ErrorIs(t, err, memory.ErrQueueNotFound)
+	})
+}
@@ -2,12 +2,16 @@ package amqp
 
 type AmqpHeadersCarrier map[string]interface{}
 
+// Get - get header value, falls back to the cloudevents binary mode attribute of the same name
 func (c AmqpHeadersCarrier) Get(key string) string {
-	v, ok := c[key]
-	if !ok {
-		return ""
+	for _, k := range []string{key, CloudEventsPrefix + key, cloudEventsAltPrefix + key} {
+		v, ok := c[k].(string)
+		if ok {
+			return v
+		}
 	}
-	return v.(string)
+
+	return ""
 }
 
 func (c AmqpHeadersCarrier) Set(key string, value string) {
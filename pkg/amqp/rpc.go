@@ -0,0 +1,192 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	tracing "go-rengan/pkg/tracing"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultRPCTimeout - how long a call waits for its reply when ctx has no deadline
+const DefaultRPCTimeout = 10 * time.Second
+
+var (
+	ErrRPCTimeout = errors.New("amqp rpc: timeout waiting for reply")
+	ErrRPCClosed  = errors.New("amqp rpc: reply queue closed")
+)
+
+// RPCClient - request/reply over amqp using reply_to and correlation_id.
+// It stays on the amqp channel rather than broker.Broker, which only publishes to topics for consumer groups:
+// a call needs an exclusive reply queue, a queue of the default exchange and a per request expiration
+type RPCClient interface {
+	Call(ctx context.Context, queue string, msg amqp.Publishing) (amqp.Delivery, error)
+}
+
+type RPCClientImpl struct {
+	tracing    tracing.Tracing
+	channel    Channel
+	timeout    time.Duration
+	mu         sync.Mutex
+	replyQueue string
+	pending    map[string]chan amqp.Delivery
+}
+
+// NewRPCClient - make rpc client, the reply queue is declared on the first call
+func NewRPCClient(tracing tracing.Tracing, a AMQP, timeout time.Duration) RPCClient {
+	if timeout <= 0 {
+		timeout = DefaultRPCTimeout
+	}
+
+	return &RPCClientImpl{
+		tracing: tracing,
+		channel: a.Get(),
+		timeout: timeout,
+		pending: map[string]chan amqp.Delivery{},
+	}
+}
+
+// Call - publish the request to the queue and wait for the reply with the same correlation id
+func (c *RPCClientImpl) Call(ctx context.Context, queue string, msg amqp.Publishing) (amqp.Delivery, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	tr := c.tracing.Tracer("amqp")
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindClient),
+	}
+	ctx, span := tr.Start(ctx, fmt.Sprintf("AMQP - rpc - %s", queue), opts...)
+	defer span.End()
+
+	replyQueue, err := c.replyTo()
+	if err != nil {
+		span.RecordError(err)
+		return amqp.Delivery{}, err
+	}
+
+	correlationID := uuid.NewString()
+	reply := make(chan amqp.Delivery, 1)
+
+	c.mu.Lock()
+	c.pending[correlationID] = reply
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, correlationID)
+		c.mu.Unlock()
+	}()
+
+	// Inject the context in the headers, next to the headers of the request
+	headers := InjectAMQPHeaders(ctx)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	msg.Headers = headers
+	msg.ReplyTo = replyQueue
+	msg.CorrelationId = correlationID
+	if msg.Expiration == "" {
+		// The request is useless once nobody waits for the reply anymore. RabbitMQ closes the channel
+		// on a negative expiration, a call whose deadline passed is not published
+		deadline, _ := ctx.Deadline()
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			err := timeoutErr(ctx)
+			span.RecordError(err)
+			return amqp.Delivery{}, err
+		}
+		if remaining < time.Millisecond {
+			remaining = time.Millisecond
+		}
+		msg.Expiration = fmt.Sprint(remaining.Milliseconds())
+	}
+
+	err = c.channel.Publish("", queue, false, false, msg)
+	if err != nil {
+		span.RecordError(err)
+		return amqp.Delivery{}, err
+	}
+
+	select {
+	case d, ok := <-reply:
+		if !ok {
+			span.RecordError(ErrRPCClosed)
+			return amqp.Delivery{}, ErrRPCClosed
+		}
+		return d, nil
+	case <-ctx.Done():
+		err := timeoutErr(ctx)
+		span.RecordError(err)
+		return amqp.Delivery{}, err
+	}
+}
+
+// timeoutErr - error of the call whose ctx is done or whose deadline passed
+func timeoutErr(ctx context.Context) error {
+	err := ctx.Err()
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		return ErrRPCTimeout
+	}
+
+	return err
+}
+
+// replyTo - declare the exclusive reply queue and start dispatching replies by correlation id
+func (c *RPCClientImpl) replyTo() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.replyQueue != "" {
+		return c.replyQueue, nil
+	}
+
+	q, err := c.channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return "", err
+	}
+
+	replies, err := c.channel.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return "", err
+	}
+
+	go c.dispatch(replies)
+	c.replyQueue = q.Name
+
+	return c.replyQueue, nil
+}
+
+func (c *RPCClientImpl) dispatch(replies <-chan amqp.Delivery) {
+	for d := range replies {
+		c.mu.Lock()
+		reply, ok := c.pending[d.CorrelationId]
+		c.mu.Unlock()
+
+		// Late replies of timed out calls and duplicate replies are dropped
+		if ok {
+			select {
+			case reply <- d:
+			default:
+			}
+		}
+	}
+
+	// Fail the waiting calls, the next call declares a new reply queue
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, reply := range c.pending {
+		close(reply)
+		delete(c.pending, id)
+	}
+	c.replyQueue = ""
+}
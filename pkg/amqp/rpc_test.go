@@ -0,0 +1,109 @@
+package amqp_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	"go-rengan/pkg/amqp/memory"
+	tracing "go-rengan/pkg/tracing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRPCClientCall(t *testing.T) {
+	t.Run("success when reply", func(t *testing.T) {
+		broker := memory.New()
+		defer broker.Close()
+
+		_, err := broker.QueueDeclare("echo", true, false, false, false, nil)
+		assert.NoError(t, err)
+		requests, err := broker.Consume("echo", "", true, false, false, false, nil)
+		assert.NoError(t, err)
+
+		go func() {
+			for d := range requests {
+				broker.Publish("", d.ReplyTo, false, false, amqp.Publishing{
+					CorrelationId: d.CorrelationId,
+					Body:          append([]byte("echo "), d.Body...),
+				})
+			}
+		}()
+
+		client := pkgamqp.NewRPCClient(tracing.NewNoop(), pkgamqp.NewWithChannel(broker), time.Second)
+		for _, body := range []string{"1", "2"} {
+			d, err := client.Call(context.Background(), "echo", amqp.Publishing{Body: []byte(body)})
+			assert.NoError(t, err)
+			assert.Equal(t, "echo "+body, string(d.Body))
+		}
+	})
+
+	t.Run("error when timeout", func(t *testing.T) {
+		broker := memory.New()
+		defer broker.Close()
+
+		_, err := broker.QueueDeclare("nobody", true, false, false, false, nil)
+		assert.NoError(t, err)
+
+		client := pkgamqp.NewRPCClient(tracing.NewNoop(), pkgamqp.NewWithChannel(broker), 10*time.Millisecond)
+		_, err = client.Call(context.Background(), "nobody", amqp.Publishing{})
+		assert.ErrorIs(t, err, pkgamqp.ErrRPCTimeout)
+	})
+
+	t.Run("success when request expires with the deadline", func(t *testing.T) {
+		broker := memory.New()
+		defer broker.Close()
+
+		_, err := broker.QueueDeclare("echo", true, false, false, false, nil)
+		assert.NoError(t, err)
+		requests, err := broker.Consume("echo", "", true, false, false, false, nil)
+		assert.NoError(t, err)
+
+		expirations := make(chan string, 1)
+		go func() {
+			for d := range requests {
+				expirations <- d.Expiration
+				broker.Publish("", d.ReplyTo, false, false, amqp.Publishing{CorrelationId: d.CorrelationId})
+			}
+		}()
+
+		client := pkgamqp.NewRPCClient(tracing.NewNoop(), pkgamqp.NewWithChannel(broker), time.Second)
+		_, err = client.Call(context.Background(), "echo", amqp.Publishing{})
+		assert.NoError(t, err)
+
+		expiration, err := strconv.Atoi(<-expirations)
+		assert.NoError(t, err)
+		assert.True(t, expiration >= 1 && expiration <= 1000, expiration)
+	})
+
+	t.Run("error when deadline passed before publish", func(t *testing.T) {
+		broker := memory.New()
+		defer broker.Close()
+
+		_, err := broker.QueueDeclare("nobody", true, false, false, false, nil)
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		client := pkgamqp.NewRPCClient(tracing.NewNoop(), pkgamqp.NewWithChannel(broker), time.Second)
+		_, err = client.Call(ctx, "nobody", amqp.Publishing{})
+		assert.ErrorIs(t, err, pkgamqp.ErrRPCTimeout)
+		assert.Equal(t, 0, broker.QueueLength("nobody"))
+	})
+
+	t.Run("error when context cancelled", func(t *testing.T) {
+		broker := memory.New()
+		defer broker.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		client := pkgamqp.NewRPCClient(tracing.NewNoop(), pkgamqp.NewWithChannel(broker), time.Second)
+		_, err := client.Call(ctx, "nobody", amqp.Publishing{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
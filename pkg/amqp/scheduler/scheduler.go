@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	config "go-rengan/pkg/config"
+	logger "go-rengan/pkg/logger"
+	tracing "go-rengan/pkg/tracing"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrNotScheduled = errors.New("scheduled message not found or already released")
+
+// Message - message to publish to the exchange with the routing key. The scheduler keeps the publishing as is
+// and releases it on the amqp channel, so it delays any amqp message, an event as well as a request to a queue
+type Message struct {
+	Exchange   string
+	RoutingKey string
+	Publishing amqp.Publishing
+}
+
+// ScheduledMessage - message waiting in the store until it is due
+type ScheduledMessage struct {
+	ID        string
+	Message   *Message
+	DeliverAt time.Time
+}
+
+// Store - persistence of the scheduled messages
+type Store interface {
+	Insert(ctx context.Context, msg *ScheduledMessage) error
+	// Cancel removes a pending message, ErrNotScheduled when it is unknown or already released
+	Cancel(ctx context.Context, id string) error
+	// ClaimDue locks one due message for release, it returns nil when nothing is due
+	ClaimDue(ctx context.Context, now time.Time) (*ScheduledMessage, error)
+	// MarkReleased records that the claimed message was published
+	MarkReleased(ctx context.Context, id string) error
+}
+
+type Scheduler interface {
+	PublishAt(ctx context.Context, msg *Message, at time.Time) (string, error)
+	Cancel(ctx context.Context, id string) error
+	Run(ctx context.Context)
+}
+
+type SchedulerImpl struct {
+	logger   logger.Logger
+	tracing  tracing.Tracing
+	channel  pkgamqp.AMQP
+	store    Store
+	interval time.Duration
+}
+
+// New - make scheduler which releases due messages from the store to the amqp channel every scheduler interval
+func New(
+	cfg config.AMQPConfig,
+	logger logger.Logger,
+	tracing tracing.Tracing,
+	channel pkgamqp.AMQP,
+	store Store,
+) Scheduler {
+	return &SchedulerImpl{
+		logger:   logger,
+		tracing:  tracing,
+		channel:  channel,
+		store:    store,
+		interval: cfg.SchedulerInterval,
+	}
+}
+
+// PublishAt - schedule the message to be published at the given time, it returns the id to cancel it with
+func (s *SchedulerImpl) PublishAt(ctx context.Context, msg *Message, at time.Time) (string, error) {
+	ctx, span := s.tracing.Tracer("amqp").Start(ctx, fmt.Sprintf("AMQP - schedule - %s", msg.RoutingKey))
+	defer span.End()
+
+	// Inject the context in the headers, so the consumer continues the scheduling trace.
+	// The message of the caller is left as is, it may publish or schedule it again
+	headers := pkgamqp.InjectAMQPHeaders(ctx)
+	for k, v := range msg.Publishing.Headers {
+		headers[k] = v
+	}
+	copied := *msg
+	copied.Publishing.Headers = headers
+
+	scheduled := &ScheduledMessage{
+		ID:        uuid.NewString(),
+		Message:   &copied,
+		DeliverAt: at.UTC(),
+	}
+
+	err := s.store.Insert(ctx, scheduled)
+	if err != nil {
+		s.tracing.LogError(span, err)
+		return "", err
+	}
+
+	return scheduled.ID, nil
+}
+
+// Cancel - cancel the scheduled message before it is released
+func (s *SchedulerImpl) Cancel(ctx context.Context, id string) error {
+	return s.store.Cancel(ctx, id)
+}
+
+// Run - release due messages until ctx is done
+func (s *SchedulerImpl) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.releaseDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseDue - publish every message which is due now
+func (s *SchedulerImpl) releaseDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		scheduled, err := s.store.ClaimDue(ctx, time.Now().UTC())
+		if err != nil {
+			s.logger.Error(ctx, "scheduler claim failed", "error", err)
+			return
+		}
+
+		if scheduled == nil {
+			return
+		}
+
+		err = s.release(ctx, scheduled)
+		if err != nil {
+			// The claim expires and the message is released again later
+			s.logger.Error(ctx, "scheduler release failed", "scheduled_id", scheduled.ID, "error", err)
+			return
+		}
+	}
+}
+
+func (s *SchedulerImpl) release(ctx context.Context, scheduled *ScheduledMessage) error {
+	msg := scheduled.Message
+	ctx = pkgamqp.ExtractAMQPHeaders(ctx, msg.Publishing.Headers)
+
+	tr := s.tracing.Tracer("amqp")
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindProducer),
+	}
+	ctx, span := tr.Start(ctx, fmt.Sprintf("AMQP - publish - %s", msg.RoutingKey), opts...)
+	defer span.End()
+
+	// The publish span, child of the scheduling trace, becomes the parent of the consumer
+	publishing := msg.Publishing
+	publishing.Headers = amqp.Table{}
+	for k, v := range msg.Publishing.Headers {
+		publishing.Headers[k] = v
+	}
+	for k, v := range pkgamqp.InjectAMQPHeaders(ctx) {
+		publishing.Headers[k] = v
+	}
+
+	err := s.channel.Get().Publish(msg.Exchange, msg.RoutingKey, false, false, publishing)
+	if err != nil {
+		s.tracing.LogError(span, err)
+		return err
+	}
+
+	err = s.store.MarkReleased(ctx, scheduled.ID)
+	if err != nil {
+		s.tracing.LogError(span, err)
+		return err
+	}
+	s.logger.Info(ctx, "scheduled message released", "scheduled_id", scheduled.ID, "routing_key", msg.RoutingKey)
+
+	return nil
+}
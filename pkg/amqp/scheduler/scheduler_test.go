@@ -0,0 +1,205 @@
+package scheduler_test
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	"go-rengan/pkg/amqp/memory"
+	"go-rengan/pkg/amqp/scheduler"
+	config "go-rengan/pkg/config"
+	logger "go-rengan/pkg/logger"
+	tracing "go-rengan/pkg/tracing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var appConfig = config.AppConfig{ID: 1, Name: "go-rengan"}
+
+// fakeStore - in memory store of the scheduled messages
+type fakeStore struct {
+	mu       sync.Mutex
+	pending  map[string]*scheduler.ScheduledMessage
+	released []string
+}
+
+func (f *fakeStore) Insert(ctx context.Context, msg *scheduler.ScheduledMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pending[msg.ID] = msg
+	return nil
+}
+
+func (f *fakeStore) Cancel(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.pending[id]; !ok {
+		return scheduler.ErrNotScheduled
+	}
+	delete(f.pending, id)
+	return nil
+}
+
+func (f *fakeStore) ClaimDue(ctx context.Context, now time.Time) (*scheduler.ScheduledMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	due := []*scheduler.ScheduledMessage{}
+	for _, msg := range f.pending {
+		if !msg.DeliverAt.After(now) {
+			due = append(due, msg)
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].DeliverAt.Before(due[j].DeliverAt) })
+	delete(f.pending, due[0].ID)
+	return due[0], nil
+}
+
+func (f *fakeStore) MarkReleased(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.released = append(f.released, id)
+	return nil
+}
+
+// startScheduler - run the scheduler against a memory broker until the test ends
+func startScheduler(t *testing.T) (tracing.Tracing, scheduler.Scheduler, <-chan amqp.Delivery) {
+	tracing := tracing.NewNoop()
+
+	broker := memory.New()
+	q, err := broker.QueueDeclare("reminders", false, false, false, false, nil)
+	assert.NoError(t, err)
+	msgs, err := broker.Consume(q.Name, "", true, false, false, false, nil)
+	assert.NoError(t, err)
+
+	store := &fakeStore{pending: map[string]*scheduler.ScheduledMessage{}}
+	s := scheduler.New(config.AMQPConfig{SchedulerInterval: 10 * time.Millisecond}, logger.NewNop(), tracing, pkgamqp.NewWithChannel(broker), store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		broker.Close()
+	})
+
+	return tracing, s, msgs
+}
+
+func TestPublishAt(t *testing.T) {
+	t.Run("success when message is due", func(t *testing.T) {
+		tracing, s, msgs := startScheduler(t)
+
+		ctx, span := tracing.Tracer("test").Start(context.Background(), "test")
+		defer span.End()
+
+		_, err := s.PublishAt(ctx, &scheduler.Message{
+			RoutingKey: "reminders",
+			Publishing: amqp.Publishing{Headers: amqp.Table{"x-kind": "reminder"}, Body: []byte("later")},
+		}, time.Now().Add(50*time.Millisecond))
+		assert.NoError(t, err)
+
+		select {
+		case d := <-msgs:
+			assert.Equal(t, "later", string(d.Body))
+			assert.Equal(t, "reminder", d.Headers["x-kind"])
+
+			// The consumer continues the trace which scheduled the message
+			consumerCtx := pkgamqp.ExtractAMQPHeaders(context.Background(), d.Headers)
+			assert.Equal(t, span.SpanContext().TraceID(), trace.SpanContextFromContext(consumerCtx).TraceID())
+		case <-time.After(time.Second):
+			assert.Fail(t, "scheduled message was not published")
+		}
+	})
+
+	t.Run("success when headers of the caller are left as is", func(t *testing.T) {
+		tracing, s, msgs := startScheduler(t)
+
+		ctx, span := tracing.Tracer("test").Start(context.Background(), "test")
+		defer span.End()
+
+		headers := amqp.Table{"x-kind": "reminder"}
+		msg := &scheduler.Message{RoutingKey: "reminders", Publishing: amqp.Publishing{Headers: headers, Body: []byte("later")}}
+		_, err := s.PublishAt(ctx, msg, time.Now())
+		assert.NoError(t, err)
+
+		select {
+		case <-msgs:
+		case <-time.After(time.Second):
+			assert.Fail(t, "scheduled message was not published")
+		}
+
+		assert.Equal(t, amqp.Table{"x-kind": "reminder"}, headers)
+		assert.Equal(t, amqp.Table{"x-kind": "reminder"}, msg.Publishing.Headers)
+	})
+
+	t.Run("success when messages are published in order", func(t *testing.T) {
+		_, s, msgs := startScheduler(t)
+
+		at := time.Now().Add(50 * time.Millisecond)
+		scheduled := map[string]time.Time{
+			"second": at,
+			"first":  at.Add(-10 * time.Millisecond),
+		}
+		for body, deliverAt := range scheduled {
+			_, err := s.PublishAt(context.Background(), &scheduler.Message{
+				RoutingKey: "reminders",
+				Publishing: amqp.Publishing{Body: []byte(body)},
+			}, deliverAt)
+			assert.NoError(t, err)
+		}
+
+		for _, expected := range []string{"first", "second"} {
+			select {
+			case d := <-msgs:
+				assert.Equal(t, expected, string(d.Body))
+			case <-time.After(time.Second):
+				assert.Fail(t, "scheduled message was not published")
+			}
+		}
+	})
+}
+
+func TestCancel(t *testing.T) {
+	t.Run("success when message is not due", func(t *testing.T) {
+		_, s, msgs := startScheduler(t)
+
+		id, err := s.PublishAt(context.Background(), &scheduler.Message{
+			RoutingKey: "reminders",
+			Publishing: amqp.Publishing{Body: []byte("later")},
+		}, time.Now().Add(50*time.Millisecond))
+		assert.NoError(t, err)
+
+		assert.NoError(t, s.Cancel(context.Background(), id))
+
+		select {
+		case <-msgs:
+			assert.Fail(t, "cancelled message was published")
+		case <-time.After(150 * time.Millisecond):
+		}
+	})
+
+	t.Run("error when message is unknown", func(t *testing.T) {
+		_, s, _ := startScheduler(t)
+
+		err := s.Cancel(context.Background(), "unknown")
+
+		assert.ErrorIs(t, err, scheduler.ErrNotScheduled)
+	})
+}
@@ -0,0 +1,240 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	mongodb "go-rengan/pkg/mongodb"
+
+	"github.com/streadway/amqp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultClaimTimeout - how long a claimed message is locked before another release attempt
+const DefaultClaimTimeout = time.Minute
+
+// releasedRetention - how long released and cancelled messages are kept
+const releasedRetention = 7 * 24 * time.Hour
+
+const collectionName = "scheduled_messages"
+
+// Status of the scheduled messages
+const (
+	StatusPending   = "pending"
+	StatusClaimed   = "claimed"
+	StatusReleased  = "released"
+	StatusCancelled = "cancelled"
+)
+
+type MongoStore struct {
+	mongoDB mongodb.MongoDB
+}
+
+// scheduledMessage - scheduled message document
+type scheduledMessage struct {
+	ID           string     `bson:"_id"`
+	Status       string     `bson:"status"`
+	DeliverAt    time.Time  `bson:"deliverAt"`
+	ClaimedUntil time.Time  `bson:"claimedUntil,omitempty"`
+	FinishedAt   *time.Time `bson:"finishedAt,omitempty"`
+	Exchange     string     `bson:"exchange"`
+	RoutingKey   string     `bson:"routingKey"`
+	Publishing   publishing `bson:"publishing"`
+}
+
+// publishing - stored amqp.Publishing properties
+type publishing struct {
+	Headers         map[string]interface{} `bson:"headers,omitempty"`
+	ContentType     string                 `bson:"contentType,omitempty"`
+	ContentEncoding string                 `bson:"contentEncoding,omitempty"`
+	DeliveryMode    uint8                  `bson:"deliveryMode,omitempty"`
+	Priority        uint8                  `bson:"priority,omitempty"`
+	CorrelationID   string                 `bson:"correlationId,omitempty"`
+	ReplyTo         string                 `bson:"replyTo,omitempty"`
+	Expiration      string                 `bson:"expiration,omitempty"`
+	MessageID       string                 `bson:"messageId,omitempty"`
+	Timestamp       time.Time              `bson:"timestamp,omitempty"`
+	Type            string                 `bson:"type,omitempty"`
+	AppID           string                 `bson:"appId,omitempty"`
+	Body            []byte                 `bson:"body"`
+}
+
+// NewMongoStore - make mongo store and ensure the indexes of the scheduled messages collection
+func NewMongoStore(mongoDB mongodb.MongoDB) (Store, error) {
+	s := &MongoStore{
+		mongoDB: mongoDB,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "deliverAt", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "finishedAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(releasedRetention.Seconds())),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Insert - insert pending message
+func (s *MongoStore) Insert(ctx context.Context, msg *ScheduledMessage) error {
+	p := msg.Message.Publishing
+	_, err := s.collection().InsertOne(ctx, &scheduledMessage{
+		ID:         msg.ID,
+		Status:     StatusPending,
+		DeliverAt:  msg.DeliverAt,
+		Exchange:   msg.Message.Exchange,
+		RoutingKey: msg.Message.RoutingKey,
+		Publishing: publishing{
+			Headers:         p.Headers,
+			ContentType:     p.ContentType,
+			ContentEncoding: p.ContentEncoding,
+			DeliveryMode:    p.DeliveryMode,
+			Priority:        p.Priority,
+			CorrelationID:   p.CorrelationId,
+			ReplyTo:         p.ReplyTo,
+			Expiration:      p.Expiration,
+			MessageID:       p.MessageId,
+			Timestamp:       p.Timestamp,
+			Type:            p.Type,
+			AppID:           p.AppId,
+			Body:            p.Body,
+		},
+	})
+
+	return err
+}
+
+// Cancel - cancel pending message
+func (s *MongoStore) Cancel(ctx context.Context, id string) error {
+	now := time.Now().UTC()
+	result, err := s.collection().UpdateOne(ctx,
+		bson.M{"_id": id, "status": StatusPending},
+		bson.M{"$set": bson.M{"status": StatusCancelled, "finishedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount <= 0 {
+		return ErrNotScheduled
+	}
+
+	return nil
+}
+
+// ClaimDue - atomically claim a due pending message, or a claimed one whose release did not finish in time
+func (s *MongoStore) ClaimDue(ctx context.Context, now time.Time) (*ScheduledMessage, error) {
+	filter := bson.M{
+		"deliverAt": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"status": StatusPending},
+			bson.M{"status": StatusClaimed, "claimedUntil": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"status": StatusClaimed, "claimedUntil": now.Add(DefaultClaimTimeout)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "deliverAt", Value: 1}}).SetReturnDocument(options.After)
+
+	doc := &scheduledMessage{}
+	err := s.collection().FindOneAndUpdate(ctx, filter, update, opts).Decode(doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	p := doc.Publishing
+	return &ScheduledMessage{
+		ID:        doc.ID,
+		DeliverAt: doc.DeliverAt,
+		Message: &Message{
+			Exchange:   doc.Exchange,
+			RoutingKey: doc.RoutingKey,
+			Publishing: amqp.Publishing{
+				Headers:         headers(p.Headers),
+				ContentType:     p.ContentType,
+				ContentEncoding: p.ContentEncoding,
+				DeliveryMode:    p.DeliveryMode,
+				Priority:        p.Priority,
+				CorrelationId:   p.CorrelationID,
+				ReplyTo:         p.ReplyTo,
+				Expiration:      p.Expiration,
+				MessageId:       p.MessageID,
+				Timestamp:       p.Timestamp,
+				Type:            p.Type,
+				AppId:           p.AppID,
+				Body:            p.Body,
+			},
+		},
+	}, nil
+}
+
+// MarkReleased - mark claimed message as released
+func (s *MongoStore) MarkReleased(ctx context.Context, id string) error {
+	now := time.Now().UTC()
+	_, err := s.collection().UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"status": StatusReleased, "finishedAt": now}},
+	)
+
+	return err
+}
+
+// headers - amqp table of the stored headers. The nested documents, arrays, dates and binaries are decoded
+// to bson types, which the amqp channel refuses to publish
+func headers(stored map[string]interface{}) amqp.Table {
+	if stored == nil {
+		return nil
+	}
+
+	table := amqp.Table{}
+	for k, v := range stored {
+		table[k] = headerValue(v)
+	}
+
+	return table
+}
+
+func headerValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case primitive.D:
+		table := amqp.Table{}
+		for _, e := range v {
+			table[e.Key] = headerValue(e.Value)
+		}
+		return table
+	case primitive.M:
+		return headers(v)
+	case map[string]interface{}:
+		return headers(v)
+	case primitive.A:
+		return headerValue([]interface{}(v))
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, value := range v {
+			values[i] = headerValue(value)
+		}
+		return values
+	case primitive.DateTime:
+		return v.Time().UTC()
+	case primitive.Binary:
+		return v.Data
+	default:
+		return v
+	}
+}
+
+func (s *MongoStore) collection() *mongo.Collection {
+	return s.mongoDB.Database().Collection(collectionName)
+}
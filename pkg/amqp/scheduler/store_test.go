@@ -0,0 +1,173 @@
+package scheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-rengan/pkg/amqp/scheduler"
+	logger "go-rengan/pkg/logger"
+	mongodb "go-rengan/pkg/mongodb"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func newMongoStore(mt *mtest.T) scheduler.Store {
+	mt.AddMockResponses(mtest.CreateSuccessResponse())
+	store, err := scheduler.NewMongoStore(mongodb.NewWithClient(mt.Client, mt.DB.Name(), logger.NewNop()))
+	assert.NoError(mt, err)
+	mt.ClearEvents()
+
+	return store
+}
+
+func TestMongoStoreClaimDue(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	now := time.Date(2022, 8, 1, 9, 0, 0, 0, time.UTC)
+
+	mt.Run("success claim due message", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+			{Key: "_id", Value: "1"},
+			{Key: "status", Value: scheduler.StatusClaimed},
+			{Key: "deliverAt", Value: now.Add(-time.Second)},
+			{Key: "exchange", Value: "todo.events"},
+			{Key: "routingKey", Value: "todo.reminder"},
+			{Key: "publishing", Value: bson.D{
+				{Key: "headers", Value: bson.D{{Key: "x-user-id", Value: "user-1"}}},
+				{Key: "contentType", Value: "application/json"},
+				{Key: "body", Value: []byte(`{"id":"1"}`)},
+			}},
+		}}))
+
+		msg, err := store.ClaimDue(context.Background(), now)
+
+		assert.NoError(mt, err)
+		assert.Equal(mt, "1", msg.ID)
+		assert.Equal(mt, "todo.events", msg.Message.Exchange)
+		assert.Equal(mt, "todo.reminder", msg.Message.RoutingKey)
+		assert.Equal(mt, "application/json", msg.Message.Publishing.ContentType)
+		assert.Equal(mt, "user-1", msg.Message.Publishing.Headers["x-user-id"])
+		assert.Equal(mt, `{"id":"1"}`, string(msg.Message.Publishing.Body))
+	})
+
+	mt.Run("success claim message with nested headers as stored", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		at := time.Date(2022, 8, 1, 8, 0, 0, 0, time.UTC)
+		sent := amqp.Table{
+			"x-user-id": "user-1",
+			"x-death": []interface{}{
+				amqp.Table{"queue": "todo.reminders", "count": int64(2), "time": at, "routing-keys": []interface{}{"todo.reminder"}},
+			},
+			"x-retry": amqp.Table{"attempt": int32(1), "payload": []byte("1")},
+		}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		err := store.Insert(context.Background(), &scheduler.ScheduledMessage{
+			ID:        "1",
+			DeliverAt: now.Add(-time.Second),
+			Message:   &scheduler.Message{Exchange: "todo.events", RoutingKey: "todo.reminder", Publishing: amqp.Publishing{Headers: sent}},
+		})
+		assert.NoError(mt, err)
+		stored := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: stored}))
+		msg, err := store.ClaimDue(context.Background(), now)
+
+		assert.NoError(mt, err)
+		assert.Equal(mt, sent, msg.Message.Publishing.Headers)
+		assert.NoError(mt, msg.Message.Publishing.Headers.Validate())
+	})
+
+	mt.Run("success claim pending message or claim which timed out", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+
+		_, err := store.ClaimDue(context.Background(), now)
+
+		assert.NoError(mt, err)
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(mt, now, cmd.Lookup("query", "deliverAt", "$lte").Time().UTC())
+
+		or := cmd.Lookup("query", "$or").Array()
+		assert.Equal(mt, scheduler.StatusPending, or.Index(0).Value().Document().Lookup("status").StringValue())
+		claimed := or.Index(1).Value().Document()
+		assert.Equal(mt, scheduler.StatusClaimed, claimed.Lookup("status").StringValue())
+		assert.Equal(mt, now, claimed.Lookup("claimedUntil", "$lt").Time().UTC())
+
+		// The claim holds the message for the claim timeout, the oldest due message first
+		assert.Equal(mt, scheduler.StatusClaimed, cmd.Lookup("update", "$set", "status").StringValue())
+		assert.Equal(mt, now.Add(scheduler.DefaultClaimTimeout), cmd.Lookup("update", "$set", "claimedUntil").Time().UTC())
+		assert.Equal(mt, int32(1), cmd.Lookup("sort", "deliverAt").Int32())
+		assert.True(mt, cmd.Lookup("new").Boolean())
+	})
+
+	mt.Run("success nil when nothing is due", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+
+		msg, err := store.ClaimDue(context.Background(), now)
+
+		assert.NoError(mt, err)
+		assert.Nil(mt, msg)
+	})
+
+	mt.Run("error when query fails", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Name: "BadValue", Message: "bad value"}))
+
+		msg, err := store.ClaimDue(context.Background(), now)
+
+		assert.Error(mt, err)
+		assert.Nil(mt, msg)
+	})
+}
+
+func TestMongoStoreCancel(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("success cancel pending message", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := store.Cancel(context.Background(), "1")
+
+		assert.NoError(mt, err)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(mt, scheduler.StatusPending, update.Lookup("q", "status").StringValue())
+		assert.Equal(mt, scheduler.StatusCancelled, update.Lookup("u", "$set", "status").StringValue())
+	})
+
+	mt.Run("error when message is claimed or released", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+
+		err := store.Cancel(context.Background(), "1")
+
+		assert.ErrorIs(mt, err, scheduler.ErrNotScheduled)
+	})
+}
+
+func TestMongoStoreMarkReleased(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("success mark message released", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := store.MarkReleased(context.Background(), "1")
+
+		assert.NoError(mt, err)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(mt, scheduler.StatusReleased, update.Lookup("u", "$set", "status").StringValue())
+		_, err = update.LookupErr("u", "$set", "finishedAt")
+		assert.NoError(mt, err)
+	})
+}
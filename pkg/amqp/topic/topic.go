@@ -0,0 +1,29 @@
+package topic
+
+import "strings"
+
+// Match - whether the routing key matches the binding pattern of an amqp topic exchange,
+// the words are separated by dots, "*" matches exactly one word and "#" matches zero or more words
+func Match(pattern string, routingKey string) bool {
+	return match(strings.Split(pattern, "."), strings.Split(routingKey, "."))
+}
+
+func match(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if match(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && match(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && match(pattern[1:], words[1:])
+	}
+}
@@ -0,0 +1,33 @@
+package topic_test
+
+import (
+	"testing"
+
+	"go-rengan/pkg/amqp/topic"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern    string
+		routingKey string
+		match      bool
+	}{
+		{"todo.created", "todo.created", true},
+		{"todo.created", "todo.deleted", false},
+		{"todo.*", "todo.created", true},
+		{"todo.*", "todo.created.v2", false},
+		{"todo.*", "todo", false},
+		{"todo.#", "todo", true},
+		{"todo.#", "todo.created.v2", true},
+		{"#", "todo.created", true},
+		{"#.created", "todo.created", true},
+		{"*.created", "user.todo.created", false},
+		{"#.created", "user.todo.created", true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.match, topic.Match(c.pattern, c.routingKey), c.pattern+" "+c.routingKey)
+	}
+}
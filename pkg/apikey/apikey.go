@@ -0,0 +1,161 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	auth "go-rengan/pkg/auth"
+	logger "go-rengan/pkg/logger"
+	tenant "go-rengan/pkg/tenant"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// tokenPrefix - prefix of the tokens, it tells an api key apart from other secrets in logs and scanners
+const tokenPrefix = "grk_"
+
+// lastUsedInterval - the last use of a key is recorded at most once per interval
+const lastUsedInterval = time.Minute
+
+var (
+	// ErrNotFound - the key is unknown, or belongs to another user
+	ErrNotFound = errors.New("api key not found")
+	ErrRevoked  = errors.New("api key revoked")
+	ErrExpired  = errors.New("api key expired")
+	// ErrNoScopes - a key without scopes would not be restricted
+	ErrNoScopes = errors.New("api key needs at least one scope")
+)
+
+// Key - api key of a user, only the hash of its token is stored
+type Key struct {
+	ID   primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name string             `json:"name" bson:"name"`
+	// OwnerID - the user the key acts for
+	OwnerID string `json:"owner_id" bson:"ownerId"`
+	// TenantID - the tenant the key acts in, empty without tenancy
+	TenantID string `json:"tenant_id,omitempty" bson:"tenantId,omitempty"`
+	// Prefix - start of the token, to recognize the key
+	Prefix     string     `json:"prefix" bson:"prefix"`
+	Hash       string     `json:"-" bson:"hash"`
+	Scopes     []string   `json:"scopes" bson:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at" bson:"expiresAt"`
+	CreatedAt  time.Time  `json:"created_at" bson:"createdAt"`
+	LastUsedAt *time.Time `json:"last_used_at" bson:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" bson:"revokedAt,omitempty"`
+}
+
+// Store - storage of the api keys
+type Store interface {
+	Insert(ctx context.Context, key *Key) error
+	// FindByHash returns ErrNotFound when no key has the hash
+	FindByHash(ctx context.Context, hash string) (*Key, error)
+	// List returns the keys of the owner in the tenant, in every tenant when tenantID is empty
+	List(ctx context.Context, tenantID string, ownerID string) ([]*Key, error)
+	// Revoke returns ErrNotFound when the owner has no such key in the tenant which is not revoked
+	Revoke(ctx context.Context, tenantID string, ownerID string, id string, at time.Time) error
+	// Touch records the last use of the key
+	Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+// Keys - create, list and revoke the api keys of the users, and authenticate their tokens
+type Keys interface {
+	auth.Authenticator
+	// Create stores the key and returns its token, the token cannot be read again
+	Create(ctx context.Context, key *Key) (string, error)
+	// List returns the keys of the owner in the tenant of ctx
+	List(ctx context.Context, ownerID string) ([]*Key, error)
+	// Revoke revokes a key of the owner in the tenant of ctx
+	Revoke(ctx context.Context, ownerID string, id string) error
+}
+
+type KeysImpl struct {
+	logger logger.Logger
+	store  Store
+	now    func() time.Time
+}
+
+// New - make api keys stored in store
+func New(logger logger.Logger, store Store) Keys {
+	return &KeysImpl{
+		logger: logger,
+		store:  store,
+		now:    time.Now,
+	}
+}
+
+// hash - hash of the token, the token is random so a fast hash does not weaken it
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (k *KeysImpl) Create(ctx context.Context, key *Key) (string, error) {
+	if len(key.Scopes) == 0 {
+		return "", ErrNoScopes
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key.ID = primitive.NewObjectID()
+	key.Prefix = token[:len(tokenPrefix)+6]
+	key.Hash = hash(token)
+	key.CreatedAt = k.now().UTC()
+	key.LastUsedAt = nil
+	key.RevokedAt = nil
+	if err := k.store.Insert(ctx, key); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// List - keys of the owner, the same user in another tenant does not see them
+func (k *KeysImpl) List(ctx context.Context, ownerID string) ([]*Key, error) {
+	tenantID, _ := tenant.FromContext(ctx)
+	return k.store.List(ctx, tenantID, ownerID)
+}
+
+// Revoke - revoke a key of the owner, the same user in another tenant cannot revoke it
+func (k *KeysImpl) Revoke(ctx context.Context, ownerID string, id string) error {
+	tenantID, _ := tenant.FromContext(ctx)
+	return k.store.Revoke(ctx, tenantID, ownerID, id, k.now().UTC())
+}
+
+// Authenticate - principal of the owner of the key, with the scopes of the key
+func (k *KeysImpl) Authenticate(ctx context.Context, token string) (*auth.Principal, error) {
+	key, err := k.store.FindByHash(ctx, hash(token))
+	if err != nil {
+		return nil, err
+	}
+
+	now := k.now().UTC()
+	if key.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+	if !now.Before(key.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedInterval {
+		if err := k.store.Touch(ctx, key.ID, now); err != nil {
+			k.logger.Warn(ctx, "api key last use not recorded", "api_key", key.ID.Hex(), "error", err)
+		}
+	}
+
+	return &auth.Principal{
+		Subject:  key.OwnerID,
+		Scopes:   key.Scopes,
+		APIKeyID: key.ID.Hex(),
+		Tenant:   key.TenantID,
+	}, nil
+}
@@ -0,0 +1,213 @@
+package apikey_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-rengan/pkg/apikey"
+	logger "go-rengan/pkg/logger"
+	tenant "go-rengan/pkg/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeStore - in memory store of the api keys
+type fakeStore struct {
+	mu      sync.Mutex
+	keys    []*apikey.Key
+	touched []primitive.ObjectID
+}
+
+func (f *fakeStore) Insert(ctx context.Context, key *apikey.Key) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.keys = append(f.keys, key)
+	return nil
+}
+
+func (f *fakeStore) FindByHash(ctx context.Context, hash string) (*apikey.Key, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range f.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return nil, apikey.ErrNotFound
+}
+
+func (f *fakeStore) List(ctx context.Context, tenantID string, ownerID string) ([]*apikey.Key, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := []*apikey.Key{}
+	for _, key := range f.keys {
+		if key.OwnerID == ownerID && (tenantID == "" || key.TenantID == tenantID) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeStore) Revoke(ctx context.Context, tenantID string, ownerID string, id string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range f.keys {
+		if key.ID.Hex() == id && key.OwnerID == ownerID && (tenantID == "" || key.TenantID == tenantID) && key.RevokedAt == nil {
+			key.RevokedAt = &at
+			return nil
+		}
+	}
+	return apikey.ErrNotFound
+}
+
+func (f *fakeStore) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.touched = append(f.touched, id)
+	for _, key := range f.keys {
+		if key.ID == id {
+			key.LastUsedAt = &at
+		}
+	}
+	return nil
+}
+
+func newKey(scopes ...string) *apikey.Key {
+	return &apikey.Key{
+		Name:      "batch",
+		OwnerID:   "user-1",
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func TestCreate(t *testing.T) {
+	t.Run("success stores the hash of the token", func(t *testing.T) {
+		store := &fakeStore{}
+		keys := apikey.New(logger.NewNop(), store)
+
+		key := newKey("todo:read")
+		token, err := keys.Create(context.Background(), key)
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(token, "grk_"))
+		assert.True(t, strings.HasPrefix(token, key.Prefix))
+		assert.NotEmpty(t, key.Hash)
+		assert.NotContains(t, key.Hash, token[4:])
+		assert.False(t, key.ID.IsZero())
+		assert.Len(t, store.keys, 1)
+	})
+
+	t.Run("error when key has no scopes", func(t *testing.T) {
+		keys := apikey.New(logger.NewNop(), &fakeStore{})
+
+		_, err := keys.Create(context.Background(), newKey())
+
+		assert.ErrorIs(t, err, apikey.ErrNoScopes)
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Run("success with principal of the owner and scopes of the key", func(t *testing.T) {
+		store := &fakeStore{}
+		keys := apikey.New(logger.NewNop(), store)
+		key := newKey("todo:read")
+		token, _ := keys.Create(context.Background(), key)
+
+		principal, err := keys.Authenticate(context.Background(), token)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", principal.Subject)
+		assert.Equal(t, []string{"todo:read"}, principal.Scopes)
+		assert.Equal(t, key.ID.Hex(), principal.APIKeyID)
+		assert.True(t, principal.Allows("todo:read"))
+		assert.False(t, principal.Allows("todo:write"))
+		assert.Equal(t, []primitive.ObjectID{key.ID}, store.touched)
+	})
+
+	t.Run("success records the last use at most once a minute", func(t *testing.T) {
+		store := &fakeStore{}
+		keys := apikey.New(logger.NewNop(), store)
+		token, _ := keys.Create(context.Background(), newKey("todo:read"))
+
+		for i := 0; i < 3; i++ {
+			_, err := keys.Authenticate(context.Background(), token)
+			assert.NoError(t, err)
+		}
+
+		assert.Len(t, store.touched, 1)
+	})
+
+	t.Run("error when key is unknown, revoked or expired", func(t *testing.T) {
+		store := &fakeStore{}
+		keys := apikey.New(logger.NewNop(), store)
+
+		_, err := keys.Authenticate(context.Background(), "grk_unknown")
+		assert.ErrorIs(t, err, apikey.ErrNotFound)
+
+		revoked := newKey("todo:read")
+		token, _ := keys.Create(context.Background(), revoked)
+		assert.NoError(t, keys.Revoke(context.Background(), "user-1", revoked.ID.Hex()))
+		_, err = keys.Authenticate(context.Background(), token)
+		assert.ErrorIs(t, err, apikey.ErrRevoked)
+
+		expired := newKey("todo:read")
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		token, _ = keys.Create(context.Background(), expired)
+		_, err = keys.Authenticate(context.Background(), token)
+		assert.ErrorIs(t, err, apikey.ErrExpired)
+
+		assert.Empty(t, store.touched)
+	})
+}
+
+func TestRevoke(t *testing.T) {
+	t.Run("error when key belongs to another user", func(t *testing.T) {
+		keys := apikey.New(logger.NewNop(), &fakeStore{})
+		key := newKey("todo:read")
+		_, _ = keys.Create(context.Background(), key)
+
+		err := keys.Revoke(context.Background(), "user-2", key.ID.Hex())
+
+		assert.ErrorIs(t, err, apikey.ErrNotFound)
+	})
+
+	t.Run("error when key belongs to the user in another tenant", func(t *testing.T) {
+		keys := apikey.New(logger.NewNop(), &fakeStore{})
+		key := newKey("todo:read")
+		key.TenantID = "acme"
+		_, _ = keys.Create(context.Background(), key)
+
+		err := keys.Revoke(tenant.WithTenant(context.Background(), "globex"), "user-1", key.ID.Hex())
+
+		assert.ErrorIs(t, err, apikey.ErrNotFound)
+		assert.NoError(t, keys.Revoke(tenant.WithTenant(context.Background(), "acme"), "user-1", key.ID.Hex()))
+	})
+}
+
+func TestList(t *testing.T) {
+	t.Run("success with the keys of the tenant only", func(t *testing.T) {
+		keys := apikey.New(logger.NewNop(), &fakeStore{})
+		acme := newKey("todo:read")
+		acme.TenantID = "acme"
+		_, _ = keys.Create(context.Background(), acme)
+		globex := newKey("todo:read")
+		globex.TenantID = "globex"
+		_, _ = keys.Create(context.Background(), globex)
+
+		results, err := keys.List(tenant.WithTenant(context.Background(), "globex"), "user-1")
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, globex.ID, results[0].ID)
+	})
+}
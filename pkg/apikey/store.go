@@ -0,0 +1,111 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	mongodb "go-rengan/pkg/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionName = "api_keys"
+
+type MongoStore struct {
+	mongoDB mongodb.MongoDB
+}
+
+// NewMongoStore - make mongo store and ensure the indexes of the api keys collection
+func NewMongoStore(mongoDB mongodb.MongoDB) (Store, error) {
+	s := &MongoStore{
+		mongoDB: mongoDB,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.collection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "ownerId", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *MongoStore) Insert(ctx context.Context, key *Key) error {
+	_, err := s.collection().InsertOne(ctx, key)
+	return err
+}
+
+func (s *MongoStore) FindByHash(ctx context.Context, hash string) (*Key, error) {
+	key := &Key{}
+	err := s.collection().FindOne(ctx, bson.M{"hash": hash}).Decode(key)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (s *MongoStore) List(ctx context.Context, tenantID string, ownerID string) ([]*Key, error) {
+	cur, err := s.collection().Find(ctx, ownerFilter(tenantID, ownerID), options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*Key{}
+	if err := cur.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (s *MongoStore) Revoke(ctx context.Context, tenantID string, ownerID string, id string, at time.Time) error {
+	docID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	filter := ownerFilter(tenantID, ownerID)
+	filter["_id"] = docID
+	filter["revokedAt"] = bson.M{"$exists": false}
+	res, err := s.collection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revokedAt": at}})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *MongoStore) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := s.collection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastUsedAt": at}})
+	return err
+}
+
+// ownerFilter - keys of the owner in the tenant, the user ids are only unique within a tenant
+func ownerFilter(tenantID string, ownerID string) bson.M {
+	filter := bson.M{"ownerId": ownerID}
+	if tenantID != "" {
+		filter["tenantId"] = tenantID
+	}
+
+	return filter
+}
+
+func (s *MongoStore) collection() *mongo.Collection {
+	return s.mongoDB.Database().Collection(collectionName)
+}
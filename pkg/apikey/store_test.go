@@ -0,0 +1,64 @@
+package apikey_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-rengan/pkg/apikey"
+	logger "go-rengan/pkg/logger"
+	mongodb "go-rengan/pkg/mongodb"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func newMongoStore(mt *mtest.T) apikey.Store {
+	mt.AddMockResponses(mtest.CreateSuccessResponse())
+	store, err := apikey.NewMongoStore(mongodb.NewWithClient(mt.Client, mt.DB.Name(), logger.NewNop()))
+	assert.NoError(mt, err)
+	mt.ClearEvents()
+
+	return store
+}
+
+func TestMongoStore(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("success list filtered by tenant", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".api_keys", mtest.FirstBatch))
+
+		_, err := store.List(context.Background(), "acme", "user-1")
+
+		assert.NoError(mt, err)
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(mt, "acme", filter.Lookup("tenantId").StringValue())
+		assert.Equal(mt, "user-1", filter.Lookup("ownerId").StringValue())
+	})
+
+	mt.Run("success list without tenancy", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".api_keys", mtest.FirstBatch))
+
+		_, err := store.List(context.Background(), "", "user-1")
+
+		assert.NoError(mt, err)
+		_, err = mt.GetStartedEvent().Command.Lookup("filter").Document().LookupErr("tenantId")
+		assert.Error(mt, err)
+	})
+
+	mt.Run("error revoke of key in another tenant", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+
+		err := store.Revoke(context.Background(), "globex", "user-1", primitive.NewObjectID().Hex(), time.Now())
+
+		assert.ErrorIs(mt, err, apikey.ErrNotFound)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(mt, "globex", update.Lookup("q", "tenantId").StringValue())
+	})
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	config "go-rengan/pkg/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrNoKey - the token is signed with an algorithm or a key which is not configured
+var ErrNoKey = errors.New("no key to verify the token")
+
+// Authenticator - resolve the principal of a credential of the request
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+type JWTImpl struct {
+	secret []byte
+	keys   map[string]crypto.PublicKey
+	parser *jwt.Parser
+}
+
+// claims - registered claims, plus the roles, the space separated scope and the tenant of the user
+type claims struct {
+	jwt.RegisteredClaims
+	Roles  []string `json:"roles"`
+	Scope  string   `json:"scope"`
+	Tenant string   `json:"tenant"`
+}
+
+// New - make authenticator of HS256 tokens signed with the secret and RS256/ES256 tokens signed with a key of the JWKS file
+func New(cfg config.AuthConfig) (Authenticator, error) {
+	a := &JWTImpl{
+		secret: []byte(cfg.JWTSecret.Value()),
+		keys:   map[string]crypto.PublicKey{},
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(cfg.Leeway),
+		),
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+	}
+
+	return a, nil
+}
+
+// Authenticate - verify the signature, issuer, audience and expiry of the token
+func (a *JWTImpl) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	var c claims
+	if _, err := a.parser.ParseWithClaims(token, &c, a.key); err != nil {
+		return nil, err
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", jwt.ErrTokenInvalidClaims)
+	}
+
+	return &Principal{
+		Subject: c.Subject,
+		Roles:   c.Roles,
+		Scopes:  strings.Fields(c.Scope),
+		Tenant:  c.Tenant,
+	}, nil
+}
+
+// key - key of the token, it must match the algorithm so an RSA key is never used as an HMAC secret
+func (a *JWTImpl) key(token *jwt.Token) (interface{}, error) {
+	if token.Method == jwt.SigningMethodHS256 {
+		if len(a.secret) == 0 {
+			return nil, ErrNoKey
+		}
+		return a.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := a.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: kid %q", ErrNoKey, kid)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if token.Method == jwt.SigningMethodRS256 {
+			return k, nil
+		}
+	case *ecdsa.PublicKey:
+		if token.Method == jwt.SigningMethodES256 && k.Curve == elliptic.P256() {
+			return k, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: kid %q is not a %s key", ErrNoKey, kid, token.Method.Alg())
+}
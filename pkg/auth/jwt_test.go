@@ -0,0 +1,156 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// writeJWKS - JWKS file with the public keys by key id
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	set := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes())},
+	}}
+	content, err := json.Marshal(set)
+	assert.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(file, content, 0600))
+	return file
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":    "user-1",
+		"iss":    "https://auth.example.com",
+		"aud":    "go-rengan",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"roles":  []string{"admin"},
+		"scope":  "todo:read todo:write",
+		"tenant": "acme",
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func TestAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	cfg := config.AuthConfig{
+		JWTSecret: "secret",
+		JWKSFile:  writeJWKS(t, rsaKey, ecKey),
+		Issuer:    "https://auth.example.com",
+		Audience:  "go-rengan",
+		Leeway:    time.Second,
+	}
+	authenticator, err := auth.New(cfg)
+	assert.NoError(t, err)
+
+	t.Run("success when token is valid", func(t *testing.T) {
+		tokens := map[string]string{
+			"HS256": sign(t, jwt.SigningMethodHS256, "", []byte("secret"), validClaims()),
+			"RS256": sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, validClaims()),
+			"ES256": sign(t, jwt.SigningMethodES256, "ec", ecKey, validClaims()),
+		}
+
+		for alg, token := range tokens {
+			principal, err := authenticator.Authenticate(context.Background(), token)
+
+			assert.NoError(t, err, alg)
+			assert.Equal(t, &auth.Principal{
+				Subject: "user-1",
+				Roles:   []string{"admin"},
+				Scopes:  []string{"todo:read", "todo:write"},
+				Tenant:  "acme",
+			}, principal, alg)
+		}
+	})
+
+	t.Run("error when token is invalid", func(t *testing.T) {
+		expired := validClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		wrongIssuer := validClaims()
+		wrongIssuer["iss"] = "https://evil.example.com"
+		wrongAudience := validClaims()
+		wrongAudience["aud"] = "other"
+		noExpiry := validClaims()
+		delete(noExpiry, "exp")
+		noSubject := validClaims()
+		delete(noSubject, "sub")
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+
+		tokens := map[string]string{
+			"expired":                    sign(t, jwt.SigningMethodHS256, "", []byte("secret"), expired),
+			"wrong issuer":               sign(t, jwt.SigningMethodHS256, "", []byte("secret"), wrongIssuer),
+			"wrong audience":             sign(t, jwt.SigningMethodHS256, "", []byte("secret"), wrongAudience),
+			"no expiry":                  sign(t, jwt.SigningMethodHS256, "", []byte("secret"), noExpiry),
+			"no subject":                 sign(t, jwt.SigningMethodHS256, "", []byte("secret"), noSubject),
+			"wrong secret":               sign(t, jwt.SigningMethodHS256, "", []byte("other"), validClaims()),
+			"wrong key":                  sign(t, jwt.SigningMethodRS256, "rsa", otherKey, validClaims()),
+			"unknown kid":                sign(t, jwt.SigningMethodRS256, "unknown", rsaKey, validClaims()),
+			"key of the other algorithm": sign(t, jwt.SigningMethodRS256, "ec", rsaKey, validClaims()),
+			"algorithm not allowed":      sign(t, jwt.SigningMethodHS512, "", []byte("secret"), validClaims()),
+			"not a jwt":                  "token",
+		}
+
+		for name, token := range tokens {
+			_, err := authenticator.Authenticate(context.Background(), token)
+
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("error when hs256 has no secret", func(t *testing.T) {
+		withoutSecret := cfg
+		withoutSecret.JWTSecret = ""
+		authenticator, err := auth.New(withoutSecret)
+		assert.NoError(t, err)
+
+		_, err = authenticator.Authenticate(context.Background(), sign(t, jwt.SigningMethodHS256, "", []byte(""), validClaims()))
+
+		assert.ErrorIs(t, err, auth.ErrNoKey)
+	})
+
+	t.Run("error when jwks file is invalid", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "jwks.json")
+		assert.NoError(t, os.WriteFile(file, []byte(`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`), 0600))
+		invalid := cfg
+		invalid.JWKSFile = file
+
+		_, err := auth.New(invalid)
+
+		assert.ErrorContains(t, err, `unsupported key type "oct"`)
+	})
+}
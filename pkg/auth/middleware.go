@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	responseutil "go-rengan/utils/response"
+)
+
+// RequireScope - reject the requests of the principals which are not allowed the scope
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := FromContext(r.Context())
+			if !ok {
+				responseutil.Unauthorized(w, r, "Missing bearer token")
+				return
+			}
+
+			if !principal.Allows(scope) {
+				responseutil.Forbidden(w, r, fmt.Sprintf("Missing scope %s", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole - reject the requests of the principals without the role
+func RequireRole(role string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := FromContext(r.Context())
+			if !ok {
+				responseutil.Unauthorized(w, r, "Missing bearer token")
+				return
+			}
+
+			if !principal.HasRole(role) {
+				responseutil.Forbidden(w, r, fmt.Sprintf("Missing role %s", role))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnauthenticated - the request has no principal
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// RoleAdmin - role of the users who manage every resource
+const RoleAdmin = "admin"
+
+// Principal - authenticated caller of the request
+type Principal struct {
+	// Subject - id of the user, the sub claim of the token
+	Subject string
+	Roles   []string
+	// Scopes - what the principal may do, a principal without scopes is not restricted by them
+	Scopes []string
+	// APIKeyID - id of the api key the principal authenticated with, empty for a user token
+	APIKeyID string
+	// Tenant - tenant the principal is bound to, empty when its token names none
+	Tenant string
+}
+
+// HasRole - whether the principal has the role
+func (p *Principal) HasRole(role string) bool {
+	return contains(p.Roles, role)
+}
+
+// HasScope - whether the principal was granted the scope
+func (p *Principal) HasScope(scope string) bool {
+	return contains(p.Scopes, scope)
+}
+
+// Allows - whether the principal may use the scope
+func (p *Principal) Allows(scope string) bool {
+	return len(p.Scopes) == 0 || p.HasScope(scope)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal - ctx which carries the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext - principal of the request, false when the request is not authenticated
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
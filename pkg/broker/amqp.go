@@ -0,0 +1,226 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	logger "go-rengan/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+)
+
+// AMQPBroker - RabbitMQ backend, a topic is a topic exchange, a group is a queue bound to the exchange with the types
+type AMQPBroker struct {
+	logger  logger.Logger
+	channel pkgamqp.AMQP
+	// mu keeps the prefetch of a consumer from being set by another one on the shared channel
+	mu sync.Mutex
+}
+
+// consumer - consumer of the queue of a subscription, started with the prefetch of the limit taken after changed
+type consumer struct {
+	tag     string
+	msgs    <-chan amqp.Delivery
+	changed <-chan struct{}
+}
+
+// NewAMQP - make RabbitMQ broker, the messages are encoded as cloudevents in binary mode
+func NewAMQP(logger logger.Logger, channel pkgamqp.AMQP) Broker {
+	return &AMQPBroker{
+		logger:  logger,
+		channel: channel,
+	}
+}
+
+// Publish - publish persistent message to the topic exchange with the type as routing key
+func (b *AMQPBroker) Publish(ctx context.Context, topic string, msg *Message) error {
+	channel := b.channel.Get()
+	err := channel.ExchangeDeclare(topic, amqp.ExchangeTopic, true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	// The trace context is injected in the headers
+	publishing, err := pkgamqp.EncodeBinary(ctx, &pkgamqp.CloudEvent{
+		ID:              msg.ID,
+		Source:          msg.Source,
+		SpecVersion:     pkgamqp.CloudEventsSpecVersion,
+		Type:            msg.Type,
+		DataContentType: msg.DataContentType,
+		DataSchema:      msg.DataSchema,
+		Subject:         msg.Subject,
+		Time:            msg.Time,
+		Extensions:      msg.Extensions,
+		Data:            msg.Data,
+	})
+	if err != nil {
+		return err
+	}
+	publishing.DeliveryMode = amqp.Persistent
+
+	return channel.Publish(topic, msg.Type, false, false, publishing)
+}
+
+// Declare - declare the topic exchange and the durable queue of the group bound to the types
+func (b *AMQPBroker) Declare(ctx context.Context, sub *Subscription) error {
+	channel := b.channel.Get()
+	err := channel.ExchangeDeclare(sub.Topic, amqp.ExchangeTopic, true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	q, err := channel.QueueDeclare(sub.Group, true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, messageType := range sub.Types {
+		err = channel.QueueBind(q.Name, messageType, sub.Topic, false, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Subscribe - consume the durable queue of the group with manual acknowledgement. With a concurrency limit
+// the broker delivers at most as many unacknowledged messages as the limit, when the limit changes a consumer
+// with the new prefetch replaces the current one, whose deliveries already received are still handled
+func (b *AMQPBroker) Subscribe(ctx context.Context, sub *Subscription, handler Handler) error {
+	err := b.Declare(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	channel := b.channel.Get()
+	current, err := b.consume(channel, sub)
+	if err != nil {
+		return err
+	}
+
+	defer drain(sub)
+
+	// stale - deliveries of the replaced consumer, the next change waits until they are handled
+	var stale <-chan amqp.Delivery
+	for {
+		var changed <-chan struct{}
+		if stale == nil {
+			changed = current.changed
+		}
+
+		select {
+		case <-ctx.Done():
+			return channel.Cancel(current.tag, false)
+		case <-changed:
+			next, err := b.consume(channel, sub)
+			if err != nil {
+				return err
+			}
+			err = channel.Cancel(current.tag, false)
+			if err != nil {
+				return err
+			}
+			stale, current = current.msgs, next
+		case d, ok := <-stale:
+			if !ok {
+				stale = nil
+				continue
+			}
+			dispatch(sub, func() {
+				b.handle(d, handler)
+			})
+		case d, ok := <-current.msgs:
+			if !ok {
+				return nil
+			}
+			dispatch(sub, func() {
+				b.handle(d, handler)
+			})
+		}
+	}
+}
+
+// consume - start a consumer of the queue of the group, with a concurrency limit its prefetch is the limit.
+// The prefetch applies to the consumers started after it is set, it is reset for the other consumers of the channel
+func (b *AMQPBroker) consume(channel pkgamqp.Channel, sub *Subscription) (*consumer, error) {
+	c := &consumer{tag: uuid.NewString()}
+	if sub.Concurrency == nil {
+		msgs, err := channel.Consume(sub.Group, c.tag, false, false, false, false, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.msgs = msgs
+
+		return c, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c.changed = sub.Concurrency.Changed()
+	err := channel.Qos(sub.Concurrency.Limit(), 0, false)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := channel.Consume(sub.Group, c.tag, false, false, false, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.msgs = msgs
+
+	err = channel.Qos(0, 0, false)
+	if err != nil {
+		b.logger.Error(context.Background(), "consumer prefetch reset failed", "group", sub.Group, "error", err)
+	}
+
+	return c, nil
+}
+
+// Close - the subscriptions stop when the amqp channel, which the broker does not own, is closed
+func (b *AMQPBroker) Close() error {
+	return nil
+}
+
+// handle - acknowledge the handled delivery, a failed one is requeued once
+func (b *AMQPBroker) handle(d amqp.Delivery, handler Handler) {
+	ce, err := pkgamqp.DecodeCloudEvent(d)
+	if err != nil {
+		// Undecodable message, requeue would loop forever
+		b.logger.Error(context.Background(), "undecodable message dropped", "routing_key", d.RoutingKey, "error", err)
+		b.nack(d, false)
+		return
+	}
+
+	err = handler(ce.Context(context.Background()), &Message{
+		ID:              ce.ID,
+		Source:          ce.Source,
+		Type:            ce.Type,
+		Subject:         ce.Subject,
+		Time:            ce.Time,
+		DataContentType: ce.DataContentType,
+		DataSchema:      ce.DataSchema,
+		Extensions:      ce.Extensions,
+		Data:            ce.Data,
+	})
+	if err != nil {
+		b.nack(d, !d.Redelivered && !errors.Is(err, ErrReject))
+		return
+	}
+
+	err = d.Ack(false)
+	if err != nil {
+		b.logger.Error(context.Background(), "message ack failed", "message_id", d.MessageId, "error", err)
+	}
+}
+
+func (b *AMQPBroker) nack(d amqp.Delivery, requeue bool) {
+	err := d.Nack(false, requeue)
+	if err != nil {
+		b.logger.Error(context.Background(), "message nack failed", "message_id", d.MessageId, "error", err)
+	}
+}
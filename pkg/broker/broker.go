@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	config "go-rengan/pkg/config"
+	logger "go-rengan/pkg/logger"
+	metrics "go-rengan/pkg/metrics"
+)
+
+// ErrReject - wrap a handler error with it to drop the message instead of redelivering it
+var ErrReject = errors.New("broker: message rejected")
+
+// ErrClosed - publish to a closed broker
+var ErrClosed = errors.New("broker: closed")
+
+// Message - broker neutral message, its attributes follow the cloudevents context attributes
+type Message struct {
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	DataSchema      string
+	Extensions      map[string]string
+	Data            []byte
+}
+
+// Subscription - consumer group of a topic, the group receives the messages whose type matches one of the types.
+// A type pattern "*" matches exactly one word and "#" matches zero or more words, e.g. "todo.*".
+// Without concurrency the messages are handled one at a time.
+type Subscription struct {
+	Topic       string
+	Group       string
+	Types       []string
+	Concurrency *Concurrency
+}
+
+// Handler - handle one message, returning an error redelivers the message once unless it wraps ErrReject
+type Handler func(ctx context.Context, msg *Message) error
+
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg *Message) error
+}
+
+type Subscriber interface {
+	// Declare creates the group of the subscription, so it keeps the messages published before the first subscriber
+	Declare(ctx context.Context, sub *Subscription) error
+	// Subscribe handles the messages of the subscription until ctx is done or the broker is closed
+	Subscribe(ctx context.Context, sub *Subscription, handler Handler) error
+}
+
+type Broker interface {
+	Publisher
+	Subscriber
+	// Close stops the subscriptions and releases the messages the broker holds
+	Close() error
+}
+
+// New - make the broker of the backend, RabbitMQ through the amqp channel or Go channels in process.
+// The messages are validated against their schema when published and received, and measured.
+func New(cfg config.AMQPConfig, logger logger.Logger, metrics metrics.Metrics, channel pkgamqp.AMQP, validator Validator) (Broker, error) {
+	backend := pkgamqp.Broker(cfg.Broker)
+	switch backend {
+	case pkgamqp.BrokerAMQP, "":
+		return NewInstrumented(metrics, NewValidating(logger, NewAMQP(logger, channel), validator)), nil
+	case pkgamqp.BrokerMemory:
+		return NewInstrumented(metrics, NewValidating(logger, NewChannel(), validator)), nil
+	default:
+		return nil, fmt.Errorf("unknown broker %q", backend)
+	}
+}
@@ -0,0 +1,189 @@
+package broker_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	"go-rengan/pkg/amqp/memory"
+	"go-rengan/pkg/broker"
+	config "go-rengan/pkg/config"
+	logger "go-rengan/pkg/logger"
+	tenant "go-rengan/pkg/tenant"
+	tracing "go-rengan/pkg/tracing"
+	errorsutil "go-rengan/utils/errors"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var appConfig = config.AppConfig{ID: 1, Name: "go-rengan"}
+
+type received struct {
+	ctx context.Context
+	msg *broker.Message
+}
+
+// backend - broker under test and how to know its group has a running subscriber
+type backend struct {
+	name   string
+	broker broker.Broker
+	ready  func(topic, group string) bool
+}
+
+func backends(t *testing.T) []backend {
+	memoryBroker := memory.New()
+	t.Cleanup(func() { memoryBroker.Close() })
+
+	channelBroker := broker.NewChannel()
+
+	return []backend{
+		{
+			name:   "amqp",
+			broker: broker.NewAMQP(logger.NewNop(), pkgamqp.NewWithChannel(memoryBroker)),
+			ready: func(topic, group string) bool {
+				q, _ := memoryBroker.QueueDeclare(group, true, false, false, false, nil)
+				return q.Consumers > 0
+			},
+		},
+		{
+			name:   "channel",
+			broker: channelBroker,
+			ready: func(topic, group string) bool {
+				return channelBroker.(*broker.ChannelBroker).Subscribers(topic, group) > 0
+			},
+		},
+	}
+}
+
+// subscribe - run the subscription until the test ends, every handled message is sent to the returned channel
+func subscribe(t *testing.T, b backend, sub *broker.Subscription, errs ...error) <-chan received {
+	messages := make(chan received, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		err := b.broker.Subscribe(ctx, sub, func(ctx context.Context, msg *broker.Message) error {
+			messages <- received{ctx: ctx, msg: msg}
+			if len(errs) > 0 {
+				err := errs[0]
+				errs = errs[1:]
+				return err
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	for i := 0; i < 100 && !b.ready(sub.Topic, sub.Group); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	return messages
+}
+
+func waitReceived(t *testing.T, messages <-chan received) received {
+	select {
+	case r := <-messages:
+		return r
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return received{}
+	}
+}
+
+func assertNoReceived(t *testing.T, messages <-chan received) {
+	select {
+	case r := <-messages:
+		t.Fatalf("unexpected message received %s", r.msg.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func newMessage(id, messageType string) *broker.Message {
+	return &broker.Message{
+		ID:              id,
+		Source:          "/go-rengan/test",
+		Type:            messageType,
+		Subject:         "1",
+		Time:            time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+		DataContentType: "application/json",
+		Data:            []byte(`{"title":"Buy milk"}`),
+	}
+}
+
+func TestPublish(t *testing.T) {
+	tracing := tracing.NewNoop()
+
+	for _, b := range backends(t) {
+		t.Run(fmt.Sprintf("success when subscribed (%s)", b.name), func(t *testing.T) {
+			messages := subscribe(t, b, &broker.Subscription{Topic: "todo.events", Group: "send_email", Types: []string{"todo.created"}})
+
+			ctx, span := tracing.Tracer("test").Start(tenant.WithTenant(context.Background(), "acme"), "test")
+			defer span.End()
+
+			err := b.broker.Publish(ctx, "todo.events", newMessage("1", "todo.created"))
+			assert.NoError(t, err)
+
+			r := waitReceived(t, messages)
+			assert.Equal(t, "1", r.msg.ID)
+			assert.Equal(t, "/go-rengan/test", r.msg.Source)
+			assert.Equal(t, "todo.created", r.msg.Type)
+			assert.Equal(t, "1", r.msg.Subject)
+			assert.True(t, r.msg.Time.Equal(time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)))
+			assert.Equal(t, "application/json", r.msg.DataContentType)
+			assert.Equal(t, `{"title":"Buy milk"}`, string(r.msg.Data))
+
+			// The subscriber continues the publisher trace
+			assert.Equal(t, span.SpanContext().TraceID(), trace.SpanContextFromContext(r.ctx).TraceID())
+
+			// and acts for the tenant of the publisher, carried in the tenantid header
+			tenantID, _ := tenant.FromContext(r.ctx)
+			assert.Equal(t, "acme", tenantID)
+			assert.Equal(t, "acme", r.msg.Extensions[tenant.Key])
+		})
+
+		t.Run(fmt.Sprintf("success when type does not match (%s)", b.name), func(t *testing.T) {
+			created := subscribe(t, b, &broker.Subscription{Topic: "todo.events", Group: "created", Types: []string{"todo.created"}})
+			all := subscribe(t, b, &broker.Subscription{Topic: "todo.events", Group: "all", Types: []string{"todo.*"}})
+
+			err := b.broker.Publish(context.Background(), "todo.events", newMessage("2", "todo.deleted"))
+			assert.NoError(t, err)
+
+			assert.Equal(t, "2", waitReceived(t, all).msg.ID)
+			assertNoReceived(t, created)
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(fmt.Sprintf("success when failed message is redelivered once (%s)", b.name), func(t *testing.T) {
+			messages := subscribe(t, b, &broker.Subscription{Topic: "todo.events", Group: "retry", Types: []string{"#"}}, errorsutil.ErrDefault, errorsutil.ErrDefault)
+
+			err := b.broker.Publish(context.Background(), "todo.events", newMessage("3", "todo.created"))
+			assert.NoError(t, err)
+
+			assert.Equal(t, "3", waitReceived(t, messages).msg.ID)
+			assert.Equal(t, "3", waitReceived(t, messages).msg.ID)
+			assertNoReceived(t, messages)
+		})
+
+		t.Run(fmt.Sprintf("success when rejected message is dropped (%s)", b.name), func(t *testing.T) {
+			messages := subscribe(t, b, &broker.Subscription{Topic: "todo.events", Group: "reject", Types: []string{"#"}}, fmt.Errorf("%w: invalid", broker.ErrReject))
+
+			err := b.broker.Publish(context.Background(), "todo.events", newMessage("4", "todo.created"))
+			assert.NoError(t, err)
+
+			assert.Equal(t, "4", waitReceived(t, messages).msg.ID)
+			assertNoReceived(t, messages)
+		})
+	}
+}
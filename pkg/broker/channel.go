@@ -0,0 +1,209 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	amqptopic "go-rengan/pkg/amqp/topic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// DefaultChannelBuffer - how many messages a group holds before Publish blocks
+const DefaultChannelBuffer = 1024
+
+// ChannelBroker - in process backend on Go channels, for tests and local development.
+// A group lives as long as the process, messages published before its first subscription are dropped.
+type ChannelBroker struct {
+	mu        sync.Mutex
+	groups    map[string]*group
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type group struct {
+	topic       string
+	types       map[string]bool
+	messages    chan *delivery
+	subscribers int
+}
+
+type delivery struct {
+	msg         *Message
+	redelivered bool
+}
+
+// NewChannel - make in process broker
+func NewChannel() Broker {
+	return &ChannelBroker{
+		groups: map[string]*group{},
+		done:   make(chan struct{}),
+	}
+}
+
+// Publish - send a copy of the message to every group of the topic whose types match
+func (b *ChannelBroker) Publish(ctx context.Context, topic string, msg *Message) error {
+	select {
+	case <-b.done:
+		return ErrClosed
+	default:
+	}
+
+	msg = copyMessage(msg)
+
+	// Inject the context in the extensions, like the distributed tracing extension of cloudevents
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(msg.Extensions))
+
+	for _, g := range b.matching(topic, msg.Type) {
+		select {
+		case g.messages <- &delivery{msg: copyMessage(msg)}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.done:
+			return ErrClosed
+		}
+	}
+
+	return nil
+}
+
+// Declare - create the group of the subscription
+func (b *ChannelBroker) Declare(ctx context.Context, sub *Subscription) error {
+	b.group(sub)
+	return nil
+}
+
+// Subscribe - receive the messages of the group, subscribers of the same group compete for them
+func (b *ChannelBroker) Subscribe(ctx context.Context, sub *Subscription, handler Handler) error {
+	g := b.subscribe(sub)
+	defer b.unsubscribe(g)
+	defer drain(sub)
+
+	for {
+		// A closed broker stops the subscription even when the group has messages
+		select {
+		case <-b.done:
+			return nil
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-b.done:
+			return nil
+		case d := <-g.messages:
+			dispatch(sub, func() {
+				err := handler(otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(d.msg.Extensions)), d.msg)
+				if err != nil && !d.redelivered && !errors.Is(err, ErrReject) {
+					// Requeue without blocking the subscriber, it may be the only one draining the group.
+					// The message is dropped when the broker is closed before the group has room for it
+					go func(msg *Message) {
+						select {
+						case g.messages <- &delivery{msg: msg, redelivered: true}:
+						case <-b.done:
+						}
+					}(d.msg)
+				}
+			})
+		}
+	}
+}
+
+// Close - stop the subscriptions, the messages of the groups are dropped
+func (b *ChannelBroker) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+
+	return nil
+}
+
+// Subscribers - number of running subscribers of the group
+func (b *ChannelBroker) Subscribers(topic, groupName string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g, ok := b.groups[topic+"/"+groupName]
+	if !ok {
+		return 0
+	}
+
+	return g.subscribers
+}
+
+// subscribe - get or create the group of the subscription and count the subscriber
+func (b *ChannelBroker) subscribe(sub *Subscription) *group {
+	g := b.group(sub)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g.subscribers++
+
+	return g
+}
+
+func (b *ChannelBroker) unsubscribe(g *group) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g.subscribers--
+}
+
+// group - get or create the group of the subscription and bind the types to it
+func (b *ChannelBroker) group(sub *Subscription) *group {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := sub.Topic + "/" + sub.Group
+	g, ok := b.groups[key]
+	if !ok {
+		g = &group{
+			topic:    sub.Topic,
+			types:    map[string]bool{},
+			messages: make(chan *delivery, DefaultChannelBuffer),
+		}
+		b.groups[key] = g
+	}
+
+	for _, messageType := range sub.Types {
+		g.types[messageType] = true
+	}
+
+	return g
+}
+
+func (b *ChannelBroker) matching(topic, messageType string) []*group {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	groups := []*group{}
+	for _, g := range b.groups {
+		if g.topic != topic {
+			continue
+		}
+
+		for pattern := range g.types {
+			if amqptopic.Match(pattern, messageType) {
+				groups = append(groups, g)
+				break
+			}
+		}
+	}
+
+	return groups
+}
+
+func copyMessage(msg *Message) *Message {
+	c := *msg
+	c.Extensions = make(map[string]string, len(msg.Extensions))
+	for k, v := range msg.Extensions {
+		c.Extensions[k] = v
+	}
+	c.Data = append([]byte(nil), msg.Data...)
+
+	return &c
+}
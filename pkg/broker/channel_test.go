@@ -0,0 +1,78 @@
+package broker_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"go-rengan/pkg/broker"
+	errorsutil "go-rengan/utils/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelClose(t *testing.T) {
+	t.Run("success subscription stops and publish fails when closed", func(t *testing.T) {
+		b := broker.NewChannel()
+		sub := &broker.Subscription{Topic: "todo.events", Group: "close", Types: []string{"#"}}
+
+		done := make(chan error)
+		go func() {
+			done <- b.Subscribe(context.Background(), sub, func(ctx context.Context, msg *broker.Message) error { return nil })
+		}()
+		for i := 0; i < 100 && b.(*broker.ChannelBroker).Subscribers(sub.Topic, sub.Group) == 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		assert.NoError(t, b.Close())
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("subscription not stopped")
+		}
+		assert.ErrorIs(t, b.Publish(context.Background(), sub.Topic, newMessage("1", "todo.created")), broker.ErrClosed)
+	})
+
+	t.Run("success failed message is dropped when closed while its group is full", func(t *testing.T) {
+		goroutines := runtime.NumGoroutine()
+
+		b := broker.NewChannel()
+		sub := &broker.Subscription{Topic: "todo.events", Group: "full", Types: []string{"#"}}
+		assert.NoError(t, b.Declare(context.Background(), sub))
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- b.Subscribe(context.Background(), sub, func(ctx context.Context, msg *broker.Message) error {
+				if msg.ID != "0" {
+					return nil
+				}
+				close(started)
+				<-release
+				return errorsutil.ErrDefault
+			})
+		}()
+
+		// The other messages fill the group while the first one is handled
+		assert.NoError(t, b.Publish(context.Background(), sub.Topic, newMessage("0", "todo.created")))
+		<-started
+		for i := 1; i <= broker.DefaultChannelBuffer; i++ {
+			assert.NoError(t, b.Publish(context.Background(), sub.Topic, newMessage(fmt.Sprint(i), "todo.created")))
+		}
+
+		assert.NoError(t, b.Close())
+		close(release)
+		assert.NoError(t, <-done)
+
+		// The requeue of the failed message does not wait for room in the group forever
+		for i := 0; i < 100 && runtime.NumGoroutine() > goroutines; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+	})
+}
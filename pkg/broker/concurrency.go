@@ -0,0 +1,106 @@
+package broker
+
+import (
+	"sync"
+)
+
+// Concurrency - limit of the messages a subscription handles at the same time, it can be changed while subscribed
+type Concurrency struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+	wg     sync.WaitGroup
+	// changed is closed and replaced when the limit changes
+	changed chan struct{}
+}
+
+// NewConcurrency - make concurrency limit, a limit below 1 handles one message at a time
+func NewConcurrency(limit int) *Concurrency {
+	if limit < 1 {
+		limit = 1
+	}
+
+	c := &Concurrency{limit: limit, changed: make(chan struct{})}
+	c.cond = sync.NewCond(&c.mu)
+
+	return c
+}
+
+// SetLimit - change the limit, the messages already being handled finish when it is lowered
+func (c *Concurrency) SetLimit(limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	c.mu.Lock()
+	if c.limit != limit {
+		c.limit = limit
+		close(c.changed)
+		c.changed = make(chan struct{})
+	}
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+// Changed - channel closed when the limit changes, to be taken before reading the limit
+func (c *Concurrency) Changed() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.changed
+}
+
+// Limit - current limit
+func (c *Concurrency) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.limit
+}
+
+// Go - run fn once fewer messages than the limit are being handled
+func (c *Concurrency) Go(fn func()) {
+	c.mu.Lock()
+	for c.active >= c.limit {
+		c.cond.Wait()
+	}
+	c.active++
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.release()
+		fn()
+	}()
+}
+
+// Wait - wait for the messages being handled
+func (c *Concurrency) Wait() {
+	c.wg.Wait()
+}
+
+func (c *Concurrency) release() {
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+// dispatch - handle in the subscription loop when no concurrency is set, otherwise through the concurrency limit
+func dispatch(sub *Subscription, fn func()) {
+	if sub.Concurrency == nil {
+		fn()
+		return
+	}
+
+	sub.Concurrency.Go(fn)
+}
+
+// drain - wait for the messages of the subscription being handled
+func drain(sub *Subscription) {
+	if sub.Concurrency != nil {
+		sub.Concurrency.Wait()
+	}
+}
@@ -0,0 +1,122 @@
+package broker_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	"go-rengan/pkg/amqp/memory"
+	"go-rengan/pkg/broker"
+	logger "go-rengan/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeConcurrency(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name+" success handle up to the limit at the same time", func(t *testing.T) {
+			concurrency := broker.NewConcurrency(2)
+			sub := &broker.Subscription{Topic: "todo.events", Group: b.name + ".concurrency", Types: []string{"todo.created"}, Concurrency: concurrency}
+			started := make(chan string, 10)
+			release := make(chan struct{})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				err := b.broker.Subscribe(ctx, sub, func(ctx context.Context, msg *broker.Message) error {
+					started <- msg.ID
+					<-release
+					return nil
+				})
+				assert.NoError(t, err)
+				close(done)
+			}()
+			for i := 0; i < 100 && !b.ready(sub.Topic, sub.Group); i++ {
+				time.Sleep(time.Millisecond)
+			}
+
+			for i := 1; i <= 3; i++ {
+				assert.NoError(t, b.broker.Publish(context.Background(), sub.Topic, newMessage(fmt.Sprint(i), "todo.created")))
+			}
+
+			waitStarted(t, started)
+			waitStarted(t, started)
+			select {
+			case id := <-started:
+				t.Fatalf("message %s handled above the limit", id)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			// Raising the limit handles the waiting message
+			concurrency.SetLimit(3)
+			waitStarted(t, started)
+
+			close(release)
+			cancel()
+			<-done
+		})
+	}
+}
+
+// qosChannel - memory broker which records the prefetch counts
+type qosChannel struct {
+	*memory.Broker
+	mu       sync.Mutex
+	prefetch []int
+}
+
+func (c *qosChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	c.mu.Lock()
+	c.prefetch = append(c.prefetch, prefetchCount)
+	c.mu.Unlock()
+
+	return c.Broker.Qos(prefetchCount, prefetchSize, global)
+}
+
+func (c *qosChannel) prefetchCounts() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]int(nil), c.prefetch...)
+}
+
+func TestSubscribePrefetch(t *testing.T) {
+	t.Run("success prefetch is the limit and follows its changes", func(t *testing.T) {
+		channel := &qosChannel{Broker: memory.New()}
+		t.Cleanup(func() { channel.Close() })
+		b := broker.NewAMQP(logger.NewNop(), pkgamqp.NewWithChannel(channel))
+		concurrency := broker.NewConcurrency(2)
+		sub := &broker.Subscription{Topic: "todo.events", Group: "amqp.prefetch", Types: []string{"todo.created"}, Concurrency: concurrency}
+		messages := subscribe(t, backend{name: "amqp", broker: b, ready: func(topic, group string) bool {
+			q, _ := channel.QueueDeclare(group, true, false, false, false, nil)
+			return q.Consumers > 0
+		}}, sub)
+
+		// The prefetch is reset once the consumer is started, for the other consumers of the channel
+		assert.Equal(t, []int{2, 0}, channel.prefetchCounts())
+
+		concurrency.SetLimit(2)
+		concurrency.SetLimit(3)
+		assert.Eventually(t, func() bool {
+			return len(channel.prefetchCounts()) == 4
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, []int{2, 0, 3, 0}, channel.prefetchCounts())
+
+		// The consumer which replaced the first one receives the messages
+		assert.NoError(t, b.Publish(context.Background(), sub.Topic, newMessage("1", "todo.created")))
+		assert.Equal(t, "1", waitReceived(t, messages).msg.ID)
+		q, _ := channel.QueueDeclare(sub.Group, true, false, false, false, nil)
+		assert.Equal(t, 1, q.Consumers)
+	})
+}
+
+func waitStarted(t *testing.T, started <-chan string) {
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("no message handled")
+	}
+}
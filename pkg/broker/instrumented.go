@@ -0,0 +1,76 @@
+package broker
+
+import (
+	"context"
+	"time"
+
+	metrics "go-rengan/pkg/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+)
+
+// Attributes of the broker measurements
+const (
+	AttributeTopic = attribute.Key("messaging.destination")
+	AttributeGroup = attribute.Key("messaging.consumer_group")
+	AttributeType  = attribute.Key("messaging.message_type")
+)
+
+// InstrumentedBroker - count the published and consumed messages and record the handler latency of each group
+type InstrumentedBroker struct {
+	broker    Broker
+	published syncint64.Counter
+	consumed  syncint64.Counter
+	duration  syncfloat64.Histogram
+}
+
+// NewInstrumented - make broker which records the metrics of the messages of the broker
+func NewInstrumented(m metrics.Metrics, broker Broker) Broker {
+	meter := m.Meter("go-rengan/pkg/broker")
+
+	return &InstrumentedBroker{
+		broker:    broker,
+		published: metrics.Counter(meter, "messaging.published", "Messages published by topic"),
+		consumed:  metrics.Counter(meter, "messaging.consumed", "Messages handled by consumer group"),
+		duration:  metrics.Histogram(meter, "messaging.handler.duration", "Duration of the message handlers by consumer group"),
+	}
+}
+
+// Publish - publish the message and count it with its outcome
+func (b *InstrumentedBroker) Publish(ctx context.Context, topic string, msg *Message) error {
+	err := b.broker.Publish(ctx, topic, msg)
+	b.published.Add(ctx, 1, AttributeTopic.String(topic), AttributeType.String(msg.Type), metrics.Outcome(err))
+
+	return err
+}
+
+// Close - close the broker
+func (b *InstrumentedBroker) Close() error {
+	return b.broker.Close()
+}
+
+// Declare - create the group of the subscription
+func (b *InstrumentedBroker) Declare(ctx context.Context, sub *Subscription) error {
+	return b.broker.Declare(ctx, sub)
+}
+
+// Subscribe - handle the messages, counting them and recording the duration of the handler with its outcome
+func (b *InstrumentedBroker) Subscribe(ctx context.Context, sub *Subscription, handler Handler) error {
+	return b.broker.Subscribe(ctx, sub, func(ctx context.Context, msg *Message) error {
+		start := time.Now()
+		err := handler(ctx, msg)
+
+		attrs := []attribute.KeyValue{
+			AttributeTopic.String(sub.Topic),
+			AttributeGroup.String(sub.Group),
+			AttributeType.String(msg.Type),
+			metrics.Outcome(err),
+		}
+		b.consumed.Add(ctx, 1, attrs...)
+		b.duration.Record(ctx, float64(time.Since(start).Microseconds())/1000, attrs...)
+
+		return err
+	})
+}
@@ -0,0 +1,85 @@
+package broker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-rengan/pkg/broker"
+	metrics "go-rengan/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	metric_sdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collect - the metrics of the reader by name
+func collect(t *testing.T, reader metric_sdk.Reader) map[string]metricdata.Aggregation {
+	rm, err := reader.Collect(context.Background())
+	assert.NoError(t, err)
+
+	result := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			result[m.Name] = m.Data
+		}
+	}
+
+	return result
+}
+
+func TestInstrumented(t *testing.T) {
+	t.Run("success count published and consumed messages with their outcome", func(t *testing.T) {
+		reader := metric_sdk.NewManualReader()
+		channelBroker := broker.NewChannel()
+		b := backend{
+			name:   "channel",
+			broker: broker.NewInstrumented(metrics.NewWithReader(reader), channelBroker),
+			ready: func(topic, group string) bool {
+				return channelBroker.(*broker.ChannelBroker).Subscribers(topic, group) > 0
+			},
+		}
+		messages := subscribe(t, b, &broker.Subscription{Topic: "todo.events", Group: "send_email", Types: []string{"#"}}, broker.ErrReject)
+
+		err := b.broker.Publish(context.Background(), "todo.events", newMessage("1", "todo.created"))
+		assert.NoError(t, err)
+		waitReceived(t, messages)
+
+		var data map[string]metricdata.Aggregation
+		for i := 0; i < 100; i++ {
+			if data = collect(t, reader); data["messaging.consumed"] != nil {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		published := data["messaging.published"].(metricdata.Sum[int64]).DataPoints
+		assert.Len(t, published, 1)
+		assert.Equal(t, int64(1), published[0].Value)
+		outcome, _ := published[0].Attributes.Value("outcome")
+		assert.Equal(t, metrics.OutcomeSuccess, outcome.AsString())
+
+		consumed := data["messaging.consumed"].(metricdata.Sum[int64]).DataPoints
+		assert.Len(t, consumed, 1)
+		outcome, _ = consumed[0].Attributes.Value("outcome")
+		assert.Equal(t, metrics.OutcomeError, outcome.AsString())
+		group, _ := consumed[0].Attributes.Value(broker.AttributeGroup)
+		assert.Equal(t, attribute.StringValue("send_email"), group)
+
+		duration := data["messaging.handler.duration"].(metricdata.Histogram).DataPoints
+		assert.Equal(t, uint64(1), duration[0].Count)
+	})
+
+	t.Run("error count failed publish", func(t *testing.T) {
+		reader := metric_sdk.NewManualReader()
+		b := broker.NewInstrumented(metrics.NewWithReader(reader), broker.NewValidating(nil, broker.NewChannel(), fakeValidator{}))
+
+		err := b.Publish(context.Background(), "todo.events", &broker.Message{ID: "1", Type: "todo.created", Data: []byte(`{"title": 1}`)})
+		assert.ErrorIs(t, err, errInvalid)
+
+		published := collect(t, reader)["messaging.published"].(metricdata.Sum[int64]).DataPoints
+		outcome, _ := published[0].Attributes.Value("outcome")
+		assert.Equal(t, metrics.OutcomeError, outcome.AsString())
+	})
+}
@@ -0,0 +1,101 @@
+package broker
+
+import (
+	"context"
+
+	logger "go-rengan/pkg/logger"
+)
+
+// Quarantine of the messages which do not match their schema
+const (
+	QuarantineTopic = "quarantine"
+	QuarantineGroup = "quarantine"
+)
+
+// Extensions added to the quarantined messages
+const (
+	ExtensionValidationError = "validationerror"
+	ExtensionTopic           = "quarantinetopic"
+	ExtensionGroup           = "quarantinegroup"
+)
+
+// Validator - validate the data of the message type against the schema of the dataschema uri
+type Validator interface {
+	Validate(messageType string, dataSchema string, data []byte) error
+}
+
+// ValidatingBroker - refuse to publish invalid messages and quarantine the invalid received ones
+type ValidatingBroker struct {
+	logger    logger.Logger
+	broker    Broker
+	validator Validator
+}
+
+// NewValidating - make broker which validates the messages of the broker
+func NewValidating(logger logger.Logger, broker Broker, validator Validator) Broker {
+	return &ValidatingBroker{
+		logger:    logger,
+		broker:    broker,
+		validator: validator,
+	}
+}
+
+// Publish - publish the message when it is valid
+func (b *ValidatingBroker) Publish(ctx context.Context, topic string, msg *Message) error {
+	err := b.validator.Validate(msg.Type, msg.DataSchema, msg.Data)
+	if err != nil {
+		return err
+	}
+
+	return b.broker.Publish(ctx, topic, msg)
+}
+
+// Close - close the broker
+func (b *ValidatingBroker) Close() error {
+	return b.broker.Close()
+}
+
+// Declare - create the group of the subscription
+func (b *ValidatingBroker) Declare(ctx context.Context, sub *Subscription) error {
+	return b.broker.Declare(ctx, sub)
+}
+
+// Subscribe - handle the valid messages, the invalid ones are moved to the quarantine with the validation error attached
+func (b *ValidatingBroker) Subscribe(ctx context.Context, sub *Subscription, handler Handler) error {
+	return b.broker.Subscribe(ctx, sub, func(ctx context.Context, msg *Message) error {
+		err := b.validator.Validate(msg.Type, msg.DataSchema, msg.Data)
+		if err == nil {
+			return handler(ctx, msg)
+		}
+		b.logger.Error(ctx, "invalid message", "message_id", msg.ID, "type", msg.Type, "error", err)
+
+		return b.quarantine(ctx, sub, msg, err)
+	})
+}
+
+func (b *ValidatingBroker) quarantine(ctx context.Context, sub *Subscription, msg *Message, validationErr error) error {
+	quarantine := &Subscription{Topic: QuarantineTopic, Group: QuarantineGroup, Types: []string{"#"}}
+	err := b.broker.Declare(ctx, quarantine)
+	if err != nil {
+		return err
+	}
+
+	extensions := make(map[string]string, len(msg.Extensions)+3)
+	for k, v := range msg.Extensions {
+		extensions[k] = v
+	}
+	extensions[ExtensionValidationError] = validationErr.Error()
+	extensions[ExtensionTopic] = sub.Topic
+	extensions[ExtensionGroup] = sub.Group
+
+	quarantined := *msg
+	quarantined.Extensions = extensions
+
+	err = b.broker.Publish(ctx, QuarantineTopic, &quarantined)
+	if err != nil {
+		return err
+	}
+	b.logger.Warn(ctx, "message quarantined", "message_id", msg.ID, "type", msg.Type, "group", sub.Group)
+
+	return nil
+}
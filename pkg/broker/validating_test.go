@@ -0,0 +1,79 @@
+package broker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-rengan/pkg/broker"
+	logger "go-rengan/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errInvalid = errors.New("/title: missing properties")
+
+// fakeValidator - messages are valid when their data is {}
+type fakeValidator struct{}
+
+func (fakeValidator) Validate(messageType string, dataSchema string, data []byte) error {
+	if string(data) != "{}" {
+		return errInvalid
+	}
+	return nil
+}
+
+func validating() (backend, broker.Broker) {
+	channelBroker := broker.NewChannel()
+	b := backend{
+		name:   "channel",
+		broker: broker.NewValidating(logger.NewNop(), channelBroker, fakeValidator{}),
+		ready: func(topic, group string) bool {
+			return channelBroker.(*broker.ChannelBroker).Subscribers(topic, group) > 0
+		},
+	}
+
+	return b, channelBroker
+}
+
+func TestValidatingPublish(t *testing.T) {
+	t.Run("error when message is invalid", func(t *testing.T) {
+		b, _ := validating()
+		messages := subscribe(t, b, &broker.Subscription{Topic: "todo.events", Group: "send_email", Types: []string{"#"}})
+
+		err := b.broker.Publish(context.Background(), "todo.events", &broker.Message{ID: "1", Type: "todo.created", Data: []byte(`{"title": 1}`)})
+
+		assert.ErrorIs(t, err, errInvalid)
+		assertNoReceived(t, messages)
+	})
+
+	t.Run("success when message is valid", func(t *testing.T) {
+		b, _ := validating()
+		messages := subscribe(t, b, &broker.Subscription{Topic: "todo.events", Group: "send_email", Types: []string{"#"}})
+
+		err := b.broker.Publish(context.Background(), "todo.events", &broker.Message{ID: "1", Type: "todo.created", Data: []byte(`{}`)})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1", waitReceived(t, messages).msg.ID)
+	})
+}
+
+func TestValidatingSubscribe(t *testing.T) {
+	t.Run("success when invalid message is quarantined", func(t *testing.T) {
+		b, inner := validating()
+		messages := subscribe(t, b, &broker.Subscription{Topic: "todo.events", Group: "send_email", Types: []string{"#"}})
+
+		// Publish around the validation, like a publisher without the schemas
+		err := inner.Publish(context.Background(), "todo.events", &broker.Message{ID: "1", Type: "todo.created", Data: []byte(`{"title": 1}`)})
+		assert.NoError(t, err)
+		assertNoReceived(t, messages)
+
+		quarantined := subscribe(t, backend{broker: inner, ready: b.ready}, &broker.Subscription{Topic: broker.QuarantineTopic, Group: broker.QuarantineGroup, Types: []string{"#"}})
+		r := waitReceived(t, quarantined)
+		assert.Equal(t, "1", r.msg.ID)
+		assert.Equal(t, `{"title": 1}`, string(r.msg.Data))
+		assert.Equal(t, errInvalid.Error(), r.msg.Extensions[broker.ExtensionValidationError])
+		assert.Equal(t, "todo.events", r.msg.Extensions[broker.ExtensionTopic])
+		assert.Equal(t, "send_email", r.msg.Extensions[broker.ExtensionGroup])
+	})
+}
@@ -1,15 +1,441 @@
 package config
 
 import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
-	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 )
 
-func New() error {
+// Config - application configuration.
+// Every setting is read from its default, then the config file, then the environment variable, the last one set wins.
+// Settings tagged reload:"live" are applied when the config is reloaded, the others need a restart.
+// Secret settings are redacted when printed, logged or dumped.
+type Config struct {
+	App          AppConfig          `yaml:"app"`
+	Log          LogConfig          `yaml:"log"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Tracer       TracerConfig       `yaml:"tracer"`
+	Metrics      MetricsConfig      `yaml:"metrics"`
+	Health       HealthConfig       `yaml:"health"`
+	Auth         AuthConfig         `yaml:"auth"`
+	RateLimit    RateLimitConfig    `yaml:"rate_limit"`
+	Tenant       TenantConfig       `yaml:"tenant"`
+	Idempotency  IdempotencyConfig  `yaml:"idempotency"`
+	OTLP         OTLPConfig         `yaml:"otlp"`
+	AMQP         AMQPConfig         `yaml:"amqp"`
+	Mail         MailConfig         `yaml:"mail"`
+	Notification NotificationConfig `yaml:"notification"`
+	// Features - feature flags by name, FEATURE_FLAGS=name=true,other=false
+	Features map[string]bool `yaml:"features" env:"FEATURE_FLAGS" reload:"live"`
+}
+
+type AppConfig struct {
+	Env  string `yaml:"env" env:"ENV" default:"development" validate:"required"`
+	Port int    `yaml:"port" env:"PORT" default:"3333" validate:"min=1,max=65535"`
+	// ID - numeric id of the instance in the traces, optional
+	ID   int64  `yaml:"id" env:"APP_ID"`
+	Name string `yaml:"name" env:"APP_NAME" default:"go-rengan" validate:"required"`
+	// StopTimeout - how long each component may take to stop on shutdown
+	StopTimeout time.Duration `yaml:"stop_timeout" env:"STOP_TIMEOUT" default:"5s" validate:"gt=0"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL" default:"info" reload:"live" validate:"oneof=debug info warn error"`
+	// Format - json or text, json in production when it is not set
+	Format string `yaml:"format" env:"LOG_FORMAT" validate:"omitempty,oneof=json text"`
+	// Exporter - stdout, or otlp to ship the logs to the collector, stdout is the fallback when it fails
+	Exporter      string        `yaml:"exporter" env:"LOG_EXPORTER" default:"stdout" validate:"oneof=stdout otlp"`
+	BatchSize     int           `yaml:"batch_size" env:"LOG_EXPORT_BATCH_SIZE" default:"512" validate:"min=1"`
+	QueueSize     int           `yaml:"queue_size" env:"LOG_EXPORT_QUEUE_SIZE" default:"2048" validate:"min=1"`
+	ExportTimeout time.Duration `yaml:"export_timeout" env:"LOG_EXPORT_TIMEOUT" default:"10s" validate:"gt=0"`
+	// ExportInterval - a batch which is not full is exported after the interval
+	ExportInterval time.Duration `yaml:"export_interval" env:"LOG_EXPORT_INTERVAL" default:"1s" validate:"gt=0"`
+}
+
+type DatabaseConfig struct {
+	URL            Secret `yaml:"url" env:"DB_URL" validate:"required"`
+	Name           string `yaml:"name" env:"DB_NAME" validate:"required"`
+	ConnectionPool uint64 `yaml:"connection_pool" env:"MONGODB_CONNECTION_POOL" default:"5" validate:"min=1"`
+}
+
+type TracerConfig struct {
+	// Exporter - otlp to the collector of OTEL_EXPORTER_OTLP_ENDPOINT, uptrace at the provider url, stdout or none
+	Exporter    string `yaml:"exporter" env:"TRACER_EXPORTER" default:"uptrace" validate:"oneof=otlp uptrace stdout none"`
+	ProviderURL Secret `yaml:"provider_url" env:"TRACER_PROVIDER_URL" validate:"required_if=Exporter uptrace"`
+	// Sampler - always_on, always_off, traceidratio or their parentbased_ variant which follows the sampling of the parent
+	Sampler      string  `yaml:"sampler" env:"TRACER_SAMPLER" default:"parentbased_always_on" validate:"oneof=always_on always_off traceidratio parentbased_always_on parentbased_always_off parentbased_traceidratio"`
+	SamplerRatio float64 `yaml:"sampler_ratio" env:"TRACER_SAMPLER_RATIO" default:"1" validate:"min=0,max=1"`
+}
+
+type MetricsConfig struct {
+	// Exporter - otlp to the collector of OTEL_EXPORTER_OTLP_ENDPOINT, prometheus to serve them at /metrics, or none
+	Exporter string `yaml:"exporter" env:"METRICS_EXPORTER" default:"otlp" validate:"oneof=otlp prometheus none"`
+	// ExportInterval - how often the otlp exporter pushes the metrics
+	ExportInterval time.Duration `yaml:"export_interval" env:"METRICS_EXPORT_INTERVAL" default:"15s" validate:"gt=0"`
+}
+
+type HealthConfig struct {
+	// CacheInterval - the readiness checks run at most once per interval, the probes in between get the last report
+	CacheInterval time.Duration `yaml:"cache_interval" env:"HEALTH_CACHE_INTERVAL" default:"5s" validate:"gte=0"`
+	CheckTimeout  time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s" validate:"gt=0"`
+	// ShutdownDelay - how long the readiness fails before the http server stops accepting requests
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"HEALTH_SHUTDOWN_DELAY" default:"0s" validate:"gte=0"`
+}
+
+// AuthConfig - JWT of the requests, signed with the HS256 secret or an RS256/ES256 key of the JWKS file
+type AuthConfig struct {
+	JWTSecret Secret `yaml:"jwt_secret" env:"AUTH_JWT_SECRET" validate:"required_without=JWKSFile"`
+	// JWKSFile - path of a local JSON Web Key Set with the public keys
+	JWKSFile string `yaml:"jwks_file" env:"AUTH_JWKS_FILE"`
+	Issuer   string `yaml:"issuer" env:"AUTH_ISSUER" validate:"required"`
+	Audience string `yaml:"audience" env:"AUTH_AUDIENCE" validate:"required"`
+	// Leeway - clock skew allowed when checking the expiry of the tokens
+	Leeway time.Duration `yaml:"leeway" env:"AUTH_LEEWAY" default:"30s" validate:"gte=0"`
+}
+
+// RateLimitConfig - token buckets of the clients of the http server, by api key, user or ip
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED" default:"true" reload:"live"`
+	// Store - memory, or mongo to share the buckets between the instances
+	Store string `yaml:"store" env:"RATE_LIMIT_STORE" default:"memory" validate:"oneof=memory mongo"`
+	// Default - rate of the routes without their own
+	Default Rate `yaml:"default" env:"RATE_LIMIT_DEFAULT" default:"120/1m" reload:"live"`
+	// Routes - rates by method and route pattern, RATE_LIMIT_ROUTES=POST /todo=10/1m,GET /todo/{id}=300/1m
+	Routes map[string]Rate `yaml:"routes" env:"RATE_LIMIT_ROUTES" reload:"live"`
+}
+
+// Rate - requests allowed per period, written "60/1m". A client may send them all at once,
+// then one more every period/requests
+type Rate struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	requests, period, ok := strings.Cut(string(text), "/")
+	if !ok {
+		return fmt.Errorf("cannot parse %q as requests/period", text)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 1 {
+		return fmt.Errorf("cannot parse %q as requests/period, requests must be a positive integer", text)
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return fmt.Errorf("cannot parse %q as requests/period, period must be a positive duration", text)
+	}
+
+	r.Requests, r.Period = n, d
+	return nil
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Requests, r.Period)
+}
+
+// TenantConfig - isolation of the teams hosted on the deployment, every request and message acts for one tenant
+type TenantConfig struct {
+	Enabled bool `yaml:"enabled" env:"TENANT_ENABLED"`
+	// Resolver - where the tenant of a request is read, header, claim (the tenant claim of the token) or subdomain
+	Resolver string `yaml:"resolver" env:"TENANT_RESOLVER" default:"header" validate:"oneof=header claim subdomain"`
+	Header   string `yaml:"header" env:"TENANT_HEADER" default:"X-Tenant-ID" validate:"required"`
+	// Domain - parent domain of the tenant subdomains, acme.todo.example.com is the tenant acme of todo.example.com
+	Domain string `yaml:"domain" env:"TENANT_DOMAIN" validate:"required_if=Resolver subdomain"`
+	// Isolation - collection, the tenants share the collections and the documents have a tenantId,
+	// or database, every tenant has its own database named DB_NAME_<tenant>
+	Isolation string `yaml:"isolation" env:"TENANT_ISOLATION" default:"collection" validate:"oneof=collection database"`
+}
+
+// IdempotencyConfig - replay of the responses of the requests sent again with the same Idempotency-Key
+type IdempotencyConfig struct {
+	// TTL - how long a response is replayed
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" default:"24h" validate:"gt=0"`
+	// LockTimeout - how long a request in progress holds its key, a request which outlives it is considered failed
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"IDEMPOTENCY_LOCK_TIMEOUT" default:"1m" validate:"gt=0"`
+}
+
+// OTLPConfig - OpenTelemetry collector, same variables as the OpenTelemetry SDKs
+type OTLPConfig struct {
+	// Endpoint - host:port for grpc, url for http/protobuf
+	Endpoint string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" default:"localhost:4317" validate:"required"`
+	Protocol string `yaml:"protocol" env:"OTEL_EXPORTER_OTLP_PROTOCOL" default:"grpc" validate:"oneof=grpc http/protobuf"`
+	Insecure bool   `yaml:"insecure" env:"OTEL_EXPORTER_OTLP_INSECURE" default:"true"`
+	// Headers - sent with every export, key=value,other=value
+	Headers Secret `yaml:"headers" env:"OTEL_EXPORTER_OTLP_HEADERS"`
+}
+
+type AMQPConfig struct {
+	// Broker - amqp for RabbitMQ at URL, memory for the in-process broker
+	Broker   string        `yaml:"broker" env:"AMQP_BROKER" default:"amqp" validate:"oneof=amqp memory"`
+	URL      Secret        `yaml:"url" env:"AMQP_URL" validate:"required_if=Broker amqp"`
+	DedupTTL time.Duration `yaml:"dedup_ttl" env:"AMQP_DEDUP_TTL" default:"72h" validate:"gt=0"`
+	// DedupLease - how long a consumer holds a message it handles, longer than the slowest handler
+	DedupLease        time.Duration `yaml:"dedup_lease" env:"AMQP_DEDUP_LEASE" default:"1m" validate:"gt=0"`
+	SchedulerInterval time.Duration `yaml:"scheduler_interval" env:"AMQP_SCHEDULER_INTERVAL" default:"1s" validate:"gt=0"`
+	// ConsumerConcurrency - how many messages each consumer handles at the same time, also its prefetch
+	ConsumerConcurrency int `yaml:"consumer_concurrency" env:"AMQP_CONSUMER_CONCURRENCY" default:"1" reload:"live" validate:"min=1"`
+}
+
+type MailConfig struct {
+	Host               string `yaml:"host" env:"SMTP_HOST" default:"localhost" validate:"required"`
+	Port               int    `yaml:"port" env:"SMTP_PORT" default:"25" validate:"min=1,max=65535"`
+	Username           string `yaml:"username" env:"SMTP_USERNAME"`
+	Password           Secret `yaml:"password" env:"SMTP_PASSWORD"`
+	From               string `yaml:"from" env:"SMTP_FROM" validate:"required,email"`
+	StartTLS           bool   `yaml:"starttls" env:"SMTP_STARTTLS"`
+	InsecureSkipVerify bool   `yaml:"tls_insecure_skip_verify" env:"SMTP_TLS_INSECURE_SKIP_VERIFY"`
+}
+
+type NotificationConfig struct {
+	Email string `yaml:"email" env:"NOTIFICATION_EMAIL" validate:"required,email"`
+}
+
+// Error - every invalid setting, one per line
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// New - load .env into the environment, then load the config from the optional yaml file and the environment
+func New(file string) (*Config, error) {
 	err := godotenv.Load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return Load(file, os.LookupEnv)
+}
+
+// Load - load the config from the defaults, the optional yaml file and lookup, then validate it.
+// A setting is also read from the file named by its _FILE variable, or from the encrypted secrets file.
+func Load(file string, lookup func(name string) (string, bool)) (*Config, error) {
+	cfg := &Config{}
+	problems := []string{}
+
+	walk(reflect.ValueOf(cfg).Elem(), func(field reflect.Value, tag reflect.StructTag) {
+		if value, ok := tag.Lookup("default"); ok {
+			if err := set(field, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s default: %v", tag.Get("env"), err))
+			}
+		}
+	})
+
+	if file != "" {
+		err := decodeFile(file, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lookup, err := withSecrets(lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	walk(reflect.ValueOf(cfg).Elem(), func(field reflect.Value, tag reflect.StructTag) {
+		name := tag.Get("env")
+		value, err := lookupSetting(name, lookup)
+		if err != nil {
+			problems = append(problems, err.Error())
+			return
+		}
+		if value == "" {
+			return
+		}
+
+		if err := set(field, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	})
+
+	problems = append(problems, validate(cfg)...)
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+
+	return cfg, nil
+}
+
+func decodeFile(file string, cfg *Config) error {
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+	default:
+		return fmt.Errorf("config file %s: only yaml is supported", file)
+	}
+
+	f, err := os.Open(file)
 	if err != nil {
-		logrus.Error(err)
+		return err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	err = decoder.Decode(cfg)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", file, err)
 	}
 
 	return nil
 }
+
+// walk - call fn with every setting of the struct
+func walk(v reflect.Value, fn func(field reflect.Value, tag reflect.StructTag)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if group(field) {
+			walk(field, fn)
+			continue
+		}
+
+		fn(field, t.Field(i).Tag)
+	}
+}
+
+// group - whether the field is a struct of settings, a struct which parses itself is one setting
+func group(field reflect.Value) bool {
+	if field.Kind() != reflect.Struct {
+		return false
+	}
+
+	_, ok := field.Addr().Interface().(encoding.TextUnmarshaler)
+	return !ok
+}
+
+// set - parse the value into the field
+func set(field reflect.Value, value string) error {
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as duration", value)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as bool", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as integer", value)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as number", value)
+		}
+		field.SetFloat(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot parse %q as positive integer", value)
+		}
+		field.SetUint(n)
+	case reflect.Map:
+		settings := reflect.MakeMap(field.Type())
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			name, setting, _ := strings.Cut(pair, "=")
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := set(elem, strings.TrimSpace(setting)); err != nil {
+				return fmt.Errorf("cannot parse %q as name=value list: %v", value, err)
+			}
+			settings.SetMapIndex(reflect.ValueOf(strings.TrimSpace(name)), elem)
+		}
+		field.Set(settings)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+
+	return nil
+}
+
+// validate - readable problem of every setting failing its validate tag, named by its environment variable
+func validate(cfg *Config) []string {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("env")
+	})
+
+	err := v.Struct(cfg)
+	if err == nil {
+		return nil
+	}
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return []string{err.Error()}
+	}
+
+	problems := []string{}
+	for _, e := range errs {
+		switch e.Tag() {
+		case "required", "required_if", "required_without":
+			problems = append(problems, fmt.Sprintf("%s is required", e.Field()))
+		case "oneof":
+			problems = append(problems, fmt.Sprintf("%s must be one of %s, got %q", e.Field(), e.Param(), e.Value()))
+		case "email":
+			problems = append(problems, fmt.Sprintf("%s must be an email address, got %q", e.Field(), e.Value()))
+		case "min", "gt":
+			problems = append(problems, fmt.Sprintf("%s must be greater than %s, got %v", e.Field(), orEqual(e.Tag(), e.Param()), e.Value()))
+		case "max":
+			problems = append(problems, fmt.Sprintf("%s must be at most %s, got %v", e.Field(), e.Param(), e.Value()))
+		default:
+			problems = append(problems, fmt.Sprintf("%s failed on %s", e.Field(), e.Tag()))
+		}
+	}
+
+	return problems
+}
+
+func orEqual(tag, param string) string {
+	if tag == "min" {
+		return "or equal to " + param
+	}
+	return param
+}
+
+// HeaderMap - headers of the exports by name
+func (c OTLPConfig) HeaderMap() map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(c.Headers.Value(), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return headers
+}
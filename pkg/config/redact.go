@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Redacted - replacement of the secret values
+const Redacted = "******"
+
+// Redact - settings of the config by yaml name, with the secrets redacted, safe to log and to show
+func Redact(cfg *Config) map[string]interface{} {
+	return redact(reflect.ValueOf(cfg).Elem())
+}
+
+// LiveSettings - environment variables of the settings which are applied on reload
+func LiveSettings() []string {
+	names := []string{}
+	walk(reflect.ValueOf(&Config{}).Elem(), func(field reflect.Value, tag reflect.StructTag) {
+		if tag.Get("reload") == "live" {
+			names = append(names, tag.Get("env"))
+		}
+	})
+
+	return names
+}
+
+func redact(v reflect.Value) map[string]interface{} {
+	settings := map[string]interface{}{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		tag := t.Field(i).Tag
+		name, _, _ := strings.Cut(tag.Get("yaml"), ",")
+
+		if group(field) {
+			settings[name] = redact(field)
+			continue
+		}
+
+		// Secret, time.Duration and Rate
+		if s, ok := field.Interface().(fmt.Stringer); ok {
+			settings[name] = s.String()
+			continue
+		}
+		settings[name] = field.Interface()
+	}
+
+	return settings
+}
+
+// redactURL - replace the password of the url, or the user when it is a token without password
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return Redacted
+	}
+
+	if u.User == nil {
+		return value
+	}
+
+	// url escapes the "*" of the userinfo, so the placeholder is replaced afterwards
+	placeholder := "redacted"
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), placeholder)
+	} else {
+		u.User = url.User(placeholder)
+	}
+
+	return strings.Replace(u.String(), placeholder+"@", Redacted+"@", 1)
+}
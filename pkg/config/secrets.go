@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	// SecretsFileEnv - path of the encrypted secrets file
+	SecretsFileEnv = "SECRETS_FILE"
+	// SecretsKeyEnv - base64 key of the encrypted secrets file
+	SecretsKeyEnv = "SECRETS_KEY"
+	// FileSuffix - suffix of the variable holding the path of the file with the value of the setting
+	FileSuffix = "_FILE"
+
+	nonceSize = 24
+)
+
+var ErrDecrypt = errors.New("cannot decrypt secrets, wrong key or corrupted file")
+
+// Secret - setting which is redacted when printed, logged or marshalled, Value returns it
+type Secret string
+
+// Value - plain value of the secret
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String - the secret redacted, a url keeps everything but its credentials
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+
+	if strings.Contains(string(s), "://") {
+		return redactURL(string(s))
+	}
+
+	return Redacted
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", s.String())), nil
+}
+
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// GenerateKey - random base64 key for the secrets file
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Encrypt - seal the plaintext with NaCl secretbox, the output is the base64 nonce and box
+func Encrypt(key string, plaintext []byte) ([]byte, error) {
+	k, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [nonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+
+	box := secretbox.Seal(nonce[:], plaintext, &nonce, k)
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(box)))
+	base64.StdEncoding.Encode(out, box)
+
+	return append(out, '\n'), nil
+}
+
+// Decrypt - open the output of Encrypt
+func Decrypt(key string, data []byte) ([]byte, error) {
+	k, err := parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	box, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(box) < nonceSize {
+		return nil, ErrDecrypt
+	}
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], box[:nonceSize])
+	plaintext, ok := secretbox.Open(nil, box[nonceSize:], &nonce, k)
+	if !ok {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+func parseKey(key string) (*[32]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(b) != 32 {
+		return nil, fmt.Errorf("%s must be a base64 encoded 32 bytes key", SecretsKeyEnv)
+	}
+
+	var k [32]byte
+	copy(k[:], b)
+
+	return &k, nil
+}
+
+// withSecrets - lookup of the settings which falls back to the encrypted secrets file
+func withSecrets(lookup func(name string) (string, bool)) (func(name string) (string, bool), error) {
+	file, err := lookupSetting(SecretsFileEnv, lookup)
+	if err != nil || file == "" {
+		return lookup, err
+	}
+
+	key, err := lookupSetting(SecretsKeyEnv, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, fmt.Errorf("%s is required to decrypt %s", SecretsKeyEnv, file)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := Decrypt(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	secrets, err := godotenv.Unmarshal(string(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return func(name string) (string, bool) {
+		if value, ok := lookup(name); ok && value != "" {
+			return value, true
+		}
+
+		// The file of the _FILE variable wins over the secrets file
+		if file, _ := lookup(name + FileSuffix); file != "" {
+			return "", false
+		}
+
+		value, ok := secrets[name]
+		return value, ok
+	}, nil
+}
+
+// lookupSetting - value of the variable, or the content of the file named by its _FILE variable,
+// e.g. a docker or kubernetes secret. Empty when neither is set.
+func lookupSetting(name string, lookup func(name string) (string, bool)) (string, error) {
+	value, _ := lookup(name)
+	file, _ := lookup(name + FileSuffix)
+	if file == "" {
+		return value, nil
+	}
+
+	if value != "" {
+		return "", fmt.Errorf("only one of %s and %s%s can be set", name, name, FileSuffix)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("%s%s: %w", name, FileSuffix, err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
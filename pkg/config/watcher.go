@@ -0,0 +1,165 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// WatchInterval - how often the config file is checked for changes
+const WatchInterval = 5 * time.Second
+
+// Logger - logging of the reloads, implemented by logger.Logger
+type Logger interface {
+	Info(ctx context.Context, msg string, fields ...interface{})
+	Warn(ctx context.Context, msg string, fields ...interface{})
+	Error(ctx context.Context, msg string, fields ...interface{})
+}
+
+// Watcher - current config, reloaded on SIGHUP and when the config file changes
+type Watcher interface {
+	// Current returns the config in use, it must not be modified
+	Current() *Config
+	// Subscribe calls fn with the new config after a reload changed a live setting
+	Subscribe(fn func(cfg *Config))
+	// Reload loads the config again, the changes of restart-only settings are kept for the next start
+	Reload() error
+	// Run reloads the config until ctx is done
+	Run(ctx context.Context)
+}
+
+type WatcherImpl struct {
+	file        string
+	logger      Logger
+	lookup      func(name string) (string, bool)
+	mu          sync.RWMutex
+	current     *Config
+	subscribers []func(cfg *Config)
+}
+
+// NewWatcher - make watcher of the config loaded from file and the environment
+func NewWatcher(file string, cfg *Config, logger Logger) Watcher {
+	return NewWatcherWithLookup(file, cfg, logger, os.LookupEnv)
+}
+
+// NewWatcherWithLookup - make watcher which reloads the config from file and lookup
+func NewWatcherWithLookup(file string, cfg *Config, logger Logger, lookup func(name string) (string, bool)) Watcher {
+	return &WatcherImpl{
+		file:    file,
+		logger:  logger,
+		lookup:  lookup,
+		current: cfg,
+	}
+}
+
+func (w *WatcherImpl) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.current
+}
+
+func (w *WatcherImpl) Subscribe(fn func(cfg *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Reload - load the config, keep the restart-only settings of the current one and notify the subscribers
+// when a live setting changed. The current config stays in use when the new one is invalid.
+func (w *WatcherImpl) Reload() error {
+	next, err := Load(w.file, w.lookup)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	current := w.current
+	changed := false
+	walkPair(reflect.ValueOf(current).Elem(), reflect.ValueOf(next).Elem(), func(before, after reflect.Value, tag reflect.StructTag) {
+		if reflect.DeepEqual(before.Interface(), after.Interface()) {
+			return
+		}
+
+		if tag.Get("reload") == "live" {
+			w.logger.Info(context.Background(), "config setting changed", "setting", tag.Get("env"))
+			changed = true
+			return
+		}
+
+		w.logger.Warn(context.Background(), "config setting changed, restart to apply it", "setting", tag.Get("env"))
+		after.Set(before)
+	})
+	w.current = next
+	subscribers := append([]func(cfg *Config){}, w.subscribers...)
+	w.mu.Unlock()
+
+	if changed {
+		for _, fn := range subscribers {
+			fn(next)
+		}
+	}
+
+	return nil
+}
+
+// Run - reload on SIGHUP and when the modification time of the config file changes
+func (w *WatcherImpl) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+
+	modTime := w.modTime()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			modTime = w.modTime()
+		case <-ticker.C:
+			t := w.modTime()
+			if t.Equal(modTime) {
+				continue
+			}
+			modTime = t
+		}
+
+		if err := w.Reload(); err != nil {
+			w.logger.Error(ctx, "config reload failed, keeping the current config", "error", err)
+		}
+	}
+}
+
+func (w *WatcherImpl) modTime() time.Time {
+	if w.file == "" {
+		return time.Time{}
+	}
+
+	info, err := os.Stat(w.file)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// walkPair - call fn with every setting of both structs of the same type
+func walkPair(a, b reflect.Value, fn func(a, b reflect.Value, tag reflect.StructTag)) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if group(a.Field(i)) {
+			walkPair(a.Field(i), b.Field(i), fn)
+			continue
+		}
+
+		fn(a.Field(i), b.Field(i), t.Field(i).Tag)
+	}
+}
@@ -0,0 +1,174 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	config "go-rengan/pkg/config"
+	mongodb "go-rengan/pkg/mongodb"
+	timeutil "go-rengan/utils/time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionName = "processed_messages"
+
+const (
+	statusProcessing = "processing"
+	statusProcessed  = "processed"
+)
+
+// codeIndexOptionsConflict - an index on the same keys exists with other options
+const codeIndexOptionsConflict = 85
+
+// pollInterval - how often a claim waiting for the lease of another consumer checks it again
+const pollInterval = time.Second
+
+// Deduplicator - record processed message ids per consumer
+type Deduplicator interface {
+	// Claim leases the message to the consumer, it returns false when the message was already processed.
+	// While another delivery of the message holds an unexpired lease, Claim waits for it to complete or expire
+	Claim(ctx context.Context, consumer string, messageID string) (bool, error)
+	// Complete marks the claimed message as processed, its redeliveries are skipped
+	Complete(ctx context.Context, consumer string, messageID string) error
+	// Release forgets the claim so a redelivery of the message is processed again
+	Release(ctx context.Context, consumer string, messageID string) error
+}
+
+type DeduplicatorImpl struct {
+	lease   time.Duration
+	mongoDB mongodb.MongoDB
+}
+
+// processedMessage - message claimed by a consumer, expired by the ttl index on processedAt.
+// A message in processing whose lease ended was claimed by a consumer which stopped before completing it
+type processedMessage struct {
+	ID          string    `bson:"_id"`
+	Consumer    string    `bson:"consumer"`
+	MessageID   string    `bson:"messageId"`
+	Status      string    `bson:"status"`
+	LeaseUntil  time.Time `bson:"leaseUntil"`
+	ProcessedAt time.Time `bson:"processedAt"`
+}
+
+// New - make deduplicator and ensure the ttl index of the processed messages collection,
+// a processed message id is remembered for the dedup ttl
+func New(cfg config.AMQPConfig, mongoDB mongodb.MongoDB) (Deduplicator, error) {
+	d := &DeduplicatorImpl{
+		lease:   cfg.DedupLease,
+		mongoDB: mongoDB,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := d.ensureTTLIndex(ctx, cfg.DedupTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// ensureTTLIndex - create the ttl index, or change its expiry when the dedup ttl changed since it was created
+func (d *DeduplicatorImpl) ensureTTLIndex(ctx context.Context, ttl time.Duration) error {
+	keys := bson.D{{Key: "processedAt", Value: 1}}
+	seconds := int32(ttl.Seconds())
+
+	_, err := d.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetExpireAfterSeconds(seconds),
+	})
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != codeIndexOptionsConflict {
+		return err
+	}
+
+	return d.mongoDB.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collectionName},
+		{Key: "index", Value: bson.D{{Key: "keyPattern", Value: keys}, {Key: "expireAfterSeconds", Value: seconds}}},
+	}).Err()
+}
+
+// Claim - insert the message in processing, the unique _id makes concurrent claims of the same id fail atomically.
+// A lease which ended is taken over, a lease in progress is waited for
+func (d *DeduplicatorImpl) Claim(ctx context.Context, consumer string, messageID string) (bool, error) {
+	for {
+		now := timeutil.GetTimeNow()
+		_, err := d.collection().InsertOne(ctx, &processedMessage{
+			ID:          key(consumer, messageID),
+			Consumer:    consumer,
+			MessageID:   messageID,
+			Status:      statusProcessing,
+			LeaseUntil:  now.Add(d.lease),
+			ProcessedAt: now,
+		})
+		if err == nil {
+			return true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return false, err
+		}
+
+		// Take over the lease of a consumer which stopped while processing the message
+		res, err := d.collection().UpdateOne(ctx,
+			bson.M{"_id": key(consumer, messageID), "status": statusProcessing, "leaseUntil": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"leaseUntil": now.Add(d.lease), "processedAt": now}},
+		)
+		if err != nil {
+			return false, err
+		}
+		if res.MatchedCount > 0 {
+			return true, nil
+		}
+
+		existing := &processedMessage{}
+		err = d.collection().FindOne(ctx, bson.M{"_id": key(consumer, messageID)}).Decode(existing)
+		if err == mongo.ErrNoDocuments {
+			// Released or expired meanwhile, claim it again
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if existing.Status == statusProcessed {
+			return false, nil
+		}
+
+		wait := existing.LeaseUntil.Sub(now)
+		if wait > pollInterval {
+			wait = pollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Complete - mark the message processed, from then on the ttl index expires it after the dedup ttl
+func (d *DeduplicatorImpl) Complete(ctx context.Context, consumer string, messageID string) error {
+	_, err := d.collection().UpdateOne(ctx,
+		bson.M{"_id": key(consumer, messageID)},
+		bson.M{"$set": bson.M{"status": statusProcessed, "processedAt": timeutil.GetTimeNow()}, "$unset": bson.M{"leaseUntil": ""}},
+	)
+	return err
+}
+
+// Release - delete the message in processing
+func (d *DeduplicatorImpl) Release(ctx context.Context, consumer string, messageID string) error {
+	_, err := d.collection().DeleteOne(ctx, bson.M{"_id": key(consumer, messageID), "status": statusProcessing})
+	return err
+}
+
+func (d *DeduplicatorImpl) collection() *mongo.Collection {
+	return d.mongoDB.Database().Collection(collectionName)
+}
+
+func key(consumer string, messageID string) string {
+	return consumer + ":" + messageID
+}
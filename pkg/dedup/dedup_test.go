@@ -0,0 +1,174 @@
+package dedup_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "go-rengan/pkg/config"
+	"go-rengan/pkg/dedup"
+	logger "go-rengan/pkg/logger"
+	mongodb "go-rengan/pkg/mongodb"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+var cfg = config.AMQPConfig{DedupTTL: 72 * time.Hour, DedupLease: time.Minute}
+
+func newDeduplicator(mt *mtest.T, cfg config.AMQPConfig) dedup.Deduplicator {
+	mt.AddMockResponses(mtest.CreateSuccessResponse())
+	deduplicator, err := dedup.New(cfg, mongodb.NewWithClient(mt.Client, mt.DB.Name(), logger.NewNop()))
+	assert.NoError(mt, err)
+	mt.ClearEvents()
+
+	return deduplicator
+}
+
+func duplicateKey() bson.D {
+	return mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"})
+}
+
+func updated(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+func found(mt *mtest.T, status string, leaseUntil time.Time) bson.D {
+	return mtest.CreateCursorResponse(0, mt.DB.Name()+".processed_messages", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: "send_email:1"},
+		{Key: "status", Value: status},
+		{Key: "leaseUntil", Value: leaseUntil},
+	})
+}
+
+func TestNew(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("success when ttl index changed since it was created", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 85, Name: "IndexOptionsConflict", Message: "expireAfterSeconds differs"}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		_, err := dedup.New(config.AMQPConfig{DedupTTL: 24 * time.Hour, DedupLease: time.Minute}, mongodb.NewWithClient(mt.Client, mt.DB.Name(), logger.NewNop()))
+
+		assert.NoError(mt, err)
+		mt.GetStartedEvent()
+		collMod := mt.GetStartedEvent()
+		assert.Equal(mt, "collMod", collMod.CommandName)
+		assert.Equal(mt, int32(24*60*60), collMod.Command.Lookup("index", "expireAfterSeconds").Int32())
+	})
+
+	mt.Run("error when index creation fails", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 13, Name: "Unauthorized", Message: "not authorized"}))
+
+		_, err := dedup.New(cfg, mongodb.NewWithClient(mt.Client, mt.DB.Name(), logger.NewNop()))
+
+		assert.Error(mt, err)
+	})
+}
+
+func TestClaim(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("success when message is new", func(mt *mtest.T) {
+		deduplicator := newDeduplicator(mt, cfg)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		claimed, err := deduplicator.Claim(context.Background(), "send_email", "1")
+
+		assert.NoError(mt, err)
+		assert.True(mt, claimed)
+		doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(mt, "processing", doc.Lookup("status").StringValue())
+	})
+
+	mt.Run("success when lease of a stopped consumer expired", func(mt *mtest.T) {
+		deduplicator := newDeduplicator(mt, cfg)
+		mt.AddMockResponses(duplicateKey(), updated(1))
+
+		claimed, err := deduplicator.Claim(context.Background(), "send_email", "1")
+
+		assert.NoError(mt, err)
+		assert.True(mt, claimed)
+		mt.GetStartedEvent()
+		filter := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+		assert.Equal(mt, "processing", filter.Lookup("status").StringValue())
+		_, err = filter.LookupErr("leaseUntil", "$lte")
+		assert.NoError(mt, err)
+	})
+
+	mt.Run("success skip when message was processed", func(mt *mtest.T) {
+		deduplicator := newDeduplicator(mt, cfg)
+		mt.AddMockResponses(duplicateKey(), updated(0), found(mt, "processed", time.Time{}))
+
+		claimed, err := deduplicator.Claim(context.Background(), "send_email", "1")
+
+		assert.NoError(mt, err)
+		assert.False(mt, claimed)
+	})
+
+	mt.Run("success wait for the lease in progress", func(mt *mtest.T) {
+		deduplicator := newDeduplicator(mt, cfg)
+		mt.AddMockResponses(
+			duplicateKey(), updated(0), found(mt, "processing", time.Now().Add(20*time.Millisecond)),
+			duplicateKey(), updated(0), found(mt, "processed", time.Time{}),
+		)
+
+		start := time.Now()
+		claimed, err := deduplicator.Claim(context.Background(), "send_email", "1")
+
+		assert.NoError(mt, err)
+		assert.False(mt, claimed)
+		assert.GreaterOrEqual(mt, time.Since(start), 10*time.Millisecond)
+	})
+
+	mt.Run("error when context is done while waiting", func(mt *mtest.T) {
+		deduplicator := newDeduplicator(mt, cfg)
+		mt.AddMockResponses(duplicateKey(), updated(0), found(mt, "processing", time.Now().Add(time.Minute)))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		claimed, err := deduplicator.Claim(ctx, "send_email", "1")
+
+		assert.ErrorIs(mt, err, context.DeadlineExceeded)
+		assert.False(mt, claimed)
+	})
+}
+
+func TestComplete(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("success mark message processed", func(mt *mtest.T) {
+		deduplicator := newDeduplicator(mt, cfg)
+		mt.AddMockResponses(updated(1))
+
+		err := deduplicator.Complete(context.Background(), "send_email", "1")
+
+		assert.NoError(mt, err)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(mt, "send_email:1", update.Lookup("q", "_id").StringValue())
+		assert.Equal(mt, "processed", update.Lookup("u", "$set", "status").StringValue())
+	})
+}
+
+func TestRelease(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("success forget message in processing only", func(mt *mtest.T) {
+		deduplicator := newDeduplicator(mt, cfg)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+
+		err := deduplicator.Release(context.Background(), "send_email", "1")
+
+		assert.NoError(mt, err)
+		filter := mt.GetStartedEvent().Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q").Document()
+		assert.Equal(mt, "send_email:1", filter.Lookup("_id").StringValue())
+		assert.Equal(mt, "processing", filter.Lookup("status").StringValue())
+	})
+}
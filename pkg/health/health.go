@@ -0,0 +1,181 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	config "go-rengan/pkg/config"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// ErrShuttingDown - readiness of the server which is stopping
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// CheckFunc - check one dependency, an error marks the server as not ready
+type CheckFunc func(ctx context.Context) error
+
+// Result - outcome of one check
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report - readiness of the server, ok when every check is ok
+type Report struct {
+	Status    string            `json:"status"`
+	Checks    map[string]Result `json:"checks"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+// OK - whether every check passed
+func (r *Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type Health interface {
+	// Register adds the check of the dependency to the readiness
+	Register(name string, check CheckFunc)
+	// Ready runs the checks at most once per cache interval and reports their results
+	Ready(ctx context.Context) *Report
+	// Shutdown makes the readiness fail from now on, so the load balancer stops sending requests
+	Shutdown()
+}
+
+type HealthImpl struct {
+	cacheInterval time.Duration
+	checkTimeout  time.Duration
+
+	mu           sync.Mutex
+	checks       map[string]CheckFunc
+	last         *Report
+	shuttingDown bool
+}
+
+// New - make health with no check, ready until it is shut down
+func New(cfg config.HealthConfig) Health {
+	return &HealthImpl{
+		cacheInterval: cfg.CacheInterval,
+		checkTimeout:  cfg.CheckTimeout,
+		checks:        map[string]CheckFunc{},
+	}
+}
+
+func (h *HealthImpl) Register(name string, check CheckFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks[name] = check
+	h.last = nil
+}
+
+func (h *HealthImpl) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.shuttingDown = true
+}
+
+// Ready - the probes arriving while the checks run wait for their report instead of running them again
+func (h *HealthImpl) Ready(ctx context.Context) *Report {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.shuttingDown {
+		return &Report{
+			Status:    StatusFail,
+			Checks:    map[string]Result{"shutdown": {Status: StatusFail, Error: ErrShuttingDown.Error()}},
+			CheckedAt: time.Now(),
+		}
+	}
+
+	if h.last != nil && time.Since(h.last.CheckedAt) < h.cacheInterval {
+		return h.last
+	}
+
+	// The report is shared by every probe, the cancellation of this one must not fail the checks of the others
+	h.last = h.run(detached{ctx})
+
+	return h.last
+}
+
+// detached - values of the probe context without its deadline and cancellation
+type detached struct {
+	ctx context.Context
+}
+
+func (d detached) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (d detached) Done() <-chan struct{}             { return nil }
+func (d detached) Err() error                        { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.ctx.Value(key) }
+
+// run - run the checks concurrently, each within the check timeout
+func (h *HealthImpl) run(ctx context.Context) *Report {
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]Result, len(names))
+	wg := sync.WaitGroup{}
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check CheckFunc) {
+			defer wg.Done()
+			results[i] = h.check(ctx, check)
+		}(i, h.checks[name])
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status:    StatusOK,
+		Checks:    make(map[string]Result, len(names)),
+		CheckedAt: time.Now(),
+	}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+
+	return report
+}
+
+func (h *HealthImpl) check(ctx context.Context, check CheckFunc) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- check(ctx)
+	}()
+
+	// A check which ignores ctx does not hold the report past the timeout
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,110 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	config "go-rengan/pkg/config"
+	health "go-rengan/pkg/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var healthConfig = config.HealthConfig{CacheInterval: time.Minute, CheckTimeout: 50 * time.Millisecond}
+
+func TestReady(t *testing.T) {
+	t.Run("success when every check passes", func(t *testing.T) {
+		h := health.New(healthConfig)
+		h.Register("mongo", func(ctx context.Context) error { return nil })
+		h.Register("amqp", func(ctx context.Context) error { return nil })
+
+		report := h.Ready(context.Background())
+
+		assert.True(t, report.OK())
+		assert.Equal(t, health.StatusOK, report.Checks["mongo"].Status)
+		assert.Equal(t, health.StatusOK, report.Checks["amqp"].Status)
+	})
+
+	t.Run("failed when one check fails", func(t *testing.T) {
+		h := health.New(healthConfig)
+		h.Register("mongo", func(ctx context.Context) error { return nil })
+		h.Register("amqp", func(ctx context.Context) error { return errors.New("amqp connection is closed") })
+
+		report := h.Ready(context.Background())
+
+		assert.False(t, report.OK())
+		assert.Equal(t, health.StatusOK, report.Checks["mongo"].Status)
+		assert.Equal(t, health.StatusFail, report.Checks["amqp"].Status)
+		assert.Equal(t, "amqp connection is closed", report.Checks["amqp"].Error)
+	})
+
+	t.Run("failed when check exceeds the timeout", func(t *testing.T) {
+		h := health.New(healthConfig)
+		h.Register("mongo", func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		})
+
+		start := time.Now()
+		report := h.Ready(context.Background())
+
+		assert.False(t, report.OK())
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["mongo"].Error)
+	})
+
+	t.Run("success when the probe is cancelled the cached report is not failed", func(t *testing.T) {
+		h := health.New(healthConfig)
+		h.Register("mongo", func(ctx context.Context) error { return ctx.Err() })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		report := h.Ready(ctx)
+
+		assert.True(t, report.OK())
+		assert.True(t, h.Ready(context.Background()).OK())
+	})
+
+	t.Run("success reuse report within the cache interval", func(t *testing.T) {
+		calls := int32(0)
+		h := health.New(healthConfig)
+		h.Register("mongo", func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		})
+
+		h.Ready(context.Background())
+		h.Ready(context.Background())
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("success rerun checks after the cache interval", func(t *testing.T) {
+		calls := int32(0)
+		h := health.New(config.HealthConfig{CheckTimeout: time.Second})
+		h.Register("mongo", func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		})
+
+		h.Ready(context.Background())
+		h.Ready(context.Background())
+
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("failed when shutting down", func(t *testing.T) {
+		h := health.New(healthConfig)
+		h.Register("mongo", func(ctx context.Context) error { return nil })
+		assert.True(t, h.Ready(context.Background()).OK())
+
+		h.Shutdown()
+		report := h.Ready(context.Background())
+
+		assert.False(t, report.OK())
+		assert.Equal(t, health.ErrShuttingDown.Error(), report.Checks["shutdown"].Error)
+	})
+}
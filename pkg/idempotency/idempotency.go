@@ -0,0 +1,235 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+	logger "go-rengan/pkg/logger"
+	tenant "go-rengan/pkg/tenant"
+	responseutil "go-rengan/utils/response"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	// Header - key the client sends with a request it may retry
+	Header = "Idempotency-Key"
+	// HeaderReplayed - set on the replayed responses
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+const (
+	maxKeyLength = 255
+	// maxBodySize - the body is read to compare it with the body of the first request
+	maxBodySize = 1 << 20
+)
+
+// replayedHeaders - response headers which are stored and replayed with the body
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Response - stored response of a request
+type Response struct {
+	Status int               `bson:"status"`
+	Header map[string]string `bson:"header,omitempty"`
+	Body   []byte            `bson:"body"`
+}
+
+// Record - request of a key, in progress until it has a response
+type Record struct {
+	// Key - hash of the tenant, the user and the Idempotency-Key of the request
+	Key string `bson:"_id"`
+	// Fingerprint - hash of the method, the path with its query and the body of the request
+	Fingerprint string    `bson:"fingerprint"`
+	Response    *Response `bson:"response,omitempty"`
+	ExpiresAt   time.Time `bson:"expiresAt"`
+}
+
+// Store - records of the requests sent with an Idempotency-Key
+type Store interface {
+	// Lock stores the record of a request in progress, unless the key has a record which is not expired, which it returns
+	Lock(ctx context.Context, record *Record, now time.Time) (*Record, error)
+	// Complete stores the response of the request in progress
+	Complete(ctx context.Context, key string, response *Response, expiresAt time.Time) error
+	// Release removes the record of the request in progress, so the key can be sent again
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotency - replay the response of a request sent again with the same Idempotency-Key
+type Idempotency interface {
+	// Middleware opts the route in, the requests without Idempotency-Key are served as usual
+	Middleware(next http.Handler) http.Handler
+}
+
+type IdempotencyImpl struct {
+	cfg    config.IdempotencyConfig
+	logger logger.Logger
+	store  Store
+	now    func() time.Time
+}
+
+// New - make idempotency of the requests, their responses kept in store
+func New(cfg config.IdempotencyConfig, logger logger.Logger, store Store) Idempotency {
+	return &IdempotencyImpl{
+		cfg:    cfg,
+		logger: logger,
+		store:  store,
+		now:    time.Now,
+	}
+}
+
+// Middleware - the first request of a key is served and its response stored, a request sent again with the key
+// gets the stored response, a 409 while the first one is in progress or a 422 when it is another request.
+// A server error is not stored, the request can be retried with the same key
+func (i *IdempotencyImpl) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			responseutil.BadRequest(w, r, "Invalid "+Header+" header")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			responseutil.ErrorBody(w, r, err)
+			return
+		}
+		if len(body) > maxBodySize {
+			responseutil.BadRequest(w, r, "Request body too large for an "+Header)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := i.now()
+		record := &Record{
+			Key:         scopedKey(r, key),
+			Fingerprint: fingerprint(r, body),
+			ExpiresAt:   now.Add(i.cfg.LockTimeout),
+		}
+		existing, err := i.store.Lock(r.Context(), record, now)
+		if err != nil {
+			responseutil.ErrorInternal(w, r, err)
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != record.Fingerprint:
+				responseutil.UnprocessableEntity(w, r, Header+" was sent with another request")
+			case existing.Response == nil:
+				responseutil.Conflict(w, r, "A request with this "+Header+" is in progress")
+			default:
+				replay(w, existing.Response)
+			}
+			return
+		}
+
+		i.serve(w, r, next, record.Key)
+	})
+}
+
+// serve - serve the request and store its response. The client may be gone when it retries on a timeout,
+// so the record is not bound to the context of the request
+func (i *IdempotencyImpl) serve(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	body := &bytes.Buffer{}
+	ww.Tee(body)
+
+	stored := false
+	defer func() {
+		if stored {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := i.store.Release(ctx, key); err != nil {
+			i.logger.Error(r.Context(), "idempotency key release failed", "error", err)
+		}
+	}()
+
+	next.ServeHTTP(ww, r)
+
+	status := ww.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status >= http.StatusInternalServerError {
+		return
+	}
+
+	response := &Response{
+		Status: status,
+		Header: map[string]string{},
+		Body:   body.Bytes(),
+	}
+	for _, name := range replayedHeaders {
+		if value := ww.Header().Get(name); value != "" {
+			response.Header[name] = value
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := i.store.Complete(ctx, key, response, i.now().Add(i.cfg.TTL)); err != nil {
+		i.logger.Error(r.Context(), "idempotent response not stored", "error", err)
+		return
+	}
+	stored = true
+}
+
+func replay(w http.ResponseWriter, response *Response) {
+	for name, value := range response.Header {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(response.Status)
+	_, _ = w.Write(response.Body)
+}
+
+// scopedKey - key of the record, the clients only share a key within the same tenant and user
+func scopedKey(r *http.Request, key string) string {
+	tenantID, _ := tenant.FromContext(r.Context())
+	subject := ""
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		subject = principal.Subject
+	}
+
+	return hash(tenantID, subject, key)
+}
+
+// fingerprint - the request a key was sent with, the key cannot be reused for another one
+func fingerprint(r *http.Request, body []byte) string {
+	return hash(r.Method, r.URL.RequestURI(), string(body))
+}
+
+func hash(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NoopImpl - idempotency which serves every request as usual, for tests
+type NoopImpl struct{}
+
+// NewNoop - make idempotency which does nothing
+func NewNoop() Idempotency {
+	return &NoopImpl{}
+}
+
+func (n *NoopImpl) Middleware(next http.Handler) http.Handler {
+	return next
+}
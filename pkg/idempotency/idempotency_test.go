@@ -0,0 +1,186 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+	idempotency "go-rengan/pkg/idempotency"
+	logger "go-rengan/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var cfg = config.IdempotencyConfig{TTL: 24 * time.Hour, LockTimeout: time.Minute}
+
+type fakeStore struct {
+	mu      sync.Mutex
+	records map[string]*idempotency.Record
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{records: map[string]*idempotency.Record{}}
+}
+
+func (s *fakeStore) Lock(ctx context.Context, record *idempotency.Record, now time.Time) (*idempotency.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.records[record.Key]; ok && existing.ExpiresAt.After(now) {
+		return existing, nil
+	}
+	copied := *record
+	s.records[record.Key] = &copied
+	return nil, nil
+}
+
+func (s *fakeStore) Complete(ctx context.Context, key string, response *idempotency.Response, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key].Response = response
+	s.records[key].ExpiresAt = expiresAt
+	return nil
+}
+
+func (s *fakeStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// counter - handler which counts its calls and replies with status
+type counter struct {
+	calls  int
+	status int
+}
+
+func (c *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.calls++
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/todo/1")
+	w.WriteHeader(c.status)
+	_, _ = w.Write([]byte(`{"id":"1"}`))
+}
+
+func send(handler http.Handler, method string, path string, key string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(idempotency.Header, key)
+	}
+	r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "user-1"}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("when replay the response of a retry", func(t *testing.T) {
+		next := &counter{status: http.StatusCreated}
+		handler := idempotency.New(cfg, logger.NewNop(), newFakeStore()).Middleware(next)
+
+		first := send(handler, http.MethodPost, "/todo", "key-1", `{"title":"Buy milk"}`)
+		retry := send(handler, http.MethodPost, "/todo", "key-1", `{"title":"Buy milk"}`)
+
+		assert.Equal(t, 1, next.calls)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, "/todo/1", retry.Header().Get("Location"))
+		assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+		assert.Equal(t, "true", retry.Header().Get(idempotency.HeaderReplayed))
+		assert.Empty(t, first.Header().Get(idempotency.HeaderReplayed))
+	})
+
+	t.Run("when serve every request without key", func(t *testing.T) {
+		next := &counter{status: http.StatusCreated}
+		handler := idempotency.New(cfg, logger.NewNop(), newFakeStore()).Middleware(next)
+
+		send(handler, http.MethodPost, "/todo", "", `{}`)
+		send(handler, http.MethodPost, "/todo", "", `{}`)
+
+		assert.Equal(t, 2, next.calls)
+	})
+
+	t.Run("when return 422 unprocessable entity (key of another request)", func(t *testing.T) {
+		next := &counter{status: http.StatusCreated}
+		handler := idempotency.New(cfg, logger.NewNop(), newFakeStore()).Middleware(next)
+
+		send(handler, http.MethodPost, "/todo", "key-1", `{"title":"Buy milk"}`)
+		w := send(handler, http.MethodPost, "/todo", "key-1", `{"title":"Buy bread"}`)
+
+		assert.Equal(t, 1, next.calls)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("when return 422 unprocessable entity (key of another query)", func(t *testing.T) {
+		next := &counter{status: http.StatusOK}
+		handler := idempotency.New(cfg, logger.NewNop(), newFakeStore()).Middleware(next)
+
+		send(handler, http.MethodPut, "/todo/1?notify=true", "key-1", `{"title":"Buy milk"}`)
+		w := send(handler, http.MethodPut, "/todo/1?notify=false", "key-1", `{"title":"Buy milk"}`)
+
+		assert.Equal(t, 1, next.calls)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("when return 409 conflict (request in progress)", func(t *testing.T) {
+		var handler http.Handler
+		var retry *httptest.ResponseRecorder
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			retry = send(handler, http.MethodPost, "/todo", "key-1", `{}`)
+			w.WriteHeader(http.StatusCreated)
+		})
+		handler = idempotency.New(cfg, logger.NewNop(), newFakeStore()).Middleware(next)
+
+		w := send(handler, http.MethodPost, "/todo", "key-1", `{}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, http.StatusConflict, retry.Code)
+	})
+
+	t.Run("when release the key of a server error", func(t *testing.T) {
+		next := &counter{status: http.StatusInternalServerError}
+		store := newFakeStore()
+		handler := idempotency.New(cfg, logger.NewNop(), store).Middleware(next)
+
+		send(handler, http.MethodPost, "/todo", "key-1", `{}`)
+		next.status = http.StatusCreated
+		w := send(handler, http.MethodPost, "/todo", "key-1", `{}`)
+
+		assert.Equal(t, 2, next.calls)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get(idempotency.HeaderReplayed))
+	})
+
+	t.Run("when keep the keys of the users apart", func(t *testing.T) {
+		next := &counter{status: http.StatusCreated}
+		handler := idempotency.New(cfg, logger.NewNop(), newFakeStore()).Middleware(next)
+
+		send(handler, http.MethodPost, "/todo", "key-1", `{}`)
+		r := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(`{}`))
+		r.Header.Set(idempotency.Header, "key-1")
+		r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "user-2"}))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		assert.Equal(t, 2, next.calls)
+	})
+
+	t.Run("when return 400 bad request (key too long)", func(t *testing.T) {
+		next := &counter{status: http.StatusCreated}
+		handler := idempotency.New(cfg, logger.NewNop(), newFakeStore()).Middleware(next)
+
+		w := send(handler, http.MethodPost, "/todo", strings.Repeat("k", 256), `{}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 0, next.calls)
+	})
+}
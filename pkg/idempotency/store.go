@@ -0,0 +1,93 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	mongodb "go-rengan/pkg/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionName = "idempotency_keys"
+
+// lockAttempts - how many times Lock tries to take over an expired record which another request also takes
+const lockAttempts = 3
+
+// ErrContended - the key was taken and expired again while Lock tried to take it
+var ErrContended = errors.New("idempotency key contended")
+
+type MongoStore struct {
+	mongoDB mongodb.MongoDB
+}
+
+// NewMongoStore - make mongo store and ensure the expiry index of the records
+func NewMongoStore(mongoDB mongodb.MongoDB) (Store, error) {
+	s := &MongoStore{
+		mongoDB: mongoDB,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Lock - insert the record, the unique _id keeps the concurrent requests of a key from all taking it.
+// The expired records are taken over, the ttl monitor removes them only once a minute
+func (s *MongoStore) Lock(ctx context.Context, record *Record, now time.Time) (*Record, error) {
+	for attempt := 0; attempt < lockAttempts; attempt++ {
+		_, err := s.collection().InsertOne(ctx, record)
+		if err == nil {
+			return nil, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+
+		existing := &Record{}
+		err = s.collection().FindOne(ctx, bson.M{"_id": record.Key}).Decode(existing)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if existing.ExpiresAt.After(now) {
+			return existing, nil
+		}
+
+		_, err = s.collection().DeleteOne(ctx, bson.M{"_id": record.Key, "expiresAt": existing.ExpiresAt})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ErrContended
+}
+
+func (s *MongoStore) Complete(ctx context.Context, key string, response *Response, expiresAt time.Time) error {
+	_, err := s.collection().UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"response": response, "expiresAt": expiresAt}})
+	return err
+}
+
+func (s *MongoStore) Release(ctx context.Context, key string) error {
+	_, err := s.collection().DeleteOne(ctx, bson.M{"_id": key, "response": bson.M{"$exists": false}})
+	return err
+}
+
+func (s *MongoStore) collection() *mongo.Collection {
+	return s.mongoDB.Database().Collection(collectionName)
+}
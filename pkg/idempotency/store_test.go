@@ -0,0 +1,164 @@
+package idempotency_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	idempotency "go-rengan/pkg/idempotency"
+	logger "go-rengan/pkg/logger"
+	mongodb "go-rengan/pkg/mongodb"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func newMongoStore(mt *mtest.T) idempotency.Store {
+	mt.AddMockResponses(mtest.CreateSuccessResponse())
+	store, err := idempotency.NewMongoStore(mongodb.NewWithClient(mt.Client, mt.DB.Name(), logger.NewNop()))
+	assert.NoError(mt, err)
+	mt.ClearEvents()
+
+	return store
+}
+
+func duplicateKey() bson.D {
+	return mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"})
+}
+
+func found(mt *mtest.T, expiresAt time.Time, response *bson.D) bson.D {
+	doc := bson.D{
+		{Key: "_id", Value: "key-1"},
+		{Key: "fingerprint", Value: "request-1"},
+		{Key: "expiresAt", Value: expiresAt},
+	}
+	if response != nil {
+		doc = append(doc, bson.E{Key: "response", Value: *response})
+	}
+
+	return mtest.CreateCursorResponse(0, mt.DB.Name()+".idempotency_keys", mtest.FirstBatch, doc)
+}
+
+func deleted(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n})
+}
+
+func TestMongoStoreLock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := func() *idempotency.Record {
+		return &idempotency.Record{Key: "key-1", Fingerprint: "request-1", ExpiresAt: now.Add(time.Minute)}
+	}
+
+	mt.Run("success when key is new", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		existing, err := store.Lock(context.Background(), record(), now)
+
+		assert.NoError(mt, err)
+		assert.Nil(mt, existing)
+	})
+
+	mt.Run("success return record which is not expired", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		response := bson.D{{Key: "status", Value: 201}, {Key: "body", Value: []byte(`{"id":"1"}`)}}
+		mt.AddMockResponses(duplicateKey(), found(mt, now.Add(time.Hour), &response))
+
+		existing, err := store.Lock(context.Background(), record(), now)
+
+		assert.NoError(mt, err)
+		assert.Equal(mt, "request-1", existing.Fingerprint)
+		assert.Equal(mt, 201, existing.Response.Status)
+		assert.Equal(mt, `{"id":"1"}`, string(existing.Response.Body))
+	})
+
+	mt.Run("success take over expired record", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		expiredAt := now.Add(-time.Second)
+		mt.AddMockResponses(duplicateKey(), found(mt, expiredAt, nil), deleted(1), mtest.CreateSuccessResponse())
+
+		existing, err := store.Lock(context.Background(), record(), now)
+
+		assert.NoError(mt, err)
+		assert.Nil(mt, existing)
+
+		// Only the expired record is deleted, not one which another request took over meanwhile
+		events := []string{}
+		for e := mt.GetStartedEvent(); e != nil; e = mt.GetStartedEvent() {
+			events = append(events, e.CommandName)
+			if e.CommandName == "delete" {
+				filter := e.Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q").Document()
+				assert.Equal(mt, expiredAt, filter.Lookup("expiresAt").Time().UTC())
+			}
+		}
+		assert.Equal(mt, []string{"insert", "find", "delete", "insert"}, events)
+	})
+
+	mt.Run("success claim again when record is gone meanwhile", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(duplicateKey(), mtest.CreateCursorResponse(0, mt.DB.Name()+".idempotency_keys", mtest.FirstBatch), mtest.CreateSuccessResponse())
+
+		existing, err := store.Lock(context.Background(), record(), now)
+
+		assert.NoError(mt, err)
+		assert.Nil(mt, existing)
+	})
+
+	mt.Run("error contended when expired record is taken over by others every time", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		for i := 0; i < 3; i++ {
+			mt.AddMockResponses(duplicateKey(), found(mt, now.Add(-time.Second), nil), deleted(0))
+		}
+
+		existing, err := store.Lock(context.Background(), record(), now)
+
+		assert.ErrorIs(mt, err, idempotency.ErrContended)
+		assert.Nil(mt, existing)
+	})
+
+	mt.Run("error when insert fails", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Name: "BadValue", Message: "bad value"}))
+
+		_, err := store.Lock(context.Background(), record(), now)
+
+		assert.Error(mt, err)
+		assert.NotErrorIs(mt, err, idempotency.ErrContended)
+	})
+}
+
+func TestMongoStoreComplete(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("success store response", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := store.Complete(context.Background(), "key-1", &idempotency.Response{Status: 201, Body: []byte("{}")}, time.Now().Add(time.Hour))
+
+		assert.NoError(mt, err)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(mt, int32(201), update.Lookup("u", "$set", "response", "status").Int32())
+	})
+}
+
+func TestMongoStoreRelease(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("success release request in progress only", func(mt *mtest.T) {
+		store := newMongoStore(mt)
+		mt.AddMockResponses(deleted(1))
+
+		err := store.Release(context.Background(), "key-1")
+
+		assert.NoError(mt, err)
+		filter := mt.GetStartedEvent().Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q").Document()
+		assert.False(mt, filter.Lookup("response", "$exists").Boolean())
+	})
+}
@@ -1,29 +1,220 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	config "go-rengan/pkg/config"
+	tenant "go-rengan/pkg/tenant"
+	tracing "go-rengan/pkg/tracing"
+
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Logger - leveled structured logger. The fields are key value pairs, e.g. Info(ctx, "todo created", "id", id),
+// the trace_id and span_id of the span in ctx are added to every entry.
 type Logger interface {
-	Error(args ...interface{})
-	Println(args ...interface{})
-	Printf(format string, args ...interface{})
+	Debug(ctx context.Context, msg string, fields ...interface{})
+	Info(ctx context.Context, msg string, fields ...interface{})
+	Warn(ctx context.Context, msg string, fields ...interface{})
+	Error(ctx context.Context, msg string, fields ...interface{})
+	// With returns a logger which adds the fields to every entry
+	With(fields ...interface{}) Logger
+	// SetLevel changes the level of the logger and of the loggers made With it, debug, info, warn or error
+	SetLevel(level string) error
+	// Shutdown exports the entries which are not shipped yet
+	Shutdown(ctx context.Context) error
+}
+
+type LoggerImpl struct {
+	entry *logrus.Entry
+	hook  *OTLPHook
+}
+
+var std = NewWithWriter(config.AppConfig{}, config.LogConfig{}, os.Stdout)
+
+// New - make logger of the level, JSON in production and text otherwise unless the format is set.
+// With the otlp exporter the entries are shipped to the collector with the resource of the traces,
+// and written to stdout when the collector cannot keep up or fails.
+func New(app config.AppConfig, cfg config.LogConfig, otlp config.OTLPConfig) (Logger, error) {
+	if cfg.Exporter != ExporterOTLP {
+		return NewWithWriter(app, cfg, os.Stdout), nil
+	}
+
+	client, err := NewOTLPClient(otlp)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewOTLP(app, cfg, client, tracing.Resource(app), os.Stdout), nil
+}
+
+// NewWithWriter - make logger which writes the entries to w
+func NewWithWriter(app config.AppConfig, cfg config.LogConfig, w io.Writer) Logger {
+	l := logrus.New()
+	l.SetOutput(w)
+	l.SetFormatter(formatter(app, cfg))
+
+	return newLogger(app, cfg, l, nil)
+}
+
+// NewOTLP - make logger which exports the entries with client and the resource, fallback receives the entries
+// which cannot be exported
+func NewOTLP(app config.AppConfig, cfg config.LogConfig, client OTLPClient, res *resource.Resource, fallback io.Writer) Logger {
+	h := newOTLPHook(cfg, client, res, fallback, formatter(app, cfg))
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	l.SetFormatter(discardFormatter{})
+	l.AddHook(h)
+
+	return newLogger(app, cfg, l, h)
+}
+
+func newLogger(app config.AppConfig, cfg config.LogConfig, l *logrus.Logger, hook *OTLPHook) Logger {
+	entry := logrus.NewEntry(l)
+	if app.Name != "" {
+		entry = entry.WithField("service", app.Name)
+	}
+
+	logger := &LoggerImpl{entry: entry, hook: hook}
+	if cfg.Level != "" {
+		if err := logger.SetLevel(cfg.Level); err != nil {
+			logger.Warn(context.Background(), "unknown log level, logging at info", "level", cfg.Level)
+		}
+	}
+
+	return logger
+}
+
+// NewNop - make logger which discards every entry, for tests
+func NewNop() Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	return &LoggerImpl{entry: logrus.NewEntry(l)}
 }
 
-type LoggerImpl struct{}
+// Default - logger of the code without one injected, the one set by SetDefault
+func Default() Logger {
+	return std
+}
+
+// SetDefault - set the logger returned by Default
+func SetDefault(l Logger) {
+	std = l
+}
+
+func (l *LoggerImpl) Debug(ctx context.Context, msg string, fields ...interface{}) {
+	l.log(ctx, logrus.DebugLevel, msg, fields)
+}
+
+func (l *LoggerImpl) Info(ctx context.Context, msg string, fields ...interface{}) {
+	l.log(ctx, logrus.InfoLevel, msg, fields)
+}
+
+func (l *LoggerImpl) Warn(ctx context.Context, msg string, fields ...interface{}) {
+	l.log(ctx, logrus.WarnLevel, msg, fields)
+}
+
+func (l *LoggerImpl) Error(ctx context.Context, msg string, fields ...interface{}) {
+	l.log(ctx, logrus.ErrorLevel, msg, fields)
+}
+
+func (l *LoggerImpl) With(fields ...interface{}) Logger {
+	return &LoggerImpl{entry: l.entry.WithFields(toFields(fields)), hook: l.hook}
+}
 
-func New() Logger {
-	return &LoggerImpl{}
+func (l *LoggerImpl) SetLevel(level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.entry.Logger.SetLevel(lvl)
+
+	return nil
 }
 
-func (l *LoggerImpl) Error(args ...interface{}) {
-	logrus.Error(args...)
+func (l *LoggerImpl) Shutdown(ctx context.Context) error {
+	if l.hook == nil {
+		return nil
+	}
+
+	return l.hook.Shutdown(ctx)
 }
 
-func (l *LoggerImpl) Println(args ...interface{}) {
-	logrus.Println(args...)
+func (l *LoggerImpl) log(ctx context.Context, level logrus.Level, msg string, fields []interface{}) {
+	if !l.entry.Logger.IsLevelEnabled(level) {
+		return
+	}
+
+	f := toFields(fields)
+	if ctx != nil {
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			f["trace_id"] = sc.TraceID().String()
+			f["span_id"] = sc.SpanID().String()
+		}
+		if id, ok := tenant.FromContext(ctx); ok {
+			f["tenant"] = id
+		}
+	}
+
+	l.entry.WithFields(f).Log(level, msg)
 }
 
-func (l *LoggerImpl) Printf(format string, args ...interface{}) {
-	logrus.Printf(format, args...)
+// toFields - fields of the key value pairs, a value without key is logged under !BADKEY
+func toFields(kv []interface{}) logrus.Fields {
+	f := make(logrus.Fields, len(kv)/2+2)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 == len(kv) {
+			f["!BADKEY"] = kv[i]
+			break
+		}
+
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		f[key] = kv[i+1]
+	}
+
+	return f
+}
+
+// formatter - JSON in production and text otherwise unless the format is set
+func formatter(app config.AppConfig, cfg config.LogConfig) logrus.Formatter {
+	format := cfg.Format
+	if format == "" {
+		format = FormatText
+		if app.Env == "production" {
+			format = FormatJSON
+		}
+	}
+
+	if format == FormatJSON {
+		return &logrus.JSONFormatter{
+			FieldMap: logrus.FieldMap{logrus.FieldKeyMsg: "message"},
+		}
+	}
+
+	return &logrus.TextFormatter{}
+}
+
+// discardFormatter - formatter of the logger whose output is discarded
+type discardFormatter struct{}
+
+func (discardFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return nil, nil
 }
@@ -0,0 +1,432 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	config "go-rengan/pkg/config"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const scopeName = "go-rengan/pkg/logger"
+
+// OTLPClient - sends a batch of log records to the collector
+type OTLPClient interface {
+	Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error
+	Close() error
+}
+
+// OTLPHook - logrus hook which ships the entries to the collector in batches.
+// When the queue is full the entry is written to the fallback instead of blocking the caller,
+// a batch which cannot be exported is written to the fallback too.
+type OTLPHook struct {
+	client    OTLPClient
+	resource  *resourcepb.Resource
+	fallback  io.Writer
+	formatter logrus.Formatter
+	batchSize int
+	interval  time.Duration
+	timeout   time.Duration
+
+	queue   chan *logrus.Entry
+	flush   chan chan struct{}
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped uint64
+}
+
+// NewOTLPClient - make grpc or http/protobuf client of the collector
+func NewOTLPClient(cfg config.OTLPConfig) (OTLPClient, error) {
+	switch cfg.Protocol {
+	case "http/protobuf":
+		return newHTTPClient(cfg), nil
+	case "grpc", "":
+		return newGRPCClient(cfg)
+	default:
+		return nil, fmt.Errorf("unknown otlp protocol %q", cfg.Protocol)
+	}
+}
+
+// newOTLPHook - make hook exporting to client with the resource of the service, fallback receives the entries
+// formatted by formatter when they cannot be exported
+func newOTLPHook(cfg config.LogConfig, client OTLPClient, res *resource.Resource, fallback io.Writer, formatter logrus.Formatter) *OTLPHook {
+	h := &OTLPHook{
+		client:    client,
+		resource:  toResource(res),
+		fallback:  fallback,
+		formatter: formatter,
+		batchSize: cfg.BatchSize,
+		interval:  cfg.ExportInterval,
+		timeout:   cfg.ExportTimeout,
+		queue:     make(chan *logrus.Entry, cfg.QueueSize),
+		flush:     make(chan chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go h.run()
+
+	return h
+}
+
+func (h *OTLPHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire - enqueue the entry, it never blocks the logging goroutine
+func (h *OTLPHook) Fire(entry *logrus.Entry) error {
+	// logrus reuses the buffer of the entry once the hooks returned
+	entry = &logrus.Entry{
+		Logger:  entry.Logger,
+		Data:    entry.Data,
+		Time:    entry.Time,
+		Level:   entry.Level,
+		Message: entry.Message,
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		h.writeFallback([]*logrus.Entry{entry})
+		return nil
+	}
+
+	select {
+	case h.queue <- entry:
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+		h.writeFallback([]*logrus.Entry{entry})
+	}
+
+	return nil
+}
+
+// Dropped - number of entries written to the fallback because the queue was full
+func (h *OTLPHook) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// Flush - export the queued entries
+func (h *OTLPHook) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case h.flush <- flushed:
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown - export the queued entries and close the client, later entries go to the fallback
+func (h *OTLPHook) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.queue)
+	h.mu.Unlock()
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return h.client.Close()
+}
+
+func (h *OTLPHook) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	batch := make([]*logrus.Entry, 0, h.batchSize)
+	for {
+		select {
+		case entry, ok := <-h.queue:
+			if !ok {
+				h.export(batch)
+				return
+			}
+
+			batch = append(batch, entry)
+			if len(batch) >= h.batchSize {
+				h.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			h.export(batch)
+			batch = batch[:0]
+		case flushed := <-h.flush:
+			for n := len(h.queue); n > 0; n-- {
+				batch = append(batch, <-h.queue)
+			}
+			h.export(batch)
+			batch = batch[:0]
+			close(flushed)
+		}
+	}
+}
+
+func (h *OTLPHook) export(batch []*logrus.Entry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	records := make([]*logspb.LogRecord, len(batch))
+	for i, entry := range batch {
+		records[i] = toRecord(entry)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	err := h.client.Export(ctx, &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: h.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: scopeName},
+				LogRecords: records,
+			}},
+		}},
+	})
+	if err != nil {
+		fmt.Fprintf(h.fallback, "otlp log export failed, writing %d entries to stdout: %v\n", len(batch), err)
+		h.writeFallback(batch)
+	}
+}
+
+func (h *OTLPHook) writeFallback(entries []*logrus.Entry) {
+	for _, entry := range entries {
+		line, err := h.formatter.Format(entry)
+		if err != nil {
+			continue
+		}
+		_, _ = h.fallback.Write(line)
+	}
+}
+
+func toRecord(entry *logrus.Entry) *logspb.LogRecord {
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(entry.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(entry.Time.UnixNano()),
+		SeverityNumber:       severity(entry.Level),
+		SeverityText:         strings.ToUpper(entry.Level.String()),
+		Body:                 stringValue(entry.Message),
+	}
+
+	for key, value := range entry.Data {
+		switch key {
+		case "trace_id":
+			if id, err := trace.TraceIDFromHex(fmt.Sprint(value)); err == nil {
+				record.TraceId = id[:]
+			}
+			continue
+		case "span_id":
+			if id, err := trace.SpanIDFromHex(fmt.Sprint(value)); err == nil {
+				record.SpanId = id[:]
+			}
+			continue
+		case "service":
+			// service.name of the resource
+			continue
+		}
+
+		record.Attributes = append(record.Attributes, &commonpb.KeyValue{Key: key, Value: anyValue(value)})
+	}
+
+	return record
+}
+
+func severity(level logrus.Level) logspb.SeverityNumber {
+	switch level {
+	case logrus.TraceLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_TRACE
+	case logrus.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case logrus.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case logrus.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case logrus.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	}
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+func anyValue(value interface{}) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case string:
+		return stringValue(v)
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case uint64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case error:
+		return stringValue(v.Error())
+	case fmt.Stringer:
+		return stringValue(v.String())
+	default:
+		return stringValue(fmt.Sprint(v))
+	}
+}
+
+func toResource(res *resource.Resource) *resourcepb.Resource {
+	pb := &resourcepb.Resource{}
+	if res == nil {
+		return pb
+	}
+
+	for _, kv := range res.Attributes() {
+		pb.Attributes = append(pb.Attributes, &commonpb.KeyValue{Key: string(kv.Key), Value: attributeValue(kv.Value)})
+	}
+
+	return pb
+}
+
+func attributeValue(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	default:
+		return stringValue(v.Emit())
+	}
+}
+
+type grpcClient struct {
+	conn    *grpc.ClientConn
+	client  collogspb.LogsServiceClient
+	headers metadata.MD
+}
+
+func newGRPCClient(cfg config.OTLPConfig) (OTLPClient, error) {
+	creds := insecure.NewCredentials()
+	if !cfg.Insecure {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+
+	// The connection is established lazily, the logger works while the collector is down
+	conn, err := grpc.Dial(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcClient{
+		conn:    conn,
+		client:  collogspb.NewLogsServiceClient(conn),
+		headers: metadata.New(cfg.HeaderMap()),
+	}, nil
+}
+
+func (c *grpcClient) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	_, err := c.client.Export(metadata.NewOutgoingContext(ctx, c.headers), req)
+	return err
+}
+
+func (c *grpcClient) Close() error {
+	return c.conn.Close()
+}
+
+type httpClient struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTPClient(cfg config.OTLPConfig) OTLPClient {
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if !strings.Contains(endpoint, "://") {
+		scheme := "https://"
+		if cfg.Insecure {
+			scheme = "http://"
+		}
+		endpoint = scheme + endpoint
+	}
+
+	return &httpClient{
+		url:     endpoint + "/v1/logs",
+		headers: cfg.HeaderMap(),
+		client:  &http.Client{},
+	}
+}
+
+func (c *httpClient) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.headers {
+		r.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp logs export: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (c *httpClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,204 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	config "go-rengan/pkg/config"
+	logger "go-rengan/pkg/logger"
+	tracing "go-rengan/pkg/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var exportConfig = config.LogConfig{
+	Level:          "info",
+	Format:         "json",
+	Exporter:       "otlp",
+	BatchSize:      10,
+	QueueSize:      10,
+	ExportTimeout:  time.Second,
+	ExportInterval: time.Hour,
+}
+
+type fakeClient struct {
+	mu       sync.Mutex
+	requests []*collogspb.ExportLogsServiceRequest
+	err      error
+	block    chan struct{}
+}
+
+func (c *fakeClient) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	if c.block != nil {
+		<-c.block
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests = append(c.requests, req)
+	return c.err
+}
+
+func (c *fakeClient) Close() error {
+	return nil
+}
+
+func (c *fakeClient) records() []*logspb.LogRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records := []*logspb.LogRecord{}
+	for _, req := range c.requests {
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				records = append(records, sl.LogRecords...)
+			}
+		}
+	}
+
+	return records
+}
+
+// syncBuffer - fallback written by the export goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestOTLP(t *testing.T) {
+	res := tracing.Resource(appConfig)
+
+	t.Run("success export records with the resource of the traces", func(t *testing.T) {
+		client := &fakeClient{}
+		fallback := &syncBuffer{}
+		l := logger.NewOTLP(appConfig, exportConfig, client, res, fallback)
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID,
+			SpanID:  spanID,
+		}))
+
+		l.Warn(ctx, "slow query", "duration_ms", 1200, "collection", "todo")
+		assert.NoError(t, l.Shutdown(context.Background()))
+
+		records := client.records()
+		assert.Len(t, records, 1)
+		assert.Equal(t, "slow query", records[0].Body.GetStringValue())
+		assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_WARN, records[0].SeverityNumber)
+		assert.Equal(t, traceID[:], records[0].TraceId)
+		assert.Equal(t, spanID[:], records[0].SpanId)
+
+		attributes := map[string]interface{}{}
+		for _, kv := range records[0].Attributes {
+			attributes[kv.Key] = kv.Value.GetStringValue()
+			if kv.Key == "duration_ms" {
+				attributes[kv.Key] = kv.Value.GetIntValue()
+			}
+		}
+		assert.Equal(t, map[string]interface{}{"duration_ms": int64(1200), "collection": "todo"}, attributes)
+
+		resource := map[string]string{}
+		for _, kv := range client.requests[0].ResourceLogs[0].Resource.Attributes {
+			resource[kv.Key] = kv.Value.GetStringValue()
+		}
+		assert.Equal(t, "go-rengan", resource["service.name"])
+		assert.Equal(t, "production", resource["deployment.environment"])
+		assert.Equal(t, tracing.Version(), resource["service.version"])
+		assert.Empty(t, fallback.String())
+	})
+
+	t.Run("success write to fallback when export fails", func(t *testing.T) {
+		client := &fakeClient{err: errors.New("collector unavailable")}
+		fallback := &syncBuffer{}
+		l := logger.NewOTLP(appConfig, exportConfig, client, res, fallback)
+
+		l.Error(context.Background(), "todo create failed", "error", errors.New("boom"))
+		assert.NoError(t, l.Shutdown(context.Background()))
+
+		assert.Contains(t, fallback.String(), "collector unavailable")
+		assert.Contains(t, fallback.String(), `"message":"todo create failed"`)
+	})
+
+	t.Run("success write to fallback without blocking when queue is full", func(t *testing.T) {
+		client := &fakeClient{block: make(chan struct{})}
+		fallback := &syncBuffer{}
+		cfg := exportConfig
+		cfg.BatchSize = 1
+		cfg.QueueSize = 1
+		l := logger.NewOTLP(appConfig, cfg, client, res, fallback)
+
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 10; i++ {
+				l.Info(context.Background(), "todo created")
+			}
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("logging blocked on a slow collector")
+		}
+		assert.Contains(t, fallback.String(), "todo created")
+
+		close(client.block)
+		assert.NoError(t, l.Shutdown(context.Background()))
+	})
+
+	t.Run("success export over http/protobuf", func(t *testing.T) {
+		received := make(chan *collogspb.ExportLogsServiceRequest, 1)
+		headers := make(chan http.Header, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/logs", r.URL.Path)
+			body, _ := io.ReadAll(r.Body)
+			req := &collogspb.ExportLogsServiceRequest{}
+			assert.NoError(t, proto.Unmarshal(body, req))
+			headers <- r.Header
+			received <- req
+		}))
+		defer srv.Close()
+
+		client, err := logger.NewOTLPClient(config.OTLPConfig{
+			Endpoint: srv.URL,
+			Protocol: "http/protobuf",
+			Headers:  "uptrace-dsn=http://project1_secret_token@localhost:14317/1",
+		})
+		assert.NoError(t, err)
+		l := logger.NewOTLP(appConfig, exportConfig, client, res, &syncBuffer{})
+
+		l.Info(context.Background(), "todo created")
+		assert.NoError(t, l.Shutdown(context.Background()))
+
+		req := <-received
+		assert.Equal(t, "todo created", req.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body.GetStringValue())
+		assert.Equal(t, "http://project1_secret_token@localhost:14317/1", (<-headers).Get("uptrace-dsn"))
+	})
+}
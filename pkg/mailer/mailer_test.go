@@ -0,0 +1,186 @@
+package mailer_test
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-rengan/pkg/mailer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpStandIn - in-process smtp server which records the received envelope and data
+type smtpStandIn struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	from string
+	to   []string
+	auth string
+	data string
+	done chan struct{}
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &smtpStandIn{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+
+	return s
+}
+
+func (s *smtpStandIn) config() mailer.SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	return mailer.SMTPConfig{Host: host, Port: p, From: "go-rengan@example.com"}
+}
+
+func (s *smtpStandIn) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer close(s.done)
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP stand-in")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			s.auth = strings.TrimPrefix(line, "AUTH PLAIN ")
+			tp.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			s.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			tp.PrintfLine("250 2.1.0 Ok")
+		case "RCPT":
+			s.to = append(s.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			tp.PrintfLine("250 2.1.5 Ok")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, _ := tp.ReadDotBytes()
+			s.data = string(data)
+			tp.PrintfLine("250 2.0.0 Ok: queued")
+		case "QUIT":
+			tp.PrintfLine("221 2.0.0 Bye")
+			s.mu.Unlock()
+			return
+		default:
+			tp.PrintfLine("502 5.5.2 Command not recognized")
+		}
+		s.mu.Unlock()
+	}
+}
+
+// parts - decoded body of each part keyed by media type
+func parts(t *testing.T, data string) (*mail.Message, map[string]string) {
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	assert.NoError(t, err)
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+
+	result := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+
+		mediaType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		body, err := io.ReadAll(quotedprintable.NewReader(p))
+		assert.NoError(t, err)
+		result[mediaType] = string(body)
+	}
+
+	return msg, result
+}
+
+func TestSend(t *testing.T) {
+	t.Run("success when send", func(t *testing.T) {
+		s := newSMTPStandIn(t)
+
+		err := mailer.NewSMTP(s.config()).Send(context.Background(), &mailer.Message{
+			To:      []string{"user@example.com"},
+			Subject: "Todo créé",
+			Text:    "Buy milk",
+			HTML:    "<p>Buy milk</p>",
+		})
+		assert.NoError(t, err)
+		<-s.done
+
+		assert.Equal(t, "go-rengan@example.com", s.from)
+		assert.Equal(t, []string{"user@example.com"}, s.to)
+		assert.Empty(t, s.auth)
+
+		msg, body := parts(t, s.data)
+		subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		assert.NoError(t, err)
+		assert.Equal(t, "Todo créé", subject)
+		assert.Equal(t, "Buy milk", body["text/plain"])
+		assert.Equal(t, "<p>Buy milk</p>", body["text/html"])
+	})
+
+	t.Run("success when send with auth", func(t *testing.T) {
+		s := newSMTPStandIn(t)
+		config := s.config()
+		config.Username = "user"
+		config.Password = "secret"
+
+		err := mailer.NewSMTP(config).Send(context.Background(), &mailer.Message{
+			To:   []string{"user@example.com"},
+			Text: "Buy milk",
+		})
+		assert.NoError(t, err)
+		<-s.done
+
+		credentials, err := base64.StdEncoding.DecodeString(s.auth)
+		assert.NoError(t, err)
+		assert.Equal(t, "\x00user\x00secret", string(credentials))
+	})
+
+	t.Run("error when starttls is not supported", func(t *testing.T) {
+		s := newSMTPStandIn(t)
+		config := s.config()
+		config.StartTLS = true
+
+		err := mailer.NewSMTP(config).Send(context.Background(), &mailer.Message{
+			To:   []string{"user@example.com"},
+			Text: "Buy milk",
+		})
+		assert.ErrorIs(t, err, mailer.ErrStartTLSUnsupported)
+	})
+
+	t.Run("error when no recipient", func(t *testing.T) {
+		s := newSMTPStandIn(t)
+
+		err := mailer.NewSMTP(s.config()).Send(context.Background(), &mailer.Message{Text: "Buy milk"})
+		assert.ErrorIs(t, err, mailer.ErrNoRecipient)
+	})
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	config "go-rengan/pkg/config"
+	tracing "go-rengan/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	metric_sdk "go.opentelemetry.io/otel/sdk/metric"
+)
+
+const (
+	ExporterOTLP       = "otlp"
+	ExporterPrometheus = "prometheus"
+	ExporterNone       = "none"
+)
+
+// Outcome of the operation of a measurement
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+type Metrics interface {
+	Meter(name string) metric.Meter
+	// Handler serves the metrics to the Prometheus scraper, nil unless the exporter is prometheus
+	Handler() http.Handler
+	// ShutDown flushes the metrics which are not exported yet until ctx is done
+	ShutDown(ctx context.Context) error
+}
+
+type MetricsImpl struct {
+	mp      *metric_sdk.MeterProvider
+	handler http.Handler
+}
+
+// New - make metrics with the exporter of the config, registered as the global meter provider
+func New(app config.AppConfig, cfg config.MetricsConfig, otlp config.OTLPConfig) (Metrics, error) {
+	opts := []metric_sdk.Option{
+		metric_sdk.WithResource(tracing.Resource(app)),
+	}
+
+	var handler http.Handler
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		exporter, err := otlpExporter(otlp)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, metric_sdk.WithReader(metric_sdk.NewPeriodicReader(exporter, metric_sdk.WithInterval(cfg.ExportInterval))))
+	case ExporterPrometheus:
+		registry := prometheus.NewRegistry()
+		exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, metric_sdk.WithReader(exporter))
+		handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	case ExporterNone:
+	default:
+		return nil, fmt.Errorf("unknown metrics exporter %q", cfg.Exporter)
+	}
+
+	mp := metric_sdk.NewMeterProvider(opts...)
+	global.SetMeterProvider(mp)
+
+	return &MetricsImpl{
+		mp:      mp,
+		handler: handler,
+	}, nil
+}
+
+// NewNoop - make metrics which are never exported, for tests
+func NewNoop() Metrics {
+	return &MetricsImpl{
+		mp: metric_sdk.NewMeterProvider(),
+	}
+}
+
+// NewWithReader - make metrics collected by reader, for tests
+func NewWithReader(reader metric_sdk.Reader) Metrics {
+	return &MetricsImpl{
+		mp: metric_sdk.NewMeterProvider(metric_sdk.WithReader(reader)),
+	}
+}
+
+// otlpExporter - grpc or http/protobuf exporter, the connection is established lazily
+func otlpExporter(cfg config.OTLPConfig) (metric_sdk.Exporter, error) {
+	endpoint, insecure := cfg.Endpoint, cfg.Insecure
+	if u, err := url.Parse(cfg.Endpoint); err == nil && u.Host != "" {
+		endpoint, insecure = u.Host, u.Scheme == "http"
+	}
+
+	switch cfg.Protocol {
+	case "http/protobuf":
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(endpoint),
+			otlpmetrichttp.WithHeaders(cfg.HeaderMap()),
+		}
+		if insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(context.Background(), opts...)
+	case "grpc", "":
+		opts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithEndpoint(endpoint),
+			otlpmetricgrpc.WithHeaders(cfg.HeaderMap()),
+		}
+		if insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		return otlpmetricgrpc.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unknown otlp protocol %q", cfg.Protocol)
+	}
+}
+
+func (m *MetricsImpl) Meter(name string) metric.Meter {
+	return m.mp.Meter(name)
+}
+
+func (m *MetricsImpl) Handler() http.Handler {
+	return m.handler
+}
+
+func (m *MetricsImpl) ShutDown(ctx context.Context) error {
+	return m.mp.Shutdown(ctx)
+}
+
+// Counter - counter of the meter, a counter which records nothing when it cannot be made
+func Counter(meter metric.Meter, name string, description string) syncint64.Counter {
+	counter, err := meter.SyncInt64().Counter(name, instrument.WithDescription(description))
+	if err != nil {
+		otel.Handle(err)
+		counter, _ = metric.NewNoopMeter().SyncInt64().Counter(name)
+	}
+
+	return counter
+}
+
+// Histogram - histogram of durations in milliseconds, a histogram which records nothing when it cannot be made
+func Histogram(meter metric.Meter, name string, description string) syncfloat64.Histogram {
+	histogram, err := meter.SyncFloat64().Histogram(name, instrument.WithDescription(description), instrument.WithUnit(unit.Milliseconds))
+	if err != nil {
+		otel.Handle(err)
+		histogram, _ = metric.NewNoopMeter().SyncFloat64().Histogram(name)
+	}
+
+	return histogram
+}
+
+// Outcome - outcome attribute of the error of the operation
+func Outcome(err error) attribute.KeyValue {
+	if err != nil {
+		return attribute.String("outcome", OutcomeError)
+	}
+
+	return attribute.String("outcome", OutcomeSuccess)
+}
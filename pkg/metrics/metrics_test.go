@@ -0,0 +1,60 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	config "go-rengan/pkg/config"
+	metrics "go-rengan/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+	metric_sdk "go.opentelemetry.io/otel/sdk/metric"
+)
+
+var appConfig = config.AppConfig{Env: "test", Name: "go-rengan"}
+
+func TestMetrics(t *testing.T) {
+	t.Run("success serve metrics to prometheus", func(t *testing.T) {
+		m, err := metrics.New(appConfig, config.MetricsConfig{Exporter: "prometheus"}, config.OTLPConfig{})
+		assert.NoError(t, err)
+		defer m.ShutDown(context.Background())
+
+		counter := metrics.Counter(m.Meter("test"), "todo.created", "Todos created")
+		counter.Add(context.Background(), 2)
+
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		body, _ := io.ReadAll(rec.Body)
+		assert.Contains(t, string(body), "todo_created_total")
+		assert.Contains(t, string(body), `service_name="go-rengan"`)
+	})
+
+	t.Run("success without handler when metrics are pushed", func(t *testing.T) {
+		m, err := metrics.New(appConfig, config.MetricsConfig{Exporter: "none"}, config.OTLPConfig{})
+		assert.NoError(t, err)
+
+		assert.Nil(t, m.Handler())
+	})
+
+	t.Run("error when reader fails to shut down", func(t *testing.T) {
+		m := metrics.NewWithReader(metric_sdk.NewManualReader())
+		assert.NoError(t, m.ShutDown(context.Background()))
+
+		assert.ErrorIs(t, m.ShutDown(context.Background()), metric_sdk.ErrReaderShutdown)
+	})
+
+	t.Run("failed unknown exporter", func(t *testing.T) {
+		_, err := metrics.New(appConfig, config.MetricsConfig{Exporter: "statsd"}, config.OTLPConfig{})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestOutcome(t *testing.T) {
+	assert.Equal(t, metrics.OutcomeSuccess, metrics.Outcome(nil).Value.AsString())
+	assert.Equal(t, metrics.OutcomeError, metrics.Outcome(errors.New("boom")).Value.AsString())
+}
@@ -0,0 +1,146 @@
+package mongodb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	config "go-rengan/pkg/config"
+	tenant "go-rengan/pkg/tenant"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Isolation strategies of the tenants
+const (
+	// IsolationCollection - the tenants share the collections, the documents have a tenantId
+	IsolationCollection = "collection"
+	// IsolationDatabase - every tenant has its own database
+	IsolationDatabase = "database"
+)
+
+// Isolation - collections of the tenant of the context
+type Isolation interface {
+	// Collection returns the collection of the tenant of ctx, tenant.ErrMissing when tenancy is enabled and ctx has none
+	Collection(ctx context.Context, name string) (*Collection, error)
+	// EnsureIndexes creates the indexes of the collection, in the database of every tenant on its first use
+	EnsureIndexes(ctx context.Context, name string, indexes []mongo.IndexModel) error
+}
+
+// Collection - collection of a tenant, Filter and TenantID keep the documents of the tenant apart in a shared collection
+type Collection struct {
+	*mongo.Collection
+	// TenantID - tenant to store in the tenantId of the documents, empty when they need none
+	TenantID string
+}
+
+// Filter - filter restricted to the documents of the tenant
+func (c *Collection) Filter(filter bson.M) bson.M {
+	if c.TenantID != "" {
+		filter["tenantId"] = c.TenantID
+	}
+	return filter
+}
+
+// Document - document stamped with the tenant, to insert
+func (c *Collection) Document(doc bson.M) bson.M {
+	if c.TenantID != "" {
+		doc["tenantId"] = c.TenantID
+	}
+	return doc
+}
+
+type IsolationImpl struct {
+	cfg     config.TenantConfig
+	mongoDB MongoDB
+
+	mu      sync.Mutex
+	indexes map[string][]mongo.IndexModel
+	// ensured - collections of the tenant databases whose indexes are created, by database and collection
+	ensured map[string]bool
+}
+
+// NewIsolation - make isolation of the tenants of the config, without tenancy every collection is in the application database
+func NewIsolation(cfg config.TenantConfig, mongoDB MongoDB) Isolation {
+	return &IsolationImpl{
+		cfg:     cfg,
+		mongoDB: mongoDB,
+		indexes: map[string][]mongo.IndexModel{},
+		ensured: map[string]bool{},
+	}
+}
+
+func (i *IsolationImpl) Collection(ctx context.Context, name string) (*Collection, error) {
+	if !i.cfg.Enabled {
+		return &Collection{Collection: i.mongoDB.Database().Collection(name)}, nil
+	}
+
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+
+	if i.cfg.Isolation != IsolationDatabase {
+		return &Collection{Collection: i.mongoDB.Database().Collection(name), TenantID: id}, nil
+	}
+
+	collection := i.mongoDB.Get().Database(i.mongoDB.Database().Name() + "_" + id).Collection(name)
+	if err := i.ensure(collection); err != nil {
+		return nil, err
+	}
+
+	return &Collection{Collection: collection}, nil
+}
+
+func (i *IsolationImpl) EnsureIndexes(ctx context.Context, name string, indexes []mongo.IndexModel) error {
+	if !i.cfg.Enabled {
+		_, err := i.mongoDB.Database().Collection(name).Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+
+	if i.cfg.Isolation == IsolationDatabase {
+		i.mu.Lock()
+		i.indexes[name] = indexes
+		i.mu.Unlock()
+		return nil
+	}
+
+	// The queries of a shared collection always filter by tenant first
+	shared := make([]mongo.IndexModel, 0, len(indexes)+1)
+	shared = append(shared, mongo.IndexModel{Keys: bson.D{{Key: "tenantId", Value: 1}}})
+	for _, index := range indexes {
+		keys, ok := index.Keys.(bson.D)
+		if !ok {
+			shared = append(shared, index)
+			continue
+		}
+		shared = append(shared, mongo.IndexModel{Keys: append(bson.D{{Key: "tenantId", Value: 1}}, keys...), Options: index.Options})
+	}
+
+	_, err := i.mongoDB.Database().Collection(name).Indexes().CreateMany(ctx, shared)
+	return err
+}
+
+// ensure - create the indexes of the collection once per tenant database
+func (i *IsolationImpl) ensure(collection *mongo.Collection) error {
+	key := collection.Database().Name() + "." + collection.Name()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	indexes := i.indexes[collection.Name()]
+	if i.ensured[key] || len(indexes) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+	i.ensured[key] = true
+
+	return nil
+}
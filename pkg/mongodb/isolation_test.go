@@ -0,0 +1,75 @@
+package mongodb_test
+
+import (
+	"context"
+	"testing"
+
+	config "go-rengan/pkg/config"
+	mongodb "go-rengan/pkg/mongodb"
+	tenant "go-rengan/pkg/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeMongoDB - client which is never connected, the collections are only named
+type fakeMongoDB struct {
+	client *mongo.Client
+}
+
+func newFakeMongoDB(t *testing.T) mongodb.MongoDB {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	assert.NoError(t, err)
+
+	return &fakeMongoDB{client: client}
+}
+
+func (f *fakeMongoDB) Get() *mongo.Client                   { return f.client }
+func (f *fakeMongoDB) Database() *mongo.Database            { return f.client.Database("app") }
+func (f *fakeMongoDB) Ping(ctx context.Context) error       { return nil }
+func (f *fakeMongoDB) Disconnect(ctx context.Context) error { return nil }
+
+func TestIsolation(t *testing.T) {
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	t.Run("success in application database without tenancy", func(t *testing.T) {
+		isolation := mongodb.NewIsolation(config.TenantConfig{}, newFakeMongoDB(t))
+
+		collection, err := isolation.Collection(context.Background(), "todo")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "app", collection.Database().Name())
+		assert.Equal(t, bson.M{"ownerId": "user-1"}, collection.Filter(bson.M{"ownerId": "user-1"}))
+	})
+
+	t.Run("success filtered by tenant in shared collection", func(t *testing.T) {
+		isolation := mongodb.NewIsolation(config.TenantConfig{Enabled: true, Isolation: mongodb.IsolationCollection}, newFakeMongoDB(t))
+
+		collection, err := isolation.Collection(ctx, "todo")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "app", collection.Database().Name())
+		assert.Equal(t, bson.M{"ownerId": "user-1", "tenantId": "acme"}, collection.Filter(bson.M{"ownerId": "user-1"}))
+		assert.Equal(t, bson.M{"title": "Buy milk", "tenantId": "acme"}, collection.Document(bson.M{"title": "Buy milk"}))
+	})
+
+	t.Run("success in database of tenant", func(t *testing.T) {
+		isolation := mongodb.NewIsolation(config.TenantConfig{Enabled: true, Isolation: mongodb.IsolationDatabase}, newFakeMongoDB(t))
+
+		collection, err := isolation.Collection(ctx, "todo")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "app_acme", collection.Database().Name())
+		assert.Equal(t, bson.M{"ownerId": "user-1"}, collection.Filter(bson.M{"ownerId": "user-1"}))
+	})
+
+	t.Run("error when context has no tenant", func(t *testing.T) {
+		isolation := mongodb.NewIsolation(config.TenantConfig{Enabled: true, Isolation: mongodb.IsolationCollection}, newFakeMongoDB(t))
+
+		_, err := isolation.Collection(context.Background(), "todo")
+
+		assert.ErrorIs(t, err, tenant.ErrMissing)
+	})
+}
@@ -2,35 +2,40 @@ package mongodb
 
 import (
 	"context"
-	"os"
 	"time"
 
+	config "go-rengan/pkg/config"
 	logger "go-rengan/pkg/logger"
+	metrics "go-rengan/pkg/metrics"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type MongoDB interface {
 	Get() *mongo.Client
-	Disconnect() error
+	Database() *mongo.Database
+	// Ping checks the primary answers
+	Ping(ctx context.Context) error
+	// Disconnect closes the connections, waiting for the operations in progress until ctx is done
+	Disconnect(ctx context.Context) error
 }
 
 type MongoDBImpl struct {
-	ctx    context.Context
-	client *mongo.Client
-	logger logger.Logger
+	client   *mongo.Client
+	database string
+	logger   logger.Logger
 }
 
-func New(logger logger.Logger) (MongoDB, error) {
+func New(cfg config.DatabaseConfig, logger logger.Logger, metrics metrics.Metrics) (MongoDB, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	uri := os.Getenv("DB_URL")
 	opts := options.Client()
-	opts.Monitor = otelmongo.NewMonitor() // add mongo opentelemetry tracing
-	opts.ApplyURI(uri)
+	opts.Monitor = newMonitor(metrics) // add mongo opentelemetry tracing and latency metrics
+	opts.ApplyURI(cfg.URL.Value())
+	opts.SetMaxPoolSize(cfg.ConnectionPool)
 	client, err := mongo.NewClient(opts)
 	if err != nil {
 		return nil, err
@@ -38,7 +43,7 @@ func New(logger logger.Logger) (MongoDB, error) {
 
 	err = client.Connect(ctx)
 	if err != nil {
-		logger.Error("cannot connect")
+		logger.Error(ctx, "mongo connect failed", "error", err)
 		return nil, err
 	}
 
@@ -48,21 +53,39 @@ func New(logger logger.Logger) (MongoDB, error) {
 		return nil, err
 	}
 
-	logger.Println("Mongo Database connected")
+	logger.Info(ctx, "mongo connected", "database", cfg.Name)
 
 	return &MongoDBImpl{
-		ctx:    ctx,
-		client: client,
-		logger: logger,
+		client:   client,
+		database: cfg.Name,
+		logger:   logger,
 	}, err
 }
 
+// NewWithClient - make mongo with a connected client, for tests against a mock deployment
+func NewWithClient(client *mongo.Client, database string, logger logger.Logger) MongoDB {
+	return &MongoDBImpl{
+		client:   client,
+		database: database,
+		logger:   logger,
+	}
+}
+
 func (m *MongoDBImpl) Get() *mongo.Client {
 	return m.client
 }
 
-func (m *MongoDBImpl) Disconnect() error {
-	if err := m.client.Disconnect(m.ctx); err != nil {
+// Database - the application database
+func (m *MongoDBImpl) Database() *mongo.Database {
+	return m.client.Database(m.database)
+}
+
+func (m *MongoDBImpl) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, readpref.Primary())
+}
+
+func (m *MongoDBImpl) Disconnect(ctx context.Context) error {
+	if err := m.client.Disconnect(ctx); err != nil {
 		return err
 	}
 
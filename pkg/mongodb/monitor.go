@@ -0,0 +1,74 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	metrics "go-rengan/pkg/metrics"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// monitor - trace the commands with otelmongo and record their latency by operation and collection
+type monitor struct {
+	tracing  *event.CommandMonitor
+	duration syncfloat64.Histogram
+	// commands - collection of the started commands by connection and request id
+	commands sync.Map
+}
+
+// newMonitor - make command monitor which traces the commands and records their latency
+func newMonitor(m metrics.Metrics) *event.CommandMonitor {
+	meter := m.Meter("go-rengan/pkg/mongodb")
+	mon := &monitor{
+		tracing:  otelmongo.NewMonitor(),
+		duration: metrics.Histogram(meter, "db.client.duration", "Duration of the mongo commands"),
+	}
+
+	return &event.CommandMonitor{
+		Started:   mon.started,
+		Succeeded: mon.succeeded,
+		Failed:    mon.failed,
+	}
+}
+
+func (m *monitor) started(ctx context.Context, evt *event.CommandStartedEvent) {
+	m.tracing.Started(ctx, evt)
+
+	collection := ""
+	if elem, err := evt.Command.IndexErr(0); err == nil {
+		collection, _ = elem.Value().StringValueOK()
+	}
+	m.commands.Store(commandKey(evt.ConnectionID, evt.RequestID), collection)
+}
+
+func (m *monitor) succeeded(ctx context.Context, evt *event.CommandSucceededEvent) {
+	m.tracing.Succeeded(ctx, evt)
+	m.record(ctx, evt.CommandFinishedEvent, nil)
+}
+
+func (m *monitor) failed(ctx context.Context, evt *event.CommandFailedEvent) {
+	m.tracing.Failed(ctx, evt)
+	m.record(ctx, evt.CommandFinishedEvent, errors.New(evt.Failure))
+}
+
+func (m *monitor) record(ctx context.Context, evt event.CommandFinishedEvent, err error) {
+	collection, _ := m.commands.LoadAndDelete(commandKey(evt.ConnectionID, evt.RequestID))
+	name, _ := collection.(string)
+
+	m.duration.Record(ctx, float64(time.Duration(evt.DurationNanos).Microseconds())/1000,
+		semconv.DBOperationKey.String(evt.CommandName),
+		semconv.DBMongoDBCollectionKey.String(name),
+		metrics.Outcome(err),
+	)
+}
+
+func commandKey(connectionID string, requestID int64) string {
+	return fmt.Sprintf("%s/%d", connectionID, requestID)
+}
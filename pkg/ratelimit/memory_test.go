@@ -0,0 +1,67 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "go-rengan/pkg/config"
+	"go-rengan/pkg/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	rate := config.Rate{Requests: 3, Period: 3 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("success until bucket is empty", func(t *testing.T) {
+		store := ratelimit.NewMemoryStore()
+
+		for remaining := 2; remaining >= 0; remaining-- {
+			res, err := store.Take(context.Background(), "user:1", rate, start)
+
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 3, res.Limit)
+			assert.Equal(t, remaining, res.Remaining)
+		}
+
+		res, err := store.Take(context.Background(), "user:1", rate, start)
+
+		assert.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, 0, res.Remaining)
+		assert.Equal(t, time.Second, res.RetryAfter)
+		assert.Equal(t, 3*time.Second, res.Reset)
+	})
+
+	t.Run("success when bucket is refilled", func(t *testing.T) {
+		store := ratelimit.NewMemoryStore()
+		for i := 0; i < 3; i++ {
+			_, _ = store.Take(context.Background(), "user:1", rate, start)
+		}
+
+		res, _ := store.Take(context.Background(), "user:1", rate, start.Add(500*time.Millisecond))
+		assert.False(t, res.Allowed)
+		assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+		res, _ = store.Take(context.Background(), "user:1", rate, start.Add(time.Second))
+		assert.True(t, res.Allowed)
+
+		res, _ = store.Take(context.Background(), "user:1", rate, start.Add(time.Hour))
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2, res.Remaining)
+	})
+
+	t.Run("success when keys have their own buckets", func(t *testing.T) {
+		store := ratelimit.NewMemoryStore()
+		for i := 0; i < 3; i++ {
+			_, _ = store.Take(context.Background(), "user:1", rate, start)
+		}
+
+		res, _ := store.Take(context.Background(), "user:2", rate, start)
+
+		assert.True(t, res.Allowed)
+	})
+}
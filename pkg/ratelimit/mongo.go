@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	config "go-rengan/pkg/config"
+	mongodb "go-rengan/pkg/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionName = "rate_limits"
+
+type MongoStore struct {
+	mongoDB mongodb.MongoDB
+}
+
+// NewMongoStore - make mongo store, shared by the instances, and ensure the expiry index of the buckets
+func NewMongoStore(mongoDB mongodb.MongoDB) (Store, error) {
+	s := &MongoStore{
+		mongoDB: mongoDB,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A bucket expires once it is full again, the next request makes a new full one
+	_, err := s.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Take - refill and take in one update, so the instances taking from the same bucket do not race
+func (s *MongoStore) Take(ctx context.Context, key string, rate config.Rate, now time.Time) (Result, error) {
+	requests := float64(rate.Requests)
+	tokens := bson.M{"$ifNull": bson.A{"$tokens", requests}}
+	elapsed := bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updatedAt", now}}}}
+	refilled := bson.M{"$add": bson.A{tokens, bson.M{"$multiply": bson.A{
+		bson.M{"$divide": bson.A{elapsed, float64(rate.Period.Milliseconds())}},
+		requests,
+	}}}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens":    bson.M{"$min": bson.A{requests, refilled}},
+			"updatedAt": now,
+			"expiresAt": now.Add(rate.Period),
+		}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{"tokens": bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}}}}},
+	}
+
+	var doc struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.collection().FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&doc)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another instance inserted the new bucket at the same time, the update finds it now
+		err = s.collection().FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&doc)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result(rate, doc.Tokens, doc.Allowed), nil
+}
+
+func (s *MongoStore) collection() *mongo.Collection {
+	return s.mongoDB.Database().Collection(collectionName)
+}
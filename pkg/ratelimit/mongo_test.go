@@ -0,0 +1,169 @@
+package ratelimit_test
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	config "go-rengan/pkg/config"
+	logger "go-rengan/pkg/logger"
+	mongodb "go-rengan/pkg/mongodb"
+	"go-rengan/pkg/ratelimit"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMongoStoreTake(t *testing.T) {
+	rate := config.Rate{Requests: 3, Period: 3 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	newStore := func(mt *mtest.T) ratelimit.Store {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		store, err := ratelimit.NewMongoStore(mongodb.NewWithClient(mt.Client, mt.DB.Name(), logger.NewNop()))
+		assert.NoError(mt, err)
+		mt.ClearEvents()
+
+		return store
+	}
+
+	mt.Run("success take from bucket in one upsert", func(mt *mtest.T) {
+		store := newStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+			{Key: "_id", Value: "user:1"}, {Key: "tokens", Value: 2.0}, {Key: "allowed", Value: true},
+		}}))
+
+		res, err := store.Take(context.Background(), "user:1", rate, start)
+
+		assert.NoError(mt, err)
+		assert.Equal(mt, ratelimit.Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}, res)
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(mt, "user:1", cmd.Lookup("query", "_id").StringValue())
+		assert.True(mt, cmd.Lookup("upsert").Boolean())
+		assert.True(mt, cmd.Lookup("new").Boolean())
+		// The refill and the take are stages of one update pipeline
+		stages, err := cmd.Lookup("update").Array().Values()
+		assert.NoError(mt, err)
+		assert.Len(mt, stages, 3)
+		_, err = stages[2].Document().LookupErr("$set", "tokens", "$cond")
+		assert.NoError(mt, err)
+	})
+
+	mt.Run("success denied when bucket is empty", func(mt *mtest.T) {
+		store := newStore(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+			{Key: "_id", Value: "user:1"}, {Key: "tokens", Value: 0.5}, {Key: "allowed", Value: false},
+		}}))
+
+		res, err := store.Take(context.Background(), "user:1", rate, start)
+
+		assert.NoError(mt, err)
+		assert.False(mt, res.Allowed)
+		assert.Equal(mt, 0, res.Remaining)
+		assert.Equal(mt, 500*time.Millisecond, res.RetryAfter)
+	})
+
+	mt.Run("success retry once when another instance inserted the new bucket", func(mt *mtest.T) {
+		store := newStore(mt)
+		mt.AddMockResponses(
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 11000, Name: "DuplicateKey", Message: "E11000 duplicate key error"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+				{Key: "_id", Value: "user:1"}, {Key: "tokens", Value: 1.0}, {Key: "allowed", Value: true},
+			}}),
+		)
+
+		res, err := store.Take(context.Background(), "user:1", rate, start)
+
+		assert.NoError(mt, err)
+		assert.True(mt, res.Allowed)
+		assert.Equal(mt, 1, res.Remaining)
+		assert.Equal(mt, "findAndModify", mt.GetStartedEvent().CommandName)
+		assert.Equal(mt, "findAndModify", mt.GetStartedEvent().CommandName)
+		assert.Nil(mt, mt.GetStartedEvent())
+	})
+
+	mt.Run("error when update fails", func(mt *mtest.T) {
+		store := newStore(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Name: "BadValue", Message: "bad value"}))
+
+		_, err := store.Take(context.Background(), "user:1", rate, start)
+
+		assert.Error(mt, err)
+	})
+}
+
+// TestMongoStoreBucket - the refill and the take of the pipeline, run by a mongo at MONGODB_TEST_URL
+func TestMongoStoreBucket(t *testing.T) {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(url))
+	assert.NoError(t, err)
+	database := "ratelimit_test_" + uuid.NewString()[:8]
+	t.Cleanup(func() {
+		_ = client.Database(database).Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	store, err := ratelimit.NewMongoStore(mongodb.NewWithClient(client, database, logger.NewNop()))
+	assert.NoError(t, err)
+
+	rate := config.Rate{Requests: 3, Period: 3 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("success until bucket is empty then refilled", func(t *testing.T) {
+		for remaining := 2; remaining >= 0; remaining-- {
+			res, err := store.Take(ctx, "user:1", rate, start)
+
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, remaining, res.Remaining)
+		}
+
+		res, err := store.Take(ctx, "user:1", rate, start)
+		assert.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, time.Second, res.RetryAfter)
+
+		res, err = store.Take(ctx, "user:1", rate, start.Add(time.Second))
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 0, res.Remaining)
+	})
+
+	t.Run("success when concurrent takes never exceed the bucket", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		allowed := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := store.Take(ctx, "user:2", rate, start)
+				assert.NoError(t, err)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if res.Allowed {
+					allowed++
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 3, allowed)
+	})
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	config "go-rengan/pkg/config"
+	mongodb "go-rengan/pkg/mongodb"
+)
+
+// Result - outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	// Limit - tokens of the full bucket
+	Limit     int
+	Remaining int
+	// RetryAfter - wait until the next token, zero when allowed
+	RetryAfter time.Duration
+	// Reset - wait until the bucket is full again
+	Reset time.Duration
+}
+
+// Store - token buckets by key. A bucket holds rate.Requests tokens, refilled over rate.Period
+type Store interface {
+	// Take takes a token from the bucket of key, a new bucket is full
+	Take(ctx context.Context, key string, rate config.Rate, now time.Time) (Result, error)
+}
+
+// NewStore - store of the config, the memory store keeps the buckets of this instance only
+func NewStore(cfg config.RateLimitConfig, mongoDB mongodb.MongoDB) (Store, error) {
+	switch cfg.Store {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "mongo":
+		return NewMongoStore(mongoDB)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+}
+
+// refill - tokens of the bucket after elapsed, at most a full bucket
+func refill(tokens float64, elapsed time.Duration, rate config.Rate) float64 {
+	if elapsed > 0 {
+		tokens += float64(elapsed) / float64(rate.Period) * float64(rate.Requests)
+	}
+	return math.Min(tokens, float64(rate.Requests))
+}
+
+// result - result of a bucket left with tokens
+func result(rate config.Rate, tokens float64, allowed bool) Result {
+	perToken := float64(rate.Period) / float64(rate.Requests)
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rate.Requests,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     time.Duration((float64(rate.Requests) - tokens) * perToken),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+
+	return res
+}
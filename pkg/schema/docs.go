@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Markdown - document the fields of every schema, nested objects are flattened to dotted field names
+func Markdown(schemas []*Schema) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("# Message schemas\n")
+	b.WriteString("Generated from the JSON schemas, do not edit\n")
+
+	for _, s := range schemas {
+		root := deref(s.Compiled)
+
+		fmt.Fprintf(b, "\n## %s v%d\n", s.Type, s.Version)
+		if root.Description != "" {
+			fmt.Fprintf(b, "%s\n\n", root.Description)
+		}
+		fmt.Fprintf(b, "Dataschema `%s`\n\n", URI(s.Type, s.Version))
+		b.WriteString("| Field | Type | Required | Description |\n")
+		b.WriteString("| --- | --- | --- | --- |\n")
+		writeFields(b, "", root)
+	}
+
+	return b.Bytes()
+}
+
+func writeFields(b *bytes.Buffer, prefix string, s *jsonschema.Schema) {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	for _, name := range names {
+		property := deref(s.Properties[name])
+		field := prefix + name
+
+		requiredText := "no"
+		if required[name] {
+			requiredText = "yes"
+		}
+		fmt.Fprintf(b, "| `%s` | %s | %s | %s |\n", field, typeOf(property), requiredText, property.Description)
+
+		if len(property.Properties) > 0 {
+			writeFields(b, field+".", property)
+		}
+	}
+}
+
+// typeOf - human readable type of the schema, e.g. "string (date-time)" or "`\"todo.created\"`"
+func typeOf(s *jsonschema.Schema) string {
+	if len(s.Constant) > 0 {
+		return fmt.Sprintf("`%v`", quote(s.Constant[0]))
+	}
+
+	if len(s.Enum) > 0 {
+		values := []string{}
+		for _, v := range s.Enum {
+			values = append(values, fmt.Sprintf("`%v`", quote(v)))
+		}
+		return strings.Join(values, ", ")
+	}
+
+	t := strings.Join(s.Types, ", ")
+	if s.Format != "" {
+		t = fmt.Sprintf("%s (%s)", t, s.Format)
+	}
+
+	return t
+}
+
+func quote(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return v
+}
+
+// deref - follow the $ref of the schema
+func deref(s *jsonschema.Schema) *jsonschema.Schema {
+	for s.Ref != nil && len(s.Properties) == 0 && len(s.Types) == 0 {
+		s = s.Ref
+	}
+	return s
+}
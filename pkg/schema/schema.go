@@ -0,0 +1,177 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// ErrUnknownSchema - no schema is registered for the message type and version
+var ErrUnknownSchema = errors.New("schema: unknown schema")
+
+// fileName - schema files are named <type>/v<version>.json
+var fileName = regexp.MustCompile(`^v([0-9]+)\.json$`)
+
+// ValidationError - data does not match the schema, one entry per failing location
+type ValidationError struct {
+	Type    string
+	Version int
+	Errors  []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("schema: %s v%d: %s", e.Type, e.Version, strings.Join(e.Errors, "; "))
+}
+
+// Schema - json schema of one message type and version
+type Schema struct {
+	Type     string
+	Version  int
+	Compiled *jsonschema.Schema
+}
+
+type Registry interface {
+	// Validate validates the data of the message type against the schema the dataschema uri points to
+	Validate(messageType string, dataSchema string, data []byte) error
+	Schemas() []*Schema
+}
+
+type RegistryImpl struct {
+	schemas map[string]*Schema
+}
+
+// URI - dataschema uri of the message type and version
+func URI(messageType string, version int) string {
+	return fmt.Sprintf("/schemas/%s/v%d.json", messageType, version)
+}
+
+// New - make registry from every <type>/v<version>.json file in fsys
+func New(fsys fs.FS) (Registry, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	compiler.ExtractAnnotations = true
+
+	found := []*Schema{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		match := fileName.FindStringSubmatch(path.Base(name))
+		if match == nil || path.Dir(name) == "." {
+			return nil
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return err
+		}
+
+		source, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		s := &Schema{Type: path.Base(path.Dir(name)), Version: version}
+		err = compiler.AddResource(resourceURL(s.Type, s.Version), bytes.NewReader(source))
+		if err != nil {
+			return fmt.Errorf("schema: %s: %w", name, err)
+		}
+		found = append(found, s)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &RegistryImpl{
+		schemas: map[string]*Schema{},
+	}
+	for _, s := range found {
+		s.Compiled, err = compiler.Compile(resourceURL(s.Type, s.Version))
+		if err != nil {
+			return nil, err
+		}
+		r.schemas[URI(s.Type, s.Version)] = s
+	}
+
+	return r, nil
+}
+
+// Validate - validate the data, the error is a *ValidationError when the data does not match
+func (r *RegistryImpl) Validate(messageType string, dataSchema string, data []byte) error {
+	s, ok := r.schemas[dataSchema]
+	if !ok || s.Type != messageType {
+		return fmt.Errorf("%w: type %s dataschema %q", ErrUnknownSchema, messageType, dataSchema)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return &ValidationError{Type: s.Type, Version: s.Version, Errors: []string{err.Error()}}
+	}
+
+	err = s.Compiled.Validate(value)
+	if err != nil {
+		var validationErr *jsonschema.ValidationError
+		if !errors.As(err, &validationErr) {
+			return err
+		}
+
+		return &ValidationError{Type: s.Type, Version: s.Version, Errors: leaves(validationErr)}
+	}
+
+	return nil
+}
+
+// Schemas - every schema, sorted by type and version
+func (r *RegistryImpl) Schemas() []*Schema {
+	schemas := make([]*Schema, 0, len(r.schemas))
+	for _, s := range r.schemas {
+		schemas = append(schemas, s)
+	}
+
+	sort.Slice(schemas, func(i, j int) bool {
+		if schemas[i].Type != schemas[j].Type {
+			return schemas[i].Type < schemas[j].Type
+		}
+		return schemas[i].Version < schemas[j].Version
+	})
+
+	return schemas
+}
+
+func resourceURL(messageType string, version int) string {
+	return "mem://" + URI(messageType, version)
+}
+
+// leaves - the errors which caused the validation to fail, as "<instance location>: <message>"
+func leaves(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		location := err.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return []string{fmt.Sprintf("%s: %s", location, err.Message)}
+	}
+
+	errs := []string{}
+	for _, cause := range err.Causes {
+		errs = append(errs, leaves(cause)...)
+	}
+
+	return errs
+}
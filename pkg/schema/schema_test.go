@@ -0,0 +1,111 @@
+package schema_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	schema "go-rengan/pkg/schema"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var schemas = fstest.MapFS{
+	"schemas/todo.created/v1.json": {Data: []byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"description": "A todo is created",
+		"type": "object",
+		"required": ["title"],
+		"properties": {
+			"title": {"type": "string", "description": "Title"},
+			"created_at": {"type": "string", "format": "date-time"}
+		}
+	}`)},
+	"schemas/todo.created/v2.json": {Data: []byte(`{
+		"type": "object",
+		"required": ["title", "owner"],
+		"properties": {
+			"title": {"type": "string"},
+			"owner": {"$ref": "#/$defs/owner"}
+		},
+		"$defs": {"owner": {"type": "object", "properties": {"id": {"type": "string", "description": "Owner id"}}}}
+	}`)},
+	"schemas/README.md": {Data: []byte("not a schema")},
+}
+
+func TestValidate(t *testing.T) {
+	registry, err := schema.New(schemas)
+	assert.NoError(t, err)
+
+	t.Run("success when data matches", func(t *testing.T) {
+		err := registry.Validate("todo.created", schema.URI("todo.created", 1), []byte(`{"title": "Buy milk", "created_at": "2022-10-01T00:00:00Z"}`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("error when data does not match", func(t *testing.T) {
+		err := registry.Validate("todo.created", schema.URI("todo.created", 1), []byte(`{"created_at": "yesterday"}`))
+
+		var validationErr *schema.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "todo.created", validationErr.Type)
+		assert.Equal(t, 1, validationErr.Version)
+		assert.ElementsMatch(t, []string{
+			"/: missing properties: 'title'",
+			"/created_at: 'yesterday' is not valid 'date-time'",
+		}, validationErr.Errors)
+	})
+
+	t.Run("error when data does not match the version", func(t *testing.T) {
+		err := registry.Validate("todo.created", schema.URI("todo.created", 2), []byte(`{"title": "Buy milk"}`))
+
+		var validationErr *schema.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, 2, validationErr.Version)
+	})
+
+	t.Run("error when data is not json", func(t *testing.T) {
+		err := registry.Validate("todo.created", schema.URI("todo.created", 1), []byte(`title`))
+
+		var validationErr *schema.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+	})
+
+	t.Run("error when schema is unknown", func(t *testing.T) {
+		for _, dataSchema := range []string{"", schema.URI("todo.created", 3), schema.URI("todo.deleted", 1)} {
+			err := registry.Validate("todo.created", dataSchema, []byte(`{"title": "Buy milk"}`))
+
+			assert.ErrorIs(t, err, schema.ErrUnknownSchema)
+		}
+	})
+
+	t.Run("error when schema type does not match", func(t *testing.T) {
+		err := registry.Validate("todo.deleted", schema.URI("todo.created", 1), []byte(`{"title": "Buy milk"}`))
+
+		assert.ErrorIs(t, err, schema.ErrUnknownSchema)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("error when schema is invalid", func(t *testing.T) {
+		_, err := schema.New(fstest.MapFS{
+			"todo.created/v1.json": {Data: []byte(`{"type": 1}`)},
+		})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestMarkdown(t *testing.T) {
+	registry, err := schema.New(schemas)
+	assert.NoError(t, err)
+
+	docs := string(schema.Markdown(registry.Schemas()))
+
+	assert.Contains(t, docs, "## todo.created v1\nA todo is created\n")
+	assert.Contains(t, docs, "| `title` | string | yes | Title |")
+	assert.Contains(t, docs, "| `created_at` | string (date-time) | no |  |")
+	assert.Contains(t, docs, "| `owner.id` | string | no | Owner id |")
+	assert.Less(t, strings.Index(docs, "## todo.created v1"), strings.Index(docs, "## todo.created v2"))
+}
@@ -0,0 +1,31 @@
+package httpserver
+
+import (
+	"net/http"
+
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+	responseutil "go-rengan/utils/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// registerAdminRoutes - operational endpoints under /admin, for the admins only
+func registerAdminRoutes(router chi.Router, watcher config.Watcher) {
+	router.Route("/admin", func(r chi.Router) {
+		r.Use(auth.RequireRole(auth.RoleAdmin))
+		r.Get("/config", configHandler(watcher))
+	})
+}
+
+// configHandler - effective config with the secrets redacted, and the settings applied on reload
+func configHandler(watcher config.Watcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseutil.ResponseOK(w, r, &responseutil.Success{
+			Data: responseutil.H{
+				"config": config.Redact(watcher.Current()),
+				"live":   config.LiveSettings(),
+			},
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package httpserver_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mockservice "go-rengan/todo/mocks/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	t.Run("success when principal is admin", func(t *testing.T) {
+		router := newServer(new(mockservice.Service))
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"live"`)
+	})
+
+	t.Run("error 403 when principal is not admin", func(t *testing.T) {
+		router := newServer(new(mockservice.Service))
+
+		for _, header := range []string{"Bearer valid", "ApiKey grk_read"} {
+			req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+			req.Header.Set("Authorization", header)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code, header)
+			assert.JSONEq(t, `{"success": false, "code": 403, "message": "Missing role admin"}`, rec.Body.String(), header)
+		}
+	})
+
+	t.Run("error 401 when request is anonymous", func(t *testing.T) {
+		router := newServer(new(mockservice.Service))
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -0,0 +1,152 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	apikey "go-rengan/pkg/apikey"
+	auth "go-rengan/pkg/auth"
+	tenant "go-rengan/pkg/tenant"
+	validator "go-rengan/pkg/validator"
+	errorsutil "go-rengan/utils/errors"
+	responseutil "go-rengan/utils/response"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// defaultAPIKeyDays - lifetime of the api keys created without expires_in_days
+const defaultAPIKeyDays = 90
+
+// APIKeyRequest - api key to create
+type APIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=todo:read todo:write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+func (request *APIKeyRequest) Bind(r *http.Request) error {
+	return validator.ValidateStruct(request)
+}
+
+// registerAPIKeyRoutes - management of the api keys of the user under /apikeys
+func registerAPIKeyRoutes(router chi.Router, keys apikey.Keys) {
+	router.Route("/apikeys", func(r chi.Router) {
+		r.Use(requireUserToken)
+		r.Get("/", listAPIKeysHandler(keys))
+		r.Post("/", createAPIKeyHandler(keys))
+		r.Delete("/{id}", revokeAPIKeyHandler(keys))
+	})
+}
+
+// requireUserToken - an api key cannot manage the api keys, else a leaked key could mint new ones
+func requireUserToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			responseutil.Unauthorized(w, r, "Missing bearer token")
+			return
+		}
+
+		if principal.APIKeyID != "" {
+			responseutil.Forbidden(w, r, "API keys are managed with a bearer token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func listAPIKeysHandler(keys apikey.Keys) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+
+		results, err := keys.List(r.Context(), principal.Subject)
+		if err != nil {
+			responseutil.ErrorInternal(w, r, err)
+			return
+		}
+
+		responseutil.ResponseOK(w, r, &responseutil.Success{
+			Data: results,
+		})
+	}
+}
+
+func createAPIKeyHandler(keys apikey.Keys) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+
+		data := &APIKeyRequest{}
+		if err := render.Bind(r, data); err != nil {
+			if err.Error() == errorsutil.ErrEOF.Error() {
+				responseutil.ErrorBody(w, r, err)
+				return
+			}
+
+			responseutil.ErrorValidation(w, r, err)
+			return
+		}
+
+		// A key cannot do more than the token which creates it
+		for _, scope := range data.Scopes {
+			if !principal.Allows(scope) {
+				responseutil.Forbidden(w, r, "Missing scope "+scope)
+				return
+			}
+		}
+
+		days := data.ExpiresInDays
+		if days == 0 {
+			days = defaultAPIKeyDays
+		}
+
+		// The key is bound to the tenant it is created in
+		tenantID, _ := tenant.FromContext(r.Context())
+		key := &apikey.Key{
+			Name:      data.Name,
+			OwnerID:   principal.Subject,
+			TenantID:  tenantID,
+			Scopes:    data.Scopes,
+			ExpiresAt: time.Now().UTC().AddDate(0, 0, days),
+		}
+		token, err := keys.Create(r.Context(), key)
+		if err != nil {
+			responseutil.ErrorInternal(w, r, err)
+			return
+		}
+
+		// The token is only shown once, the store keeps its hash
+		responseutil.Created(w, r, &responseutil.Success{
+			Data: responseutil.H{
+				"key":   key,
+				"token": token,
+			},
+		})
+	}
+}
+
+func revokeAPIKeyHandler(keys apikey.Keys) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+		id := chi.URLParam(r, "id")
+
+		err := keys.Revoke(r.Context(), principal.Subject, id)
+		if err != nil {
+			if errors.Is(err, apikey.ErrNotFound) {
+				responseutil.NotFound(w, r, "API key not found")
+				return
+			}
+
+			responseutil.ErrorInternal(w, r, err)
+			return
+		}
+
+		responseutil.ResponseOK(w, r, &responseutil.Success{
+			Data: responseutil.H{
+				"id": id,
+			},
+		})
+	}
+}
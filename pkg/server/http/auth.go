@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+
+	auth "go-rengan/pkg/auth"
+	logger "go-rengan/pkg/logger"
+	responseutil "go-rengan/utils/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PublicRoutes - handler with routes which are served without authentication
+type PublicRoutes interface {
+	RegisterPublicRoutes(router chi.Router)
+}
+
+// authenticate - reject the requests without valid credentials, the principal of the credentials is put in the context.
+// A bearer token is checked by tokens and an api key, sent as "Authorization: ApiKey <key>", by apiKeys
+func authenticate(tokens auth.Authenticator, apiKeys auth.Authenticator, logger logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, ok := credentials(r)
+			if !ok {
+				w.Header().Add("WWW-Authenticate", `Bearer`)
+				w.Header().Add("WWW-Authenticate", `ApiKey`)
+				responseutil.Unauthorized(w, r, "Missing bearer token")
+				return
+			}
+
+			authenticator, challenge, message := tokens, `Bearer error="invalid_token"`, "Invalid bearer token"
+			if scheme == schemeAPIKey {
+				authenticator, challenge, message = apiKeys, `ApiKey error="invalid_key"`, "Invalid api key"
+			}
+
+			principal, err := authenticator.Authenticate(r.Context(), token)
+			if err != nil {
+				logger.Debug(r.Context(), "http request unauthenticated", "scheme", scheme, "error", err)
+				w.Header().Set("WWW-Authenticate", challenge)
+				responseutil.Unauthorized(w, r, message)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// Authorization schemes of the requests
+const (
+	schemeBearer = "bearer"
+	schemeAPIKey = "apikey"
+)
+
+// credentials - scheme, in lower case, and token of the Authorization header
+func credentials(r *http.Request) (string, string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	scheme = strings.ToLower(scheme)
+	if !ok || (scheme != schemeBearer && scheme != schemeAPIKey) {
+		return "", "", false
+	}
+	token = strings.TrimSpace(token)
+	return scheme, token, token != ""
+}
@@ -0,0 +1,254 @@
+package httpserver_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	apikey "go-rengan/pkg/apikey"
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+	health "go-rengan/pkg/health"
+	idempotency "go-rengan/pkg/idempotency"
+	logger "go-rengan/pkg/logger"
+	metrics "go-rengan/pkg/metrics"
+	ratelimit "go-rengan/pkg/ratelimit"
+	httpserver "go-rengan/pkg/server/http"
+	tracing "go-rengan/pkg/tracing"
+	httpdelivery "go-rengan/todo/delivery/http"
+	mockservice "go-rengan/todo/mocks/service"
+	"go-rengan/todo/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// tokens - authenticator which knows the given tokens
+type tokens map[string]*auth.Principal
+
+func (t tokens) Authenticate(ctx context.Context, token string) (*auth.Principal, error) {
+	principal, ok := t[token]
+	if !ok {
+		return nil, errors.New("unknown token")
+	}
+	return principal, nil
+}
+
+// apiKeys - api keys which know the given tokens, the created keys are kept in memory
+type apiKeys struct {
+	tokens
+	created []*apikey.Key
+}
+
+func (k *apiKeys) Create(ctx context.Context, key *apikey.Key) (string, error) {
+	k.created = append(k.created, key)
+	return "grk_created", nil
+}
+
+func (k *apiKeys) List(ctx context.Context, ownerID string) ([]*apikey.Key, error) {
+	return k.created, nil
+}
+
+func (k *apiKeys) Revoke(ctx context.Context, ownerID string, id string) error {
+	return apikey.ErrNotFound
+}
+
+func newServer(service *mockservice.Service) http.Handler {
+	return newServerWithKeys(service, &apiKeys{})
+}
+
+func newServerWithKeys(service *mockservice.Service, keys *apiKeys) http.Handler {
+	return newServerWithConfig(&config.Config{App: config.AppConfig{Name: "go-rengan", Port: 3333}}, service, keys)
+}
+
+func newServerWithConfig(cfg *config.Config, service *mockservice.Service, keys *apiKeys) http.Handler {
+	watcher := config.NewWatcher("", cfg, logger.NewNop())
+	authenticator := tokens{"valid": {Subject: "user-1"}, "other": {Subject: "user-2"}, "globex": {Subject: "user-3", Tenant: "globex"},
+		"acme": {Subject: "user-1", Tenant: "acme"}, "admin": {Subject: "admin-1", Roles: []string{auth.RoleAdmin}},
+		"reader": {Subject: "user-4", Scopes: []string{models.ScopeRead}}, "globex-1": {Subject: "user-1", Tenant: "globex"}}
+	keys.tokens = tokens{"grk_read": {Subject: "user-1", Scopes: []string{models.ScopeRead}, APIKeyID: "key-1"}}
+	handler := httpdelivery.New(tracing.NewNoop(), service, idempotency.NewNoop())
+
+	return httpserver.New(cfg.App, watcher, logger.NewNop(), metrics.NewNoop(), health.New(cfg.Health), authenticator, keys, ratelimit.NewMemoryStore(), handler).GetRouter()
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Run("error 401 when token is missing or invalid", func(t *testing.T) {
+		router := newServer(new(mockservice.Service))
+
+		messages := map[string]string{
+			"":                   "Missing bearer token",
+			"Basic dXNlcjpwYXNz": "Missing bearer token",
+			"Bearer ":            "Missing bearer token",
+			"Bearer invalid":     "Invalid bearer token",
+			"ApiKey grk_invalid": "Invalid api key",
+		}
+
+		for header, message := range messages {
+			req := httptest.NewRequest(http.MethodGet, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, header)
+			assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"), header)
+			assert.JSONEq(t, `{"success": false, "code": 401, "message": "`+message+`"}`, rec.Body.String(), header)
+		}
+	})
+
+	t.Run("success when token is valid", func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("GetByID", mock.Anything, mock.AnythingOfType("string")).Return(&models.Todo{Title: "title"}, nil)
+		router := newServer(service)
+
+		req := httptest.NewRequest(http.MethodGet, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+		req.Header.Set("Authorization", "Bearer valid")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("success without token on public routes", func(t *testing.T) {
+		router := newServer(new(mockservice.Service))
+
+		for _, path := range []string{"/", "/healthz"} {
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code, path)
+		}
+	})
+
+	t.Run("success without token on the public routes of the handler", func(t *testing.T) {
+		router := newServer(new(mockservice.Service))
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/schemas/todo.created/v1.json", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+		assert.Contains(t, rec.Body.String(), `"$schema"`)
+
+		// The other routes of the handler still need a token
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todo", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestAPIKey(t *testing.T) {
+	t.Run("success on the routes of the scopes of the key", func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("GetByID", mock.Anything, mock.AnythingOfType("string")).Return(&models.Todo{Title: "title"}, nil)
+		router := newServer(service)
+
+		req := httptest.NewRequest(http.MethodGet, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+		req.Header.Set("Authorization", "ApiKey grk_read")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("error 403 when key is missing the scope of the route", func(t *testing.T) {
+		service := new(mockservice.Service)
+		router := newServer(service)
+
+		req := httptest.NewRequest(http.MethodDelete, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+		req.Header.Set("Authorization", "ApiKey grk_read")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.JSONEq(t, `{"success": false, "code": 403, "message": "Missing scope todo:write"}`, rec.Body.String())
+		service.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("error 403 when key manages the api keys", func(t *testing.T) {
+		router := newServer(new(mockservice.Service))
+
+		req := httptest.NewRequest(http.MethodGet, "/apikeys", nil)
+		req.Header.Set("Authorization", "ApiKey grk_read")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestAPIKeyRoutes(t *testing.T) {
+	t.Run("success create returns the token once", func(t *testing.T) {
+		keys := &apiKeys{}
+		router := newServerWithKeys(new(mockservice.Service), keys)
+
+		req := httptest.NewRequest(http.MethodPost, "/apikeys", strings.NewReader(`{"name": "batch", "scopes": ["todo:read"]}`))
+		req.Header.Set("Authorization", "Bearer valid")
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"token":"grk_created"`)
+		assert.Len(t, keys.created, 1)
+		assert.Equal(t, "user-1", keys.created[0].OwnerID)
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, 90), keys.created[0].ExpiresAt, time.Minute)
+	})
+
+	t.Run("error 400 when scope is unknown", func(t *testing.T) {
+		keys := &apiKeys{}
+		router := newServerWithKeys(new(mockservice.Service), keys)
+
+		req := httptest.NewRequest(http.MethodPost, "/apikeys", strings.NewReader(`{"name": "batch", "scopes": ["todo:admin"]}`))
+		req.Header.Set("Authorization", "Bearer valid")
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, keys.created)
+	})
+
+	t.Run("error 403 when scope is not allowed to the token", func(t *testing.T) {
+		keys := &apiKeys{}
+		router := newServerWithKeys(new(mockservice.Service), keys)
+
+		req := httptest.NewRequest(http.MethodPost, "/apikeys", strings.NewReader(`{"name": "batch", "scopes": ["todo:read", "todo:write"]}`))
+		req.Header.Set("Authorization", "Bearer reader")
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.JSONEq(t, `{"success": false, "code": 403, "message": "Missing scope todo:write"}`, rec.Body.String())
+		assert.Empty(t, keys.created)
+	})
+
+	t.Run("error 404 when revoked key is unknown", func(t *testing.T) {
+		router := newServer(new(mockservice.Service))
+
+		req := httptest.NewRequest(http.MethodDelete, "/apikeys/62f0c1a5e1b2c3d4e5f60718", nil)
+		req.Header.Set("Authorization", "Bearer valid")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
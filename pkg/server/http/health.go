@@ -0,0 +1,38 @@
+package httpserver
+
+import (
+	"net/http"
+
+	health "go-rengan/pkg/health"
+	responseutil "go-rengan/utils/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// registerHealthRoutes - probes of the orchestrator, /healthz while the process runs and /readyz while its dependencies are
+func registerHealthRoutes(router chi.Router, h health.Health) {
+	router.Get("/healthz", livenessHandler())
+	router.Get("/readyz", readinessHandler(h))
+}
+
+// livenessHandler - the process answers, the dependencies are not checked so their outage does not restart it
+func livenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseutil.ResponseOK(w, r, &responseutil.Success{
+			Data: responseutil.H{"status": health.StatusOK},
+		})
+	}
+}
+
+// readinessHandler - report of the checks, 503 when one fails or the server is shutting down
+func readinessHandler(h health.Health) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := h.Ready(r.Context())
+		if !report.OK() {
+			responseutil.ServiceUnavailable(w, r, &responseutil.Success{Data: report})
+			return
+		}
+
+		responseutil.ResponseOK(w, r, &responseutil.Success{Data: report})
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
 
+	apikey "go-rengan/pkg/apikey"
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+	health "go-rengan/pkg/health"
 	logger "go-rengan/pkg/logger"
+	metrics "go-rengan/pkg/metrics"
+	ratelimit "go-rengan/pkg/ratelimit"
 	todohttp "go-rengan/todo/delivery/http"
 	responseutil "go-rengan/utils/response"
 
@@ -14,7 +19,6 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/riandyrn/otelchi"
-	"github.com/sirupsen/logrus"
 )
 
 type HTTPServer interface {
@@ -31,32 +35,75 @@ type HTTPServerImpl struct {
 }
 
 func New(
+	cfg config.AppConfig,
+	watcher config.Watcher,
 	logger logger.Logger,
+	metrics metrics.Metrics,
+	health health.Health,
+	authenticator auth.Authenticator,
+	apiKeys apikey.Keys,
+	limits ratelimit.Store,
 	todoHandler todohttp.HTTPHandler,
 ) HTTPServer {
 	router := chi.NewRouter()
-	router.Use(otelchi.Middleware(os.Getenv("APP_NAME"), otelchi.WithChiRoutes(router)))
+	router.Use(otelchi.Middleware(cfg.Name, otelchi.WithChiRoutes(router)))
 	router.Use(
 		render.SetContentType(render.ContentTypeJSON), // Set content-Type headers as application/json
-		middleware.Logger,          // Log API request calls
-		middleware.Compress(5),     // Compress results, mostly gzipping assets and json
-		middleware.RedirectSlashes, // Redirect slashes to no slash URL versions
-		middleware.Recoverer,       // Recover from panics without crashing server
+		middleware.RequestID,                          // Set X-Request-Id of the request in the context
+		requestLogger(logger),                         // Log API request calls with the trace of the request
+		requestMetrics(metrics),                       // Record rate, errors and duration of the requests by route
+		middleware.Compress(5),                        // Compress results, mostly gzipping assets and json
+		middleware.RedirectSlashes,                    // Redirect slashes to no slash URL versions
+		middleware.Recoverer,                          // Recover from panics without crashing server
 	)
 
 	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		render.JSON(w, r, responseutil.H{
 			"success": "true",
 			"code":    200,
-			"message": fmt.Sprintf("Services %s run properly", os.Getenv("APP_NAME")),
+			"message": fmt.Sprintf("Services %s run properly", cfg.Name),
 		})
 	})
 
-	// Register TodoHTTPHandler routes
-	todoHandler.RegisterRoutes(router)
+	registerHealthRoutes(router, health)
+
+	// The routes of the handlers need a bearer token or an api key and act for the tenant of the request,
+	// except the ones they register as public which act for no tenant. They are rate limited by client
+	tenancy := watcher.Current().Tenant
+	if public, ok := todoHandler.(PublicRoutes); ok {
+		router.Group(func(r chi.Router) {
+			r.Use(rateLimit(watcher, limits, logger))
+
+			public.RegisterPublicRoutes(r)
+		})
+	}
+	router.Group(func(r chi.Router) {
+		r.Use(authenticate(authenticator, apiKeys, logger))
+		r.Use(rateLimit(watcher, limits, logger))
+
+		registerAdminRoutes(r, watcher)
+
+		r.Group(func(r chi.Router) {
+			r.Use(resolveTenant(tenancy))
+
+			// Register TodoHTTPHandler routes
+			todoHandler.RegisterRoutes(r)
+
+			registerAPIKeyRoutes(r, apiKeys)
+		})
+	})
+
+	// Serve the metrics to the Prometheus scraper when they are not pushed to the collector
+	if handler := metrics.Handler(); handler != nil {
+		router.Method(http.MethodGet, "/metrics", handler)
+	}
 
 	s := &HTTPServerImpl{
 		router: router,
+		svr: &http.Server{
+			Addr:    fmt.Sprintf(":%d", cfg.Port),
+			Handler: router,
+		},
 		logger: logger,
 	}
 
@@ -68,25 +115,18 @@ func New(
 // PrintAllRoutes - Walk and print out all routes
 func (hs *HTTPServerImpl) PrintAllRoutes() {
 	walkFunc := func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
-		logrus.Printf("%s %s\n", method, route)
+		hs.logger.Debug(context.Background(), "http route", "method", method, "route", route)
 		return nil
 	}
 	router := hs.GetRouter()
 	if err := chi.Walk(router, walkFunc); err != nil {
-		hs.logger.Error(err)
+		hs.logger.Error(context.Background(), "http routes walk failed", "error", err)
 	}
 }
 
 // Run - running server
 func (hs *HTTPServerImpl) Run() error {
-	addr := fmt.Sprintf("%s%s", ":", os.Getenv("PORT"))
-	logrus.Infoln("HTTP server listening on", addr)
-
-	router := hs.GetRouter()
-	hs.svr = &http.Server{
-		Addr:    addr,
-		Handler: router,
-	}
+	hs.logger.Info(context.Background(), "http server listening", "addr", hs.svr.Addr)
 
 	err := hs.svr.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
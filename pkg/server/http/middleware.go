@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"net/http"
+	"time"
+
+	logger "go-rengan/pkg/logger"
+	metrics "go-rengan/pkg/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// unmatchedRoute - route of the requests which match no route, the path is not used to keep the series bounded
+const unmatchedRoute = "unmatched"
+
+// requestLogger - log every request with its route, status, size and duration,
+// at warn level for client errors and error level for server errors
+func requestLogger(l logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				route := ""
+				if rctx := chi.RouteContext(r.Context()); rctx != nil {
+					route = rctx.RoutePattern()
+				}
+
+				fields := []interface{}{
+					"method", r.Method,
+					"path", r.URL.Path,
+					"route", route,
+					"status", status,
+					"bytes", ww.BytesWritten(),
+					"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+					"remote_addr", r.RemoteAddr,
+					"request_id", middleware.GetReqID(r.Context()),
+				}
+
+				switch {
+				case status >= http.StatusInternalServerError:
+					l.Error(r.Context(), "http request", fields...)
+				case status >= http.StatusBadRequest:
+					l.Warn(r.Context(), "http request", fields...)
+				default:
+					l.Info(r.Context(), "http request", fields...)
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// requestMetrics - count every request and record its duration by method, route pattern and status,
+// the server errors are counted apart so their rate can be alerted on
+func requestMetrics(m metrics.Metrics) func(next http.Handler) http.Handler {
+	meter := m.Meter("go-rengan/pkg/server/http")
+	requests := metrics.Counter(meter, "http.server.requests", "HTTP requests handled")
+	errors := metrics.Counter(meter, "http.server.errors", "HTTP requests answered with a server error")
+	duration := metrics.Histogram(meter, "http.server.duration", "Duration of the HTTP requests")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				route := unmatchedRoute
+				if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+					route = rctx.RoutePattern()
+				}
+
+				attrs := []attribute.KeyValue{
+					semconv.HTTPMethodKey.String(r.Method),
+					semconv.HTTPRouteKey.String(route),
+					semconv.HTTPStatusCodeKey.Int(status),
+				}
+				ctx := r.Context()
+				requests.Add(ctx, 1, attrs...)
+				duration.Record(ctx, float64(time.Since(start).Microseconds())/1000, attrs...)
+				if status >= http.StatusInternalServerError {
+					errors.Add(ctx, 1, attrs...)
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package httpserver
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+	logger "go-rengan/pkg/logger"
+	ratelimit "go-rengan/pkg/ratelimit"
+	tenant "go-rengan/pkg/tenant"
+	responseutil "go-rengan/utils/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// rateLimit - take a token from the bucket of the client for every request, the requests of an empty bucket get a 429.
+// A route with its own rate has its own bucket, the other routes share the bucket of the default rate.
+// The rates are read from the current config, so a reload applies them
+func rateLimit(watcher config.Watcher, store ratelimit.Store, logger logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := watcher.Current().RateLimit
+			if !cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			route := r.Method + " " + chi.RouteContext(r.Context()).RoutePattern()
+			key := client(r)
+			rate, ok := cfg.Routes[route]
+			if ok {
+				key += " " + route
+			} else {
+				rate = cfg.Default
+			}
+
+			res, err := store.Take(r.Context(), key, rate, time.Now())
+			if err != nil {
+				// A store down does not take the service down with it
+				logger.Error(r.Context(), "rate limit unavailable, request allowed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(res.Reset))
+			if !res.Allowed {
+				w.Header().Set("Retry-After", seconds(res.RetryAfter))
+				responseutil.TooManyRequests(w, r, "Too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// client - key of the bucket of the caller, its api key, else its user, else its ip.
+// The user ids are only unique within a tenant, the same user in two tenants has two buckets
+func client(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		if principal.APIKeyID != "" {
+			return "apikey:" + principal.APIKeyID
+		}
+
+		tenantID, ok := tenant.FromContext(r.Context())
+		if !ok {
+			tenantID = principal.Tenant
+		}
+		if tenantID != "" {
+			return "tenant:" + tenantID + " user:" + principal.Subject
+		}
+		return "user:" + principal.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds - whole seconds of d rounded up, the headers have no fractions
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,121 @@
+package httpserver_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	config "go-rengan/pkg/config"
+	mockservice "go-rengan/todo/mocks/service"
+	"go-rengan/todo/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newLimitedServer() http.Handler {
+	service := new(mockservice.Service)
+	service.On("GetByID", mock.Anything, mock.AnythingOfType("string")).Return(&models.Todo{Title: "title"}, nil)
+	service.On("Collaborators", mock.Anything, mock.AnythingOfType("string")).Return([]models.Collaborator{}, nil)
+
+	cfg := &config.Config{
+		App: config.AppConfig{Name: "go-rengan", Port: 3333},
+		RateLimit: config.RateLimitConfig{
+			Enabled: true,
+			Default: config.Rate{Requests: 2, Period: time.Minute},
+			Routes: map[string]config.Rate{
+				"GET /todo/{id}/collaborators": {Requests: 1, Period: time.Minute},
+			},
+		},
+	}
+
+	return newServerWithConfig(cfg, service, &apiKeys{})
+}
+
+func get(router http.Handler, path string, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", authorization)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("error 429 when bucket of client is empty", func(t *testing.T) {
+		router := newLimitedServer()
+
+		rec := get(router, "/todo/62f0c1a5e1b2c3d4e5f60718", "Bearer valid")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", rec.Header().Get("RateLimit-Reset"))
+
+		rec = get(router, "/todo/62f0c1a5e1b2c3d4e5f60719", "Bearer valid")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = get(router, "/todo/62f0c1a5e1b2c3d4e5f60718", "Bearer valid")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"success": false, "code": 429, "message": "Too many requests"}`, rec.Body.String())
+	})
+
+	t.Run("success when other client or api key sends requests", func(t *testing.T) {
+		router := newLimitedServer()
+		for i := 0; i < 2; i++ {
+			get(router, "/todo/62f0c1a5e1b2c3d4e5f60718", "Bearer valid")
+		}
+
+		assert.Equal(t, http.StatusOK, get(router, "/todo/62f0c1a5e1b2c3d4e5f60718", "Bearer other").Code)
+		assert.Equal(t, http.StatusOK, get(router, "/todo/62f0c1a5e1b2c3d4e5f60718", "ApiKey grk_read").Code)
+	})
+
+	t.Run("success when same user sends requests in another tenant", func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("GetByID", mock.Anything, mock.AnythingOfType("string")).Return(&models.Todo{Title: "title"}, nil)
+		cfg := &config.Config{
+			App:       config.AppConfig{Name: "go-rengan", Port: 3333},
+			RateLimit: config.RateLimitConfig{Enabled: true, Default: config.Rate{Requests: 1, Period: time.Minute}},
+			Tenant:    config.TenantConfig{Enabled: true, Resolver: "header", Header: "X-Tenant-ID", Isolation: "collection"},
+		}
+		router := newServerWithConfig(cfg, service, &apiKeys{})
+
+		inTenant := func(authorization string, tenantID string) int {
+			req := httptest.NewRequest(http.MethodGet, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+			req.Header.Set("Authorization", authorization)
+			req.Header.Set("X-Tenant-ID", tenantID)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		assert.Equal(t, http.StatusOK, inTenant("Bearer acme", "acme"))
+		assert.Equal(t, http.StatusTooManyRequests, inTenant("Bearer acme", "acme"))
+		assert.Equal(t, http.StatusOK, inTenant("Bearer globex-1", "globex"))
+	})
+
+	t.Run("success when route has its own bucket", func(t *testing.T) {
+		router := newLimitedServer()
+
+		rec := get(router, "/todo/62f0c1a5e1b2c3d4e5f60718/collaborators", "Bearer valid")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, http.StatusTooManyRequests, get(router, "/todo/62f0c1a5e1b2c3d4e5f60718/collaborators", "Bearer valid").Code)
+
+		assert.Equal(t, http.StatusOK, get(router, "/todo/62f0c1a5e1b2c3d4e5f60718", "Bearer valid").Code)
+	})
+
+	t.Run("success without headers when rate limit is disabled", func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("GetByID", mock.Anything, mock.AnythingOfType("string")).Return(&models.Todo{Title: "title"}, nil)
+		router := newServer(service)
+
+		rec := get(router, "/todo/62f0c1a5e1b2c3d4e5f60718", "Bearer valid")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	})
+}
@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+	tenant "go-rengan/pkg/tenant"
+	responseutil "go-rengan/utils/response"
+)
+
+// resolveTenant - put the tenant of the request in the context, read from the header, the claim of the token
+// or the subdomain as the config sets. A principal only acts in the tenant it is bound to, the admins act in any
+func resolveTenant(cfg config.TenantConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := auth.FromContext(r.Context())
+
+			id, err := tenant.Parse(tenantOf(r, cfg, principal))
+			if errors.Is(err, tenant.ErrMissing) {
+				responseutil.BadRequest(w, r, "Missing tenant")
+				return
+			}
+			if err != nil {
+				responseutil.BadRequest(w, r, "Invalid tenant")
+				return
+			}
+
+			if !allowedTenant(principal, id) {
+				responseutil.Forbidden(w, r, "You are not allowed to access this tenant")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), id)))
+		})
+	}
+}
+
+// allowedTenant - whether the principal acts in the tenant. A token without tenant claim or an api key created
+// before the tenancy was enabled is bound to none, the tenant header would otherwise let it act in any
+func allowedTenant(principal *auth.Principal, id string) bool {
+	if principal == nil {
+		return false
+	}
+	if principal.Tenant == "" {
+		return principal.HasRole(auth.RoleAdmin)
+	}
+
+	return principal.Tenant == id
+}
+
+// tenantOf - tenant named by the request, empty when it names none
+func tenantOf(r *http.Request, cfg config.TenantConfig, principal *auth.Principal) string {
+	switch cfg.Resolver {
+	case "claim":
+		if principal == nil {
+			return ""
+		}
+		return principal.Tenant
+	case "subdomain":
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		host = strings.ToLower(host)
+		subdomain := strings.TrimSuffix(host, "."+strings.ToLower(cfg.Domain))
+		if subdomain == host || strings.Contains(subdomain, ".") {
+			return ""
+		}
+		return subdomain
+	default:
+		return r.Header.Get(cfg.Header)
+	}
+}
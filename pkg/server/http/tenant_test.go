@@ -0,0 +1,173 @@
+package httpserver_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	config "go-rengan/pkg/config"
+	tenant "go-rengan/pkg/tenant"
+	mockservice "go-rengan/todo/mocks/service"
+	"go-rengan/todo/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// inTenant - matches a context acting for the tenant
+func inTenant(id string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		tenantID, _ := tenant.FromContext(ctx)
+		return tenantID == id
+	})
+}
+
+func newTenantServer(resolver string) (http.Handler, *mockservice.Service) {
+	service := new(mockservice.Service)
+	service.On("GetByID", inTenant("acme"), mock.AnythingOfType("string")).Return(&models.Todo{Title: "title"}, nil)
+
+	cfg := &config.Config{
+		App: config.AppConfig{Name: "go-rengan", Port: 3333},
+		Tenant: config.TenantConfig{
+			Enabled:   true,
+			Resolver:  resolver,
+			Header:    "X-Tenant-ID",
+			Domain:    "todo.example.com",
+			Isolation: "collection",
+		},
+	}
+
+	return newServerWithConfig(cfg, service, &apiKeys{}), service
+}
+
+func TestResolveTenant(t *testing.T) {
+	t.Run("success when header names the tenant", func(t *testing.T) {
+		router, service := newTenantServer("header")
+
+		req := httptest.NewRequest(http.MethodGet, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+		req.Header.Set("Authorization", "Bearer acme")
+		req.Header.Set("X-Tenant-ID", "Acme")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("success when subdomain names the tenant", func(t *testing.T) {
+		router, service := newTenantServer("subdomain")
+
+		req := httptest.NewRequest(http.MethodGet, "http://acme.todo.example.com:3333/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+		req.Header.Set("Authorization", "Bearer acme")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("error 400 when tenant is missing or invalid", func(t *testing.T) {
+		messages := map[string]string{
+			"":          "Missing tenant",
+			"acme.corp": "Invalid tenant",
+		}
+
+		for header, message := range messages {
+			router, service := newTenantServer("header")
+
+			req := httptest.NewRequest(http.MethodGet, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+			req.Header.Set("Authorization", "Bearer acme")
+			req.Header.Set("X-Tenant-ID", header)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, header)
+			assert.JSONEq(t, `{"success": false, "code": 400, "message": "`+message+`"}`, rec.Body.String(), header)
+			service.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("error 400 when token has no tenant claim", func(t *testing.T) {
+		router, _ := newTenantServer("claim")
+
+		req := httptest.NewRequest(http.MethodGet, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+		req.Header.Set("Authorization", "Bearer valid")
+		req.Header.Set("X-Tenant-ID", "acme")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("error 403 when principal is bound to another tenant", func(t *testing.T) {
+		router, service := newTenantServer("header")
+
+		req := httptest.NewRequest(http.MethodGet, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+		req.Header.Set("Authorization", "Bearer globex")
+		req.Header.Set("X-Tenant-ID", "acme")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		service.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("error 403 when principal is bound to no tenant", func(t *testing.T) {
+		for _, resolver := range []string{"header", "subdomain"} {
+			router, service := newTenantServer(resolver)
+
+			req := httptest.NewRequest(http.MethodGet, "http://acme.todo.example.com:3333/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+			req.Header.Set("Authorization", "Bearer valid")
+			req.Header.Set("X-Tenant-ID", "acme")
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code, resolver)
+			service.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("success when admin names any tenant", func(t *testing.T) {
+		router, service := newTenantServer("header")
+
+		req := httptest.NewRequest(http.MethodGet, "/todo/62f0c1a5e1b2c3d4e5f60718", nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		req.Header.Set("X-Tenant-ID", "acme")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("success without tenant nor token on public routes", func(t *testing.T) {
+		for _, resolver := range []string{"header", "claim", "subdomain"} {
+			router, _ := newTenantServer(resolver)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/schemas/todo.created/v1.json", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code, resolver)
+		}
+	})
+
+	t.Run("success without tenant on admin routes", func(t *testing.T) {
+		router, _ := newTenantServer("header")
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
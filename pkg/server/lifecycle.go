@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	logger "go-rengan/pkg/logger"
+)
+
+// ErrStopped - a component whose Run returned without error before the lifecycle stopped
+var ErrStopped = errors.New("component stopped unexpectedly")
+
+// Hook - how the lifecycle starts, runs and stops one component, every function is optional
+type Hook struct {
+	Name string
+	// OnStart prepares the component, the next components start after it returns
+	OnStart func(ctx context.Context) error
+	// Run runs the component until ctx is done, returning earlier is a failure which stops every component
+	Run func(ctx context.Context) error
+	// OnStop releases the component once the ctx of Run is done
+	OnStop func(ctx context.Context) error
+	// StopTimeout - how long the component may take to stop, the stop timeout of the lifecycle when it is zero
+	StopTimeout time.Duration
+}
+
+// Lifecycle - start the components in the order they are appended and stop them in the reverse order
+type Lifecycle interface {
+	Append(hook Hook)
+	// Start starts the components in order, when one fails the started ones are stopped
+	Start(ctx context.Context) error
+	// Done is closed when a component fails or the lifecycle stops
+	Done() <-chan struct{}
+	// Err is the failure of the first component which failed
+	Err() error
+	// Stop stops the started components in reverse order, each within its timeout
+	Stop(ctx context.Context) error
+}
+
+type LifecycleImpl struct {
+	logger      logger.Logger
+	stopTimeout time.Duration
+
+	mu       sync.Mutex
+	hooks    []Hook
+	started  []*component
+	stopping bool
+	err      error
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// component - started hook, exited is closed when its Run returns
+type component struct {
+	hook   Hook
+	cancel context.CancelFunc
+	exited chan struct{}
+}
+
+// NewLifecycle - make lifecycle whose components have stopTimeout to stop unless their hook sets one
+func NewLifecycle(logger logger.Logger, stopTimeout time.Duration) Lifecycle {
+	return &LifecycleImpl{
+		logger:      logger,
+		stopTimeout: stopTimeout,
+		done:        make(chan struct{}),
+	}
+}
+
+func (l *LifecycleImpl) Append(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hooks = append(l.hooks, hook)
+}
+
+func (l *LifecycleImpl) Start(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := l.hooks
+	l.mu.Unlock()
+
+	for _, hook := range hooks {
+		if hook.OnStart != nil {
+			if err := hook.OnStart(ctx); err != nil {
+				err = fmt.Errorf("start %s: %w", hook.Name, err)
+				l.fail(err)
+				if stopErr := l.Stop(context.Background()); stopErr != nil {
+					l.logger.Error(ctx, "stop after failed start failed", "error", stopErr)
+				}
+				return err
+			}
+		}
+
+		c := &component{hook: hook, cancel: func() {}}
+		if hook.Run != nil {
+			runCtx, cancel := context.WithCancel(context.Background())
+			c.cancel = cancel
+			c.exited = make(chan struct{})
+			go l.run(runCtx, c)
+		}
+
+		l.mu.Lock()
+		l.started = append(l.started, c)
+		l.mu.Unlock()
+		l.logger.Debug(ctx, "component started", "component", hook.Name)
+	}
+
+	return nil
+}
+
+// run - run the component, its return before it is stopped fails the lifecycle
+func (l *LifecycleImpl) run(ctx context.Context, c *component) {
+	defer close(c.exited)
+
+	err := c.hook.Run(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil {
+		err = ErrStopped
+	}
+	l.fail(fmt.Errorf("run %s: %w", c.hook.Name, err))
+}
+
+// fail - record the first failure and signal Done, failures while stopping are only logged
+func (l *LifecycleImpl) fail(err error) {
+	l.mu.Lock()
+	stopping := l.stopping
+	if l.err == nil && !stopping {
+		l.err = err
+	}
+	l.mu.Unlock()
+
+	l.logger.Error(context.Background(), "component failed", "error", err, "stopping", stopping)
+	l.doneOnce.Do(func() { close(l.done) })
+}
+
+func (l *LifecycleImpl) Done() <-chan struct{} {
+	return l.done
+}
+
+func (l *LifecycleImpl) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.err
+}
+
+// Stop - every component gets its stop even when a previous one failed, the first stop error is returned
+func (l *LifecycleImpl) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	l.stopping = true
+	started := l.started
+	l.started = nil
+	l.mu.Unlock()
+	defer l.doneOnce.Do(func() { close(l.done) })
+
+	var firstErr error
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		err := l.stop(ctx, c)
+		if err != nil {
+			l.logger.Error(ctx, "component stop failed", "component", c.hook.Name, "error", err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("stop %s: %w", c.hook.Name, err)
+			}
+			continue
+		}
+		l.logger.Debug(ctx, "component stopped", "component", c.hook.Name)
+	}
+
+	return firstErr
+}
+
+// stop - cancel the run of the component, call its OnStop and wait for its run to return, all within its timeout
+func (l *LifecycleImpl) stop(ctx context.Context, c *component) error {
+	timeout := c.hook.StopTimeout
+	if timeout <= 0 {
+		timeout = l.stopTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c.cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		var err error
+		if c.hook.OnStop != nil {
+			err = c.hook.OnStop(ctx)
+		}
+		if err == nil && c.exited != nil {
+			select {
+			case <-c.exited:
+			case <-ctx.Done():
+			}
+		}
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			err = ctx.Err()
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
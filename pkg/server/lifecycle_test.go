@@ -0,0 +1,145 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	logger "go-rengan/pkg/logger"
+	server "go-rengan/pkg/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder - order of the hook calls
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string{}, r.calls...)
+}
+
+func (r *recorder) hook(name string) server.Hook {
+	return server.Hook{
+		Name: name,
+		OnStart: func(ctx context.Context) error {
+			r.record("start " + name)
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			r.record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	t.Run("success start in order and stop in reverse order", func(t *testing.T) {
+		r := &recorder{}
+		lc := server.NewLifecycle(logger.NewNop(), time.Second)
+		lc.Append(r.hook("mongo"))
+		lc.Append(r.hook("amqp"))
+		lc.Append(r.hook("http"))
+
+		assert.NoError(t, lc.Start(context.Background()))
+		assert.NoError(t, lc.Stop(context.Background()))
+
+		assert.Equal(t, []string{"start mongo", "start amqp", "start http", "stop http", "stop amqp", "stop mongo"}, r.get())
+		assert.NoError(t, lc.Err())
+	})
+
+	t.Run("error stop started components when one fails to start", func(t *testing.T) {
+		r := &recorder{}
+		lc := server.NewLifecycle(logger.NewNop(), time.Second)
+		lc.Append(r.hook("mongo"))
+		lc.Append(server.Hook{
+			Name:    "amqp",
+			OnStart: func(ctx context.Context) error { return errors.New("connection refused") },
+		})
+		lc.Append(r.hook("http"))
+
+		err := lc.Start(context.Background())
+
+		assert.ErrorContains(t, err, "start amqp: connection refused")
+		assert.Equal(t, []string{"start mongo", "stop mongo"}, r.get())
+	})
+
+	t.Run("error stop every component when one fails while running", func(t *testing.T) {
+		r := &recorder{}
+		lc := server.NewLifecycle(logger.NewNop(), time.Second)
+		lc.Append(r.hook("mongo"))
+		lc.Append(server.Hook{
+			Name: "http",
+			Run:  func(ctx context.Context) error { return errors.New("address already in use") },
+		})
+		lc.Append(server.Hook{
+			Name: "consumer",
+			Run: func(ctx context.Context) error {
+				<-ctx.Done()
+				r.record("consumer returned")
+				return nil
+			},
+		})
+
+		assert.NoError(t, lc.Start(context.Background()))
+		select {
+		case <-lc.Done():
+		case <-time.After(time.Second):
+			t.Fatal("failure did not stop the lifecycle")
+		}
+		assert.NoError(t, lc.Stop(context.Background()))
+
+		assert.ErrorContains(t, lc.Err(), "run http: address already in use")
+		assert.Equal(t, []string{"start mongo", "consumer returned", "stop mongo"}, r.get())
+	})
+
+	t.Run("error when run returns before stop", func(t *testing.T) {
+		lc := server.NewLifecycle(logger.NewNop(), time.Second)
+		lc.Append(server.Hook{
+			Name: "scheduler",
+			Run:  func(ctx context.Context) error { return nil },
+		})
+
+		assert.NoError(t, lc.Start(context.Background()))
+		<-lc.Done()
+
+		assert.ErrorIs(t, lc.Err(), server.ErrStopped)
+	})
+
+	t.Run("error stop next components when one exceeds its timeout", func(t *testing.T) {
+		r := &recorder{}
+		lc := server.NewLifecycle(logger.NewNop(), time.Second)
+		lc.Append(r.hook("mongo"))
+		lc.Append(server.Hook{
+			Name: "http",
+			OnStop: func(ctx context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			},
+			StopTimeout: 10 * time.Millisecond,
+		})
+
+		assert.NoError(t, lc.Start(context.Background()))
+		start := time.Now()
+		err := lc.Stop(context.Background())
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "stop http")
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, []string{"start mongo", "stop mongo"}, r.get())
+	})
+}
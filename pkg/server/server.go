@@ -2,66 +2,161 @@ package server
 
 import (
 	"context"
+	"errors"
 	amqp "go-rengan/pkg/amqp"
+	scheduler "go-rengan/pkg/amqp/scheduler"
+	broker "go-rengan/pkg/broker"
+	config "go-rengan/pkg/config"
+	health "go-rengan/pkg/health"
 	logger "go-rengan/pkg/logger"
+	metrics "go-rengan/pkg/metrics"
 	mongodb "go-rengan/pkg/mongodb"
 	httpserver "go-rengan/pkg/server/http"
 	tracing "go-rengan/pkg/tracing"
 	todoamqpdelivery "go-rengan/todo/delivery/amqp"
-
-	"github.com/sirupsen/logrus"
+	"time"
 )
 
+var errConsumersStopped = errors.New("amqp consumers are not running")
+
 type ServerImpl struct {
-	httpServer       httpserver.HTTPServer
-	logger           logger.Logger
-	Tracing          tracing.Tracing
-	TodoAMQPConsumer todoamqpdelivery.AMQPConsumer
-	MongoDB          mongodb.MongoDB
-	AMQP             amqp.AMQP
+	logger    logger.Logger
+	Health    health.Health
+	Lifecycle Lifecycle
 }
 
+// NewServer - make the server, its components stop in the reverse order they start:
+// the readiness fails first, then the http server, the consumers, the schedulers and the connections.
+// The server is ready while mongo and the broker answer and the consumers run.
 func NewServer(
+	cfg config.AppConfig,
+	healthConfig config.HealthConfig,
+	health health.Health,
 	tracing tracing.Tracing,
+	metrics metrics.Metrics,
 	logger logger.Logger,
 	amqp amqp.AMQP,
+	messageBroker broker.Broker,
 	todoAMQPConsumer todoamqpdelivery.AMQPConsumer,
+	todoRPCResponder todoamqpdelivery.RPCResponder,
+	scheduler scheduler.Scheduler,
+	configWatcher config.Watcher,
 	mongoDB mongodb.MongoDB,
 	httpServer httpserver.HTTPServer,
 ) *ServerImpl {
+	health.Register("mongo", mongoDB.Ping)
+	health.Register("amqp", amqp.Ping)
+	health.Register("consumers", func(ctx context.Context) error {
+		if !todoAMQPConsumer.Running() || !todoRPCResponder.Running() {
+			return errConsumersStopped
+		}
+		return nil
+	})
+
+	lc := NewLifecycle(logger, cfg.StopTimeout)
+	lc.Append(Hook{
+		Name:   "tracing",
+		OnStop: tracing.ShutDown,
+	})
+	lc.Append(Hook{
+		Name:   "metrics",
+		OnStop: metrics.ShutDown,
+	})
+	lc.Append(Hook{
+		Name:   "mongo",
+		OnStop: mongoDB.Disconnect,
+	})
+	lc.Append(Hook{
+		Name: "amqp",
+		OnStop: func(ctx context.Context) error {
+			return amqp.Close()
+		},
+	})
+	lc.Append(Hook{
+		Name: "broker",
+		OnStop: func(ctx context.Context) error {
+			return messageBroker.Close()
+		},
+	})
+	lc.Append(Hook{
+		Name: "config watcher",
+		Run: func(ctx context.Context) error {
+			configWatcher.Run(ctx)
+			return nil
+		},
+	})
+	lc.Append(Hook{
+		Name: "scheduler",
+		Run: func(ctx context.Context) error {
+			scheduler.Run(ctx)
+			return nil
+		},
+	})
+	lc.Append(Hook{
+		Name: "todo consumer",
+		Run:  todoAMQPConsumer.Register,
+	})
+	lc.Append(Hook{
+		Name: "todo rpc responder",
+		Run:  todoRPCResponder.Register,
+	})
+	lc.Append(Hook{
+		Name: "http server",
+		Run: func(ctx context.Context) error {
+			return httpServer.Run()
+		},
+		OnStop: httpServer.GracefulStop,
+	})
+	lc.Append(Hook{
+		Name: "readiness",
+		// Fail the readiness for the shutdown delay, so the load balancer stops sending requests before the http server stops
+		OnStop: func(ctx context.Context) error {
+			health.Shutdown()
+			select {
+			case <-time.After(healthConfig.ShutdownDelay):
+			case <-ctx.Done():
+			}
+			return nil
+		},
+		StopTimeout: healthConfig.ShutdownDelay + cfg.StopTimeout,
+	})
+
 	return &ServerImpl{
-		httpServer:       httpServer,
-		logger:           logger,
-		Tracing:          tracing,
-		AMQP:             amqp,
-		TodoAMQPConsumer: todoAMQPConsumer,
-		MongoDB:          mongoDB,
+		logger:    logger,
+		Health:    health,
+		Lifecycle: lc,
 	}
 }
 
-// Run server
-func (s *ServerImpl) Run() error {
-	go func() {
-		s.TodoAMQPConsumer.Register()
-	}()
+// Run - start the components and run them until ctx is done or one of them fails, then stop them.
+// The failure of the component is returned, else the failure of the stop.
+func (s *ServerImpl) Run(ctx context.Context) error {
+	err := s.Lifecycle.Start(ctx)
+	if err != nil {
+		return err
+	}
 
-	go func() {
-		err := s.httpServer.Run()
-		if err != nil {
-			s.logger.Error(err)
-		}
-	}()
+	select {
+	case <-ctx.Done():
+		s.logger.Info(context.Background(), "shutting down")
+	case <-s.Lifecycle.Done():
+		s.logger.Error(context.Background(), "component failed, shutting down", "error", s.Lifecycle.Err())
+	}
+
+	stopErr := s.GracefulStop(context.Background())
+	if err := s.Lifecycle.Err(); err != nil {
+		return err
+	}
+	if stopErr != nil {
+		return stopErr
+	}
+
+	s.logger.Info(context.Background(), "gracefully shutdowned")
 
 	return nil
 }
 
-// GracefulStop server
-func (s *ServerImpl) GracefulStop(ctx context.Context, done chan bool) {
-	err := s.httpServer.GracefulStop(ctx)
-	if err != nil {
-		s.logger.Error(err)
-	}
-
-	logrus.Info("gracefully shutdowned")
-	done <- true
+// GracefulStop - stop the components in the reverse order they started
+func (s *ServerImpl) GracefulStop(ctx context.Context) error {
+	return s.Lifecycle.Stop(ctx)
 }
@@ -0,0 +1,33 @@
+package tenant
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Key - name of the tenant in the amqp headers and the cloudevents extensions
+const Key = "tenantid"
+
+// Propagator - propagate the tenant of the context with the trace context, registered by the tracing package
+type Propagator struct{}
+
+func (Propagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	if id, ok := FromContext(ctx); ok {
+		carrier.Set(Key, id)
+	}
+}
+
+// Extract - ctx acting for the tenant of the carrier, an invalid tenant is ignored
+func (Propagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	id, err := Parse(carrier.Get(Key))
+	if err != nil {
+		return ctx
+	}
+
+	return WithTenant(ctx, id)
+}
+
+func (Propagator) Fields() []string {
+	return []string{Key}
+}
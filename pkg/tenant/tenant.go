@@ -0,0 +1,43 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrMissing - tenancy is enabled and the context has no tenant
+	ErrMissing = errors.New("missing tenant")
+	ErrInvalid = errors.New("invalid tenant")
+)
+
+// valid - the tenant ids are used in database names, headers and subdomains
+var valid = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+type tenantKey struct{}
+
+// Parse - tenant id of the value, the ids are lower case
+func Parse(value string) (string, error) {
+	id := strings.ToLower(strings.TrimSpace(value))
+	if id == "" {
+		return "", ErrMissing
+	}
+	if !valid.MatchString(id) {
+		return "", ErrInvalid
+	}
+
+	return id, nil
+}
+
+// WithTenant - ctx acting for the tenant
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext - tenant of ctx, false when it has none
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
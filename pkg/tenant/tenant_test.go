@@ -0,0 +1,50 @@
+package tenant_test
+
+import (
+	"context"
+	"testing"
+
+	"go-rengan/pkg/tenant"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("success with lower case id", func(t *testing.T) {
+		id, err := tenant.Parse(" Acme-1 ")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "acme-1", id)
+	})
+
+	t.Run("error when id is missing or invalid", func(t *testing.T) {
+		_, err := tenant.Parse("")
+		assert.ErrorIs(t, err, tenant.ErrMissing)
+
+		for _, value := range []string{"-acme", "acme.corp", "acme/../admin", "a234567890123456789012345678901234"} {
+			_, err = tenant.Parse(value)
+			assert.ErrorIs(t, err, tenant.ErrInvalid, value)
+		}
+	})
+}
+
+func TestPropagator(t *testing.T) {
+	t.Run("success when tenant is injected and extracted", func(t *testing.T) {
+		carrier := propagation.MapCarrier{}
+		tenant.Propagator{}.Inject(tenant.WithTenant(context.Background(), "acme"), carrier)
+
+		assert.Equal(t, "acme", carrier[tenant.Key])
+
+		id, ok := tenant.FromContext(tenant.Propagator{}.Extract(context.Background(), carrier))
+		assert.True(t, ok)
+		assert.Equal(t, "acme", id)
+	})
+
+	t.Run("success without tenant when carrier has an invalid one", func(t *testing.T) {
+		ctx := tenant.Propagator{}.Extract(context.Background(), propagation.MapCarrier{tenant.Key: "acme.corp"})
+
+		_, ok := tenant.FromContext(ctx)
+		assert.False(t, ok)
+	})
+}
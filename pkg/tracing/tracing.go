@@ -2,21 +2,41 @@ package tracing
 
 import (
 	"context"
-	"log"
-	"os"
-	"strconv"
+	"fmt"
+	"net/url"
+	"runtime/debug"
+	"strings"
+
+	config "go-rengan/pkg/config"
+	tenant "go-rengan/pkg/tenant"
 
 	"github.com/uptrace/uptrace-go/uptrace"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
 	trace_sdk "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	ExporterOTLP    = "otlp"
+	ExporterUptrace = "uptrace"
+	ExporterStdout  = "stdout"
+	ExporterNone    = "none"
+)
+
+// version - service version, set at build time with -ldflags "-X go-rengan/pkg/tracing.version=v1.2.3"
+var version string
+
 type Tracing interface {
 	GetTracerProvider() *trace_sdk.TracerProvider
-	ShutDown()
+	// ShutDown flushes the spans which are not exported yet until ctx is done
+	ShutDown(ctx context.Context) error
 	LogError(span trace.Span, err error)
 	Tracer(name string) trace.Tracer
 }
@@ -25,29 +45,97 @@ type TracingImpl struct {
 	tp *trace_sdk.TracerProvider
 }
 
-func tracerProvider(url string) (*trace_sdk.TracerProvider, error) {
-	appID, err := strconv.ParseInt(os.Getenv("APP_ID"), 10, 64)
-	if err != nil {
-		return nil, err
+// Version - service.version resource attribute, the version set at build time, else the module version
+// or the vcs revision of the build info
+func Version() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "(devel)"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
 	}
 
-	uptrace.ConfigureOpentelemetry(
-		uptrace.WithDSN(url),
-		uptrace.WithServiceName(os.Getenv("APP_NAME")),
-		uptrace.WithServiceVersion("v0.0.1"),
-		uptrace.WithDeploymentEnvironment(os.Getenv("ENV")),
-		uptrace.WithResourceAttributes(
-			attribute.Int64("ID", appID),
-		),
+	return revision
+}
+
+// Resource - attributes of the service, shared by the traces and the logs so they correlate
+func Resource(app config.AppConfig) *resource.Resource {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(app.Name),
+		semconv.ServiceVersionKey.String(Version()),
+		semconv.DeploymentEnvironmentKey.String(app.Env),
+		attribute.Int64("ID", app.ID),
+	}
+
+	res, err := resource.New(context.Background(),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(attrs...),
 	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	if res == nil {
+		res = resource.NewSchemaless(attrs...)
+	}
 
-	tp := uptrace.TracerProvider()
+	return res
+}
 
-	return tp, nil
+// Sampler - sampler of the config, the ratio applies to the traceidratio samplers
+func Sampler(cfg config.TracerConfig) (trace_sdk.Sampler, error) {
+	switch cfg.Sampler {
+	case "always_on":
+		return trace_sdk.AlwaysSample(), nil
+	case "always_off":
+		return trace_sdk.NeverSample(), nil
+	case "traceidratio":
+		return trace_sdk.TraceIDRatioBased(cfg.SamplerRatio), nil
+	case "parentbased_always_on", "":
+		return trace_sdk.ParentBased(trace_sdk.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return trace_sdk.ParentBased(trace_sdk.NeverSample()), nil
+	case "parentbased_traceidratio":
+		return trace_sdk.ParentBased(trace_sdk.TraceIDRatioBased(cfg.SamplerRatio)), nil
+	default:
+		return nil, fmt.Errorf("unknown sampler %q", cfg.Sampler)
+	}
 }
 
-func New() (Tracing, error) {
-	tp, err := tracerProvider(os.Getenv("TRACER_PROVIDER_URL"))
+// New - make tracing with the exporter and the sampler of the config, registered as the global tracer provider
+func New(app config.AppConfig, cfg config.TracerConfig, otlp config.OTLPConfig) (Tracing, error) {
+	sampler, err := Sampler(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tp, err := tracerProvider(app, cfg, otlp, sampler)
 	if err != nil {
 		return nil, err
 	}
@@ -55,21 +143,109 @@ func New() (Tracing, error) {
 	// Register our TracerProvider as the global so any imported
 	// instrumentation in the future will default to using it.
 	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetTextMapPropagator(propagator())
 
 	return &TracingImpl{
 		tp: tp,
 	}, nil
 }
 
+// NewNoop - make tracing which records the spans without exporting them, for tests.
+// The spans have valid ids, so the trace propagation works.
+func NewNoop() Tracing {
+	otel.SetTextMapPropagator(propagator())
+
+	return &TracingImpl{
+		tp: trace_sdk.NewTracerProvider(),
+	}
+}
+
+func tracerProvider(app config.AppConfig, cfg config.TracerConfig, otlp config.OTLPConfig, sampler trace_sdk.Sampler) (*trace_sdk.TracerProvider, error) {
+	res := Resource(app)
+
+	if cfg.Exporter == ExporterUptrace {
+		uptrace.ConfigureOpentelemetry(
+			uptrace.WithDSN(cfg.ProviderURL.Value()),
+			uptrace.WithResource(res),
+			uptrace.WithTraceSampler(sampler),
+			uptrace.WithMetricsDisabled(), // the metrics are exported by the metrics package
+		)
+
+		return uptrace.TracerProvider(), nil
+	}
+
+	opts := []trace_sdk.TracerProviderOption{
+		trace_sdk.WithResource(res),
+		trace_sdk.WithSampler(sampler),
+	}
+
+	exporter, err := spanExporter(cfg, otlp)
+	if err != nil {
+		return nil, err
+	}
+	if exporter != nil {
+		opts = append(opts, trace_sdk.WithBatcher(exporter))
+	}
+
+	return trace_sdk.NewTracerProvider(opts...), nil
+}
+
+// spanExporter - exporter of the config, nil for none
+func spanExporter(cfg config.TracerConfig, otlp config.OTLPConfig) (trace_sdk.SpanExporter, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		return otlpExporter(otlp)
+	case ExporterStdout:
+		return stdouttrace.New()
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown tracer exporter %q", cfg.Exporter)
+	}
+}
+
+// otlpExporter - grpc or http/protobuf exporter, the connection is established lazily
+func otlpExporter(cfg config.OTLPConfig) (trace_sdk.SpanExporter, error) {
+	endpoint, insecure := cfg.Endpoint, cfg.Insecure
+	if u, err := url.Parse(cfg.Endpoint); err == nil && u.Host != "" {
+		endpoint, insecure = u.Host, u.Scheme == "http"
+	}
+
+	switch cfg.Protocol {
+	case "http/protobuf":
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(endpoint),
+			otlptracehttp.WithHeaders(cfg.HeaderMap()),
+		}
+		if insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	case "grpc", "":
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(strings.TrimSuffix(endpoint, "/")),
+			otlptracegrpc.WithHeaders(cfg.HeaderMap()),
+		}
+		if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unknown otlp protocol %q", cfg.Protocol)
+	}
+}
+
+// propagator - trace context, baggage and tenant of the requests and messages
+func propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}, tenant.Propagator{})
+}
+
 func (t *TracingImpl) GetTracerProvider() *trace_sdk.TracerProvider {
 	return t.tp
 }
 
-func (t *TracingImpl) ShutDown() {
-	if err := t.tp.Shutdown(context.Background()); err != nil {
-		log.Printf("Error shutting down tracer provider: %v", err)
-	}
+func (t *TracingImpl) ShutDown(ctx context.Context) error {
+	return t.tp.Shutdown(ctx)
 }
 
 func (t *TracingImpl) LogError(span trace.Span, err error) {
@@ -80,5 +256,5 @@ func (t *TracingImpl) LogError(span trace.Span, err error) {
 }
 
 func (t *TracingImpl) Tracer(name string) trace.Tracer {
-	return otel.Tracer(name)
+	return t.tp.Tracer(name)
 }
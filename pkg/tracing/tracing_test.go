@@ -0,0 +1,96 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	config "go-rengan/pkg/config"
+	tracing "go-rengan/pkg/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	trace_sdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var appConfig = config.AppConfig{Env: "test", Name: "go-rengan"}
+
+func TestSampler(t *testing.T) {
+	t.Run("success parent based always on by default", func(t *testing.T) {
+		sampler, err := tracing.Sampler(config.TracerConfig{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, trace_sdk.ParentBased(trace_sdk.AlwaysSample()).Description(), sampler.Description())
+	})
+
+	t.Run("success ratio of the config", func(t *testing.T) {
+		sampler, err := tracing.Sampler(config.TracerConfig{Sampler: "parentbased_traceidratio", SamplerRatio: 0.25})
+
+		assert.NoError(t, err)
+		assert.Contains(t, sampler.Description(), "TraceIDRatioBased{0.25}")
+	})
+
+	t.Run("failed unknown sampler", func(t *testing.T) {
+		_, err := tracing.Sampler(config.TracerConfig{Sampler: "sometimes"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("success without exporter", func(t *testing.T) {
+		tr, err := tracing.New(appConfig, config.TracerConfig{Exporter: "none", Sampler: "always_off"}, config.OTLPConfig{})
+		assert.NoError(t, err)
+		defer tr.ShutDown(context.Background())
+
+		_, span := tr.Tracer("test").Start(context.Background(), "span")
+		defer span.End()
+
+		assert.False(t, span.SpanContext().IsSampled())
+	})
+
+	t.Run("error when spans are not flushed before shut down ctx is done", func(t *testing.T) {
+		release := make(chan struct{})
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer collector.Close()
+		defer close(release)
+
+		tr, err := tracing.New(appConfig, config.TracerConfig{Exporter: "otlp", Sampler: "always_on"}, config.OTLPConfig{Endpoint: collector.URL, Protocol: "http/protobuf"})
+		assert.NoError(t, err)
+		_, span := tr.Tracer("test").Start(context.Background(), "span")
+		span.End()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, tr.ShutDown(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("failed unknown exporter", func(t *testing.T) {
+		_, err := tracing.New(appConfig, config.TracerConfig{Exporter: "zipkin"}, config.OTLPConfig{})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestNoop(t *testing.T) {
+	t.Run("success propagate trace of the span", func(t *testing.T) {
+		tr := tracing.NewNoop()
+		ctx, span := tr.Tracer("test").Start(context.Background(), "span")
+		defer span.End()
+
+		header := http.Header{}
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+
+		assert.Contains(t, header.Get("traceparent"), span.SpanContext().TraceID().String())
+	})
+}
+
+func TestVersion(t *testing.T) {
+	assert.NotEmpty(t, tracing.Version())
+}
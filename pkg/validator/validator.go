@@ -47,6 +47,10 @@ func ValidatonError(err error) CommonError {
 			res.Errors[field] = fmt.Sprintf("%v must higher than %v character", field, v.Param())
 		case "email":
 			res.Errors[field] = fmt.Sprintf("%v is not a valid email address", v.Value())
+		case "oneof":
+			res.Errors[field] = fmt.Sprintf("%v must be one of %v", field, v.Param())
+		case "boolean":
+			res.Errors[field] = fmt.Sprintf("%v must be true or false", field)
 		case "username":
 			res.Errors[field] = fmt.Sprintf("%v is not a valid username", v.Value())
 		}
@@ -2,83 +2,172 @@ package amqpdelivery
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
 
-	pkgamqp "go-rengan/pkg/amqp"
+	broker "go-rengan/pkg/broker"
+	config "go-rengan/pkg/config"
+	"go-rengan/pkg/dedup"
 	logger "go-rengan/pkg/logger"
 	tracing "go-rengan/pkg/tracing"
+	"go-rengan/todo/events"
+	"go-rengan/todo/notification"
 
 	"go.opentelemetry.io/otel/trace"
 )
 
+var ErrMissingMessageID = errors.New("message id is empty")
+
 type AMQPConsumer interface {
-	Create()
-	Register()
+	Create(ctx context.Context) error
+	// Register consumes until ctx is done or the broker is closed
+	Register(ctx context.Context) error
+	// Running reports whether the consumer is subscribed
+	Running() bool
 }
 
 // AMQPConsumerImpl represent the amqp
 type AMQPConsumerImpl struct {
-	logger  logger.Logger
-	tracing tracing.Tracing
-	channel pkgamqp.AMQP
+	running      int32
+	email        string
+	concurrency  *broker.Concurrency
+	logger       logger.Logger
+	tracing      tracing.Tracing
+	subscriber   broker.Subscriber
+	deduplicator dedup.Deduplicator
+	notification notification.Notification
 }
 
-// New - make amqp consumer
+// New - make amqp consumer, it handles as many messages at the same time as the live consumer concurrency setting
 func New(
+	cfg config.NotificationConfig,
+	watcher config.Watcher,
 	logger logger.Logger,
 	tracing tracing.Tracing,
-	channel pkgamqp.AMQP,
+	subscriber broker.Subscriber,
+	deduplicator dedup.Deduplicator,
+	notification notification.Notification,
 ) AMQPConsumer {
+	concurrency := broker.NewConcurrency(watcher.Current().AMQP.ConsumerConcurrency)
+	watcher.Subscribe(func(cfg *config.Config) {
+		concurrency.SetLimit(cfg.AMQP.ConsumerConcurrency)
+	})
+
 	return &AMQPConsumerImpl{
-		logger:  logger,
-		tracing: tracing,
-		channel: channel,
+		email:        cfg.Email,
+		concurrency:  concurrency,
+		logger:       logger,
+		tracing:      tracing,
+		subscriber:   subscriber,
+		deduplicator: deduplicator,
+		notification: notification,
 	}
 }
 
-func (c *AMQPConsumerImpl) Register() {
-	c.Create()
+func (c *AMQPConsumerImpl) Register(ctx context.Context) error {
+	return c.Create(ctx)
+}
+
+func (c *AMQPConsumerImpl) Running() bool {
+	return atomic.LoadInt32(&c.running) == 1
 }
 
 // Create - create todo consumer
-func (c *AMQPConsumerImpl) Create() {
+func (c *AMQPConsumerImpl) Create(ctx context.Context) error {
 	messageName := "send_email"
 
-	channel := c.channel.Get()
-	q, err := channel.QueueDeclare(messageName, true, false, false, false, nil)
-	if err != nil {
-		c.logger.Error(err)
-	}
+	c.logger.Info(ctx, "consumer listening", "group", messageName)
+	atomic.StoreInt32(&c.running, 1)
+	defer atomic.StoreInt32(&c.running, 0)
+	err := c.subscriber.Subscribe(ctx, &broker.Subscription{
+		Topic:       events.Topic,
+		Group:       messageName,
+		Types:       []string{events.TodoCreated},
+		Concurrency: c.concurrency,
+	}, c.handle(messageName, func(ctx context.Context, msg *broker.Message) error {
+		event, err := decodeTodoEvent(msg)
+		if err != nil {
+			return err
+		}
+
+		err = c.notification.TodoCreated(ctx, c.email, event.Data)
+		if err != nil {
+			return err
+		}
+		c.logger.Info(ctx, "todo created email sent", "to", c.email, "todo_id", event.Data.ID.Hex())
 
-	msgs, err := channel.Consume(
-		q.Name,
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+		return nil
+	}))
 	if err != nil {
-		c.logger.Error(err)
+		return fmt.Errorf("consumer %s: %w", messageName, err)
 	}
-	c.logger.Println("Consumer listen to queue name", messageName)
 
-	for d := range msgs {
-		ctx := pkgamqp.ExtractAMQPHeaders(context.Background(), d.Headers)
+	return nil
+}
 
+// handle - run the handler at most once per message id.
+// The message id is only marked processed once the handler succeeded, a failed handler releases it
+// so the redelivered message gets another attempt, and the lease of a consumer which stopped meanwhile expires.
+func (c *AMQPConsumerImpl) handle(consumer string, handler broker.Handler) broker.Handler {
+	return func(ctx context.Context, msg *broker.Message) error {
 		tr := c.tracing.Tracer("amqp")
 		opts := []trace.SpanStartOption{
 			trace.WithSpanKind(trace.SpanKindConsumer),
 		}
-		_, span := tr.Start(ctx, "AMQP - consume - todo.create", opts...)
+		ctx, span := tr.Start(ctx, "AMQP - consume - "+msg.Type, opts...)
+		defer span.End()
+
+		if msg.ID == "" {
+			// Unprocessable message, redelivery would fail again
+			c.tracing.LogError(span, ErrMissingMessageID)
+			c.logger.Error(ctx, "message rejected", "consumer", consumer, "type", msg.Type, "error", ErrMissingMessageID)
+			return fmt.Errorf("%w: %v", broker.ErrReject, ErrMissingMessageID)
+		}
 
-		c.logger.Printf("Send email to: %s", d.Body)
+		claimed, err := c.deduplicator.Claim(ctx, consumer, msg.ID)
+		if err != nil {
+			c.tracing.LogError(span, err)
+			c.logger.Error(ctx, "message claim failed", "consumer", consumer, "message_id", msg.ID, "error", err)
+			return err
+		}
+
+		if !claimed {
+			c.logger.Info(ctx, "duplicate message skipped", "consumer", consumer, "message_id", msg.ID)
+			return nil
+		}
+
+		err = handler(ctx, msg)
+		if err != nil {
+			c.tracing.LogError(span, err)
+			c.logger.Error(ctx, "message handling failed", "consumer", consumer, "message_id", msg.ID, "error", err)
+
+			releaseErr := c.deduplicator.Release(ctx, consumer, msg.ID)
+			if releaseErr != nil {
+				c.logger.Error(ctx, "message release failed", "consumer", consumer, "message_id", msg.ID, "error", releaseErr)
+			}
 
-		err := d.Ack(false)
+			return err
+		}
+
+		err = c.deduplicator.Complete(ctx, consumer, msg.ID)
 		if err != nil {
-			c.logger.Error(err)
+			// The message is handled, it is acknowledged anyway
+			c.logger.Error(ctx, "message complete failed", "consumer", consumer, "message_id", msg.ID, "error", err)
 		}
 
-		span.End()
+		return nil
 	}
 }
+
+// decodeTodoEvent - decode todo event from the message data
+func decodeTodoEvent(msg *broker.Message) (*events.TodoEvent, error) {
+	event := &events.TodoEvent{}
+	err := json.Unmarshal(msg.Data, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
@@ -0,0 +1,258 @@
+package amqpdelivery_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	"go-rengan/pkg/amqp/memory"
+	pkgbroker "go-rengan/pkg/broker"
+	config "go-rengan/pkg/config"
+	logger "go-rengan/pkg/logger"
+	tracing "go-rengan/pkg/tracing"
+	amqpdelivery "go-rengan/todo/delivery/amqp"
+	"go-rengan/todo/models"
+	amqppublisher "go-rengan/todo/publisher"
+	errorsutil "go-rengan/utils/errors"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var appConfig = config.AppConfig{ID: 1, Name: "go-rengan"}
+var watcher = config.NewWatcher("", &config.Config{AMQP: config.AMQPConfig{ConsumerConcurrency: 1}}, logger.NewNop())
+
+// fakeDeduplicator - claims and completions in memory, the claims are never waited for
+type fakeDeduplicator struct {
+	mu        sync.Mutex
+	claimed   map[string]bool
+	completed map[string]bool
+}
+
+func newFakeDeduplicator() *fakeDeduplicator {
+	return &fakeDeduplicator{claimed: map[string]bool{}, completed: map[string]bool{}}
+}
+
+func (d *fakeDeduplicator) Claim(ctx context.Context, consumer string, messageID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.claimed[consumer+messageID] {
+		return false, nil
+	}
+	d.claimed[consumer+messageID] = true
+
+	return true, nil
+}
+
+func (d *fakeDeduplicator) Complete(ctx context.Context, consumer string, messageID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.completed[consumer+messageID] = true
+	return nil
+}
+
+func (d *fakeDeduplicator) Release(ctx context.Context, consumer string, messageID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.claimed, consumer+messageID)
+	return nil
+}
+
+func (d *fakeDeduplicator) completedCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.completed)
+}
+
+type sentEmail struct {
+	ctx   context.Context
+	to    string
+	value *models.Todo
+}
+
+type fakeNotification struct {
+	sent chan sentEmail
+	errs []error
+}
+
+func (n *fakeNotification) TodoCreated(ctx context.Context, to string, value *models.Todo) error {
+	n.sent <- sentEmail{ctx: ctx, to: to, value: value}
+	if len(n.errs) > 0 {
+		err := n.errs[0]
+		n.errs = n.errs[1:]
+		return err
+	}
+
+	return nil
+}
+
+func (n *fakeNotification) TodoReminder(ctx context.Context, to string, value *models.Todo) error {
+	return nil
+}
+
+func waitSent(t *testing.T, n *fakeNotification) sentEmail {
+	select {
+	case s := <-n.sent:
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("no email sent")
+		return sentEmail{}
+	}
+}
+
+func assertNoSent(t *testing.T, n *fakeNotification) {
+	select {
+	case s := <-n.sent:
+		t.Fatalf("unexpected email sent for todo %s", s.value.ID.Hex())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// startConsumer - run the consumers against a memory broker until the test ends
+func startConsumer(t *testing.T, notification *fakeNotification) (tracing.Tracing, pkgbroker.Broker, *memory.Broker) {
+	return startConsumerWith(t, notification, newFakeDeduplicator())
+}
+
+func startConsumerWith(t *testing.T, notification *fakeNotification, deduplicator *fakeDeduplicator) (tracing.Tracing, pkgbroker.Broker, *memory.Broker) {
+	tracing := tracing.NewNoop()
+
+	broker := memory.New()
+	channel := pkgbroker.NewAMQP(logger.NewNop(), pkgamqp.NewWithChannel(broker))
+
+	consumer := amqpdelivery.New(config.NotificationConfig{Email: "user@example.com"}, watcher, logger.NewNop(), tracing, channel, deduplicator, notification)
+
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(t, consumer.Register(context.Background()))
+		close(done)
+	}()
+	t.Cleanup(func() {
+		broker.Close()
+		<-done
+	})
+
+	// Wait until the consumer declared its queue
+	for i := 0; i < 100; i++ {
+		q, _ := broker.QueueDeclare("send_email", true, false, false, false, nil)
+		if q.Consumers > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return tracing, channel, broker
+}
+
+func TestCreate(t *testing.T) {
+	t.Run("success when publish and consume todo created", func(t *testing.T) {
+		notification := &fakeNotification{sent: make(chan sentEmail, 10)}
+		tracing, channel, _ := startConsumer(t, notification)
+
+		publisher := amqppublisher.New(appConfig, logger.NewNop(), tracing, channel)
+
+		ctx, span := tracing.Tracer("test").Start(context.Background(), "test")
+		defer span.End()
+
+		todo := &models.Todo{ID: primitive.NewObjectID(), Title: "Buy milk"}
+		err := publisher.Created(ctx, todo)
+		assert.NoError(t, err)
+
+		sent := waitSent(t, notification)
+		assert.Equal(t, "user@example.com", sent.to)
+		assert.Equal(t, todo.ID, sent.value.ID)
+		assert.Equal(t, "Buy milk", sent.value.Title)
+
+		// Consumer span is a child of the publisher trace
+		assert.Equal(t, span.SpanContext().TraceID(), trace.SpanContextFromContext(sent.ctx).TraceID())
+	})
+
+	t.Run("success when skip other events", func(t *testing.T) {
+		notification := &fakeNotification{sent: make(chan sentEmail, 10)}
+		tracing, channel, _ := startConsumer(t, notification)
+
+		publisher := amqppublisher.New(appConfig, logger.NewNop(), tracing, channel)
+
+		err := publisher.Deleted(context.Background(), &models.Todo{ID: primitive.NewObjectID()})
+		assert.NoError(t, err)
+
+		assertNoSent(t, notification)
+	})
+
+	t.Run("success when duplicate message is processed once", func(t *testing.T) {
+		notification := &fakeNotification{sent: make(chan sentEmail, 10)}
+		_, channel, broker := startConsumer(t, notification)
+
+		// Capture a published message and deliver it twice
+		_, err := broker.QueueDeclare("capture", true, false, false, false, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, broker.QueueBind("capture", "todo.created", "todo.events", false, nil))
+
+		tracing := tracing.NewNoop()
+		publisher := amqppublisher.New(appConfig, logger.NewNop(), tracing, channel)
+		assert.NoError(t, publisher.Created(context.Background(), &models.Todo{ID: primitive.NewObjectID()}))
+		waitSent(t, notification)
+
+		deliveries, err := broker.Consume("capture", "", true, false, false, false, nil)
+		assert.NoError(t, err)
+		d := <-deliveries
+
+		err = broker.Publish("todo.events", "todo.created", false, false, amqp.Publishing{
+			Headers:     d.Headers,
+			ContentType: d.ContentType,
+			MessageId:   d.MessageId,
+			Body:        d.Body,
+		})
+		assert.NoError(t, err)
+
+		assertNoSent(t, notification)
+		assert.Equal(t, 0, broker.QueueLength("send_email"))
+	})
+
+	t.Run("success when failed delivery is redelivered", func(t *testing.T) {
+		notification := &fakeNotification{sent: make(chan sentEmail, 10), errs: []error{errorsutil.ErrDefault}}
+		tracing, channel, _ := startConsumer(t, notification)
+
+		publisher := amqppublisher.New(appConfig, logger.NewNop(), tracing, channel)
+		assert.NoError(t, publisher.Created(context.Background(), &models.Todo{ID: primitive.NewObjectID()}))
+
+		first := waitSent(t, notification)
+		second := waitSent(t, notification)
+		assert.Equal(t, first.value.ID, second.value.ID)
+	})
+
+	t.Run("success when message is completed once handled", func(t *testing.T) {
+		notification := &fakeNotification{sent: make(chan sentEmail, 10)}
+		deduplicator := newFakeDeduplicator()
+		tracing, channel, _ := startConsumerWith(t, notification, deduplicator)
+
+		publisher := amqppublisher.New(appConfig, logger.NewNop(), tracing, channel)
+		assert.NoError(t, publisher.Created(context.Background(), &models.Todo{ID: primitive.NewObjectID()}))
+
+		waitSent(t, notification)
+		assert.Eventually(t, func() bool { return deduplicator.completedCount() == 1 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("error when failed message is not completed", func(t *testing.T) {
+		notification := &fakeNotification{sent: make(chan sentEmail, 10), errs: []error{errorsutil.ErrDefault, errorsutil.ErrDefault}}
+		deduplicator := newFakeDeduplicator()
+		tracing, channel, broker := startConsumerWith(t, notification, deduplicator)
+
+		publisher := amqppublisher.New(appConfig, logger.NewNop(), tracing, channel)
+		assert.NoError(t, publisher.Created(context.Background(), &models.Todo{ID: primitive.NewObjectID()}))
+
+		// Both attempts fail, the message is dropped without being marked processed
+		waitSent(t, notification)
+		waitSent(t, notification)
+		assertNoSent(t, notification)
+		assert.Equal(t, 0, deduplicator.completedCount())
+		assert.Equal(t, 0, broker.QueueLength("send_email"))
+	})
+}
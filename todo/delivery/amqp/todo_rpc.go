@@ -0,0 +1,325 @@
+package amqpdelivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	auth "go-rengan/pkg/auth"
+	logger "go-rengan/pkg/logger"
+	tenant "go-rengan/pkg/tenant"
+	tracing "go-rengan/pkg/tracing"
+	validator "go-rengan/pkg/validator"
+	"go-rengan/todo/models"
+	"go-rengan/todo/service"
+	errorsutil "go-rengan/utils/errors"
+	paginationutil "go-rengan/utils/pagination"
+	responseutil "go-rengan/utils/response"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Request queues of the todo rpc
+const (
+	RPCTodoGet    = "todo.get"
+	RPCTodoList   = "todo.list"
+	RPCTodoCreate = "todo.create"
+)
+
+// HeaderUserID - id of the user the rpc request acts for, the services which call the rpc queues are trusted to set it
+const HeaderUserID = "x-user-id"
+
+// RPCReply - reply of the todo rpc, same envelope as the http responses
+type RPCReply struct {
+	Success bool                   `json:"success"`
+	Code    int                    `json:"code"`
+	Message string                 `json:"message,omitempty"`
+	Errors  map[string]interface{} `json:"errors,omitempty"`
+	Data    interface{}            `json:"data,omitempty"`
+	Meta    *responseutil.Meta     `json:"meta,omitempty"`
+}
+
+type RPCResponder interface {
+	// Register serves the rpc queues until ctx is done or the channel is closed
+	Register(ctx context.Context) error
+	// Running reports whether every rpc queue is consumed
+	Running() bool
+	Get(ctx context.Context, body []byte) *RPCReply
+	List(ctx context.Context, body []byte) *RPCReply
+	Create(ctx context.Context, body []byte) *RPCReply
+}
+
+// RPCResponderImpl represent the todo rpc responder, it consumes the request queues on the amqp channel
+// and replies to the reply_to queue of each request, which broker.Subscriber has no notion of
+type RPCResponderImpl struct {
+	serving     int32
+	logger      logger.Logger
+	tracing     tracing.Tracing
+	channel     pkgamqp.AMQP
+	todoService service.Service
+}
+
+// NewRPCResponder - make todo rpc responder
+func NewRPCResponder(
+	logger logger.Logger,
+	tracing tracing.Tracing,
+	channel pkgamqp.AMQP,
+	service service.Service,
+) RPCResponder {
+	return &RPCResponderImpl{
+		logger:      logger,
+		tracing:     tracing,
+		channel:     channel,
+		todoService: service,
+	}
+}
+
+// Register - serve every todo rpc until ctx is done or the channel is closed, the error of the first queue which failed
+func (r *RPCResponderImpl) Register(ctx context.Context) error {
+	handlers := r.handlers()
+	errs := make(chan error, len(handlers))
+
+	wg := sync.WaitGroup{}
+	for queue, handler := range handlers {
+		wg.Add(1)
+		go func(queue string, handler func(ctx context.Context, body []byte) *RPCReply) {
+			defer wg.Done()
+			errs <- r.serve(ctx, queue, handler)
+		}(queue, handler)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *RPCResponderImpl) Running() bool {
+	return int(atomic.LoadInt32(&r.serving)) == len(r.handlers())
+}
+
+// handlers - handler of each rpc queue
+func (r *RPCResponderImpl) handlers() map[string]func(ctx context.Context, body []byte) *RPCReply {
+	return map[string]func(ctx context.Context, body []byte) *RPCReply{
+		RPCTodoGet:    r.Get,
+		RPCTodoList:   r.List,
+		RPCTodoCreate: r.Create,
+	}
+}
+
+// Get - todo.get rpc
+func (r *RPCResponderImpl) Get(ctx context.Context, body []byte) *RPCReply {
+	request := &models.TodoGetRPCRequest{}
+	if err := json.Unmarshal(body, request); err != nil {
+		return errorBody()
+	}
+
+	result, err := r.todoService.GetByID(ctx, request.ID)
+	if err != nil {
+		return errorService(err)
+	}
+
+	return &RPCReply{
+		Success: true,
+		Code:    http.StatusOK,
+		Data:    result,
+	}
+}
+
+// List - todo.list rpc
+func (r *RPCResponderImpl) List(ctx context.Context, body []byte) *RPCReply {
+	request := &models.TodoListRPCRequest{}
+	if err := json.Unmarshal(body, request); err != nil {
+		return errorBody()
+	}
+
+	if err := validator.ValidateStruct(request); err != nil {
+		return errorValidation(err)
+	}
+
+	currentPage := paginationutil.CurrentPage(request.Page)
+	perPage := paginationutil.PerPage(request.PerPage)
+	if perPage > 100 {
+		perPage = 100
+	}
+	offset := paginationutil.Offset(currentPage, perPage)
+
+	results, totalData, err := r.todoService.GetAll(ctx, request.Keywords, request.SharedWithMe, perPage, offset)
+	if err != nil {
+		return errorService(err)
+	}
+
+	return &RPCReply{
+		Success: true,
+		Code:    http.StatusOK,
+		Data:    results,
+		Meta: &responseutil.Meta{
+			PerPage:     perPage,
+			CurrentPage: currentPage,
+			TotalPage:   paginationutil.TotalPage(totalData, perPage),
+			TotalData:   totalData,
+		},
+	}
+}
+
+// Create - todo.create rpc
+func (r *RPCResponderImpl) Create(ctx context.Context, body []byte) *RPCReply {
+	request := &models.TodoRequest{}
+	if err := json.Unmarshal(body, request); err != nil {
+		return errorBody()
+	}
+
+	if err := validator.ValidateStruct(request); err != nil {
+		return errorValidation(err)
+	}
+
+	result, err := r.todoService.Create(ctx, &models.Todo{
+		Title:       request.Title,
+		Description: request.Description,
+	})
+	if err != nil {
+		return errorService(err)
+	}
+
+	return &RPCReply{
+		Success: true,
+		Code:    http.StatusCreated,
+		Data:    result,
+	}
+}
+
+// serve - consume the request queue and publish each reply to its reply_to queue
+func (r *RPCResponderImpl) serve(ctx context.Context, queue string, handler func(ctx context.Context, body []byte) *RPCReply) error {
+	channel := r.channel.Get()
+	q, err := channel.QueueDeclare(queue, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("rpc queue %s declare: %w", queue, err)
+	}
+
+	consumerTag := uuid.NewString()
+	msgs, err := channel.Consume(q.Name, consumerTag, false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("rpc queue %s consume: %w", queue, err)
+	}
+	r.logger.Info(ctx, "rpc responder listening", "queue", queue)
+	atomic.AddInt32(&r.serving, 1)
+	defer atomic.AddInt32(&r.serving, -1)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return channel.Cancel(consumerTag, false)
+		case d, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+			r.reply(queue, d, handler)
+		}
+	}
+}
+
+func (r *RPCResponderImpl) reply(queue string, d amqp.Delivery, handler func(ctx context.Context, body []byte) *RPCReply) {
+	ctx := pkgamqp.ExtractAMQPHeaders(context.Background(), d.Headers)
+	if userID, ok := d.Headers[HeaderUserID].(string); ok && userID != "" {
+		ctx = auth.WithPrincipal(ctx, &auth.Principal{Subject: userID})
+	}
+
+	tr := r.tracing.Tracer("amqp")
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindServer),
+	}
+	ctx, span := tr.Start(ctx, "AMQP - rpc - "+queue, opts...)
+	defer span.End()
+
+	defer func() {
+		err := d.Ack(false)
+		if err != nil {
+			r.logger.Error(ctx, "rpc ack failed", "queue", queue, "error", err)
+		}
+	}()
+
+	if d.ReplyTo == "" {
+		r.logger.Warn(ctx, "rpc request without reply_to dropped", "queue", queue)
+		return
+	}
+
+	body, err := json.Marshal(handler(ctx, d.Body))
+	if err != nil {
+		r.tracing.LogError(span, err)
+		r.logger.Error(ctx, "rpc reply encode failed", "queue", queue, "error", err)
+		return
+	}
+
+	err = r.channel.Get().Publish("", d.ReplyTo, false, false, amqp.Publishing{
+		Headers:       pkgamqp.InjectAMQPHeaders(ctx),
+		ContentType:   "application/json",
+		CorrelationId: d.CorrelationId,
+		Body:          body,
+	})
+	if err != nil {
+		r.tracing.LogError(span, err)
+		r.logger.Error(ctx, "rpc reply publish failed", "queue", queue, "reply_to", d.ReplyTo, "error", err)
+	}
+}
+
+func errorBody() *RPCReply {
+	return &RPCReply{
+		Success: false,
+		Code:    http.StatusBadRequest,
+		Message: "Validation errors in your request",
+		Errors:  map[string]interface{}{"body": "Check your body request"},
+	}
+}
+
+func errorValidation(err error) *RPCReply {
+	return &RPCReply{
+		Success: false,
+		Code:    http.StatusBadRequest,
+		Message: "Validation errors in your request",
+		Errors:  validator.ValidatonError(err).Errors,
+	}
+}
+
+func errorService(err error) *RPCReply {
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		return &RPCReply{
+			Success: false,
+			Code:    http.StatusUnauthorized,
+			Message: "Missing " + HeaderUserID + " header",
+		}
+	}
+
+	if errors.Is(err, tenant.ErrMissing) {
+		return &RPCReply{
+			Success: false,
+			Code:    http.StatusBadRequest,
+			Message: "Missing " + tenant.Key + " header",
+		}
+	}
+
+	if err.Error() == errorsutil.ErrNotFound.Error() {
+		return &RPCReply{
+			Success: false,
+			Code:    http.StatusNotFound,
+			Message: "Item not found",
+		}
+	}
+
+	return &RPCReply{
+		Success: false,
+		Code:    http.StatusInternalServerError,
+		Message: "There is something error",
+	}
+}
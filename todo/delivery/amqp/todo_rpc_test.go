@@ -0,0 +1,191 @@
+package amqpdelivery_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	pkgamqp "go-rengan/pkg/amqp"
+	"go-rengan/pkg/amqp/memory"
+	auth "go-rengan/pkg/auth"
+	logger "go-rengan/pkg/logger"
+	tenant "go-rengan/pkg/tenant"
+	tracing "go-rengan/pkg/tracing"
+	validator "go-rengan/pkg/validator"
+	amqpdelivery "go-rengan/todo/delivery/amqp"
+	mockservice "go-rengan/todo/mocks/service"
+	"go-rengan/todo/models"
+	errorsutil "go-rengan/utils/errors"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var WhenError400Validation string = "when reply 400 bad request (error validation)"
+var WhenError404NotFound string = "when reply 404 not found (resouce not found)"
+var WhenError500Service string = "when reply 500 internal error (error service)"
+var WhenSuccess201Created string = "when reply 201 created"
+var WhenSuccess200OK string = "when reply 200 ok"
+
+// startResponder - serve the todo rpc against a memory broker until the test ends
+func startResponder(t *testing.T, service *mockservice.Service) (tracing.Tracing, pkgamqp.RPCClient) {
+	validator.New()
+
+	tracing := tracing.NewNoop()
+
+	broker := memory.New()
+	channel := pkgamqp.NewWithChannel(broker)
+
+	responder := amqpdelivery.NewRPCResponder(logger.NewNop(), tracing, channel, service)
+
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(t, responder.Register(context.Background()))
+		close(done)
+	}()
+	t.Cleanup(func() {
+		broker.Close()
+		<-done
+	})
+
+	// Wait until the responder declared its queues
+	for _, queue := range []string{amqpdelivery.RPCTodoGet, amqpdelivery.RPCTodoList, amqpdelivery.RPCTodoCreate} {
+		for i := 0; i < 100; i++ {
+			q, _ := broker.QueueDeclare(queue, true, false, false, false, nil)
+			if q.Consumers > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	return tracing, pkgamqp.NewRPCClient(tracing, channel, time.Second)
+}
+
+func call(t *testing.T, client pkgamqp.RPCClient, ctx context.Context, queue string, request interface{}) map[string]interface{} {
+	body, err := json.Marshal(request)
+	assert.NoError(t, err)
+
+	d, err := client.Call(ctx, queue, amqp.Publishing{
+		Headers:     amqp.Table{amqpdelivery.HeaderUserID: "user-1"},
+		ContentType: "application/json",
+		Body:        body,
+	})
+	assert.NoError(t, err)
+
+	reply := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(d.Body, &reply))
+
+	return reply
+}
+
+func TestRPCGet(t *testing.T) {
+	t.Run(WhenSuccess200OK, func(t *testing.T) {
+		service := new(mockservice.Service)
+		tracing, client := startResponder(t, service)
+
+		ctx, span := tracing.Tracer("test").Start(tenant.WithTenant(context.Background(), "acme"), "test")
+		defer span.End()
+
+		service.On("GetByID", mock.MatchedBy(func(ctx context.Context) bool {
+			// Trace context and tenant propagate from the client to the service, the service acts for the user of the header
+			principal, ok := auth.FromContext(ctx)
+			tenantID, _ := tenant.FromContext(ctx)
+			return trace.SpanContextFromContext(ctx).TraceID() == span.SpanContext().TraceID() && ok && principal.Subject == "user-1" && tenantID == "acme"
+		}), "1").Return(&models.Todo{Title: "Buy milk"}, nil)
+
+		reply := call(t, client, ctx, amqpdelivery.RPCTodoGet, &models.TodoGetRPCRequest{ID: "1"})
+
+		assert.Equal(t, true, reply["success"])
+		assert.Equal(t, float64(http.StatusOK), reply["code"])
+		assert.Equal(t, "Buy milk", reply["data"].(map[string]interface{})["title"])
+		service.AssertExpectations(t)
+	})
+
+	t.Run(WhenError404NotFound, func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("GetByID", mock.Anything, "1").Return(nil, errorsutil.ErrNotFound)
+		_, client := startResponder(t, service)
+
+		reply := call(t, client, context.Background(), amqpdelivery.RPCTodoGet, &models.TodoGetRPCRequest{ID: "1"})
+
+		assert.Equal(t, false, reply["success"])
+		assert.Equal(t, float64(http.StatusNotFound), reply["code"])
+	})
+
+	t.Run("when reply 401 unauthorized (no user)", func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("GetByID", mock.Anything, "1").Return(nil, auth.ErrUnauthenticated)
+		_, client := startResponder(t, service)
+
+		d, err := client.Call(context.Background(), amqpdelivery.RPCTodoGet, amqp.Publishing{Body: []byte(`{"id": "1"}`)})
+		assert.NoError(t, err)
+
+		reply := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(d.Body, &reply))
+		assert.Equal(t, float64(http.StatusUnauthorized), reply["code"])
+	})
+
+	t.Run("when reply 400 bad request (no tenant)", func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("GetByID", mock.Anything, "1").Return(nil, tenant.ErrMissing)
+		_, client := startResponder(t, service)
+
+		reply := call(t, client, context.Background(), amqpdelivery.RPCTodoGet, &models.TodoGetRPCRequest{ID: "1"})
+
+		assert.Equal(t, float64(http.StatusBadRequest), reply["code"])
+		assert.Equal(t, "Missing tenantid header", reply["message"])
+	})
+}
+
+func TestRPCList(t *testing.T) {
+	t.Run(WhenSuccess200OK, func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("GetAll", mock.Anything, "milk", false, 100, 100).Return([]*models.Todo{{Title: "Buy milk"}}, 101, nil)
+		_, client := startResponder(t, service)
+
+		reply := call(t, client, context.Background(), amqpdelivery.RPCTodoList, &models.TodoListRPCRequest{Keywords: "milk", Page: 2, PerPage: 1000})
+
+		assert.Equal(t, float64(http.StatusOK), reply["code"])
+		assert.Len(t, reply["data"], 1)
+		assert.Equal(t, float64(2), reply["meta"].(map[string]interface{})["page_count"])
+	})
+
+	t.Run(WhenError500Service, func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("GetAll", mock.Anything, "", false, 10, 0).Return(nil, 0, errorsutil.ErrDefault)
+		_, client := startResponder(t, service)
+
+		reply := call(t, client, context.Background(), amqpdelivery.RPCTodoList, &models.TodoListRPCRequest{})
+
+		assert.Equal(t, float64(http.StatusInternalServerError), reply["code"])
+	})
+}
+
+func TestRPCCreate(t *testing.T) {
+	t.Run(WhenSuccess201Created, func(t *testing.T) {
+		service := new(mockservice.Service)
+		service.On("Create", mock.Anything, &models.Todo{Title: "Buy milk", Description: "2 bottles"}).Return(&models.Todo{Title: "Buy milk"}, nil)
+		_, client := startResponder(t, service)
+
+		reply := call(t, client, context.Background(), amqpdelivery.RPCTodoCreate, &models.TodoRequest{Title: "Buy milk", Description: "2 bottles"})
+
+		assert.Equal(t, float64(http.StatusCreated), reply["code"])
+		service.AssertExpectations(t)
+	})
+
+	t.Run(WhenError400Validation, func(t *testing.T) {
+		service := new(mockservice.Service)
+		_, client := startResponder(t, service)
+
+		reply := call(t, client, context.Background(), amqpdelivery.RPCTodoCreate, &models.TodoRequest{})
+
+		assert.Equal(t, float64(http.StatusBadRequest), reply["code"])
+		assert.Equal(t, "title is required", reply["errors"].(map[string]interface{})["title"])
+		service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
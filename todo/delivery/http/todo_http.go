@@ -4,8 +4,11 @@ import (
 	"net/http"
 	"strconv"
 
+	auth "go-rengan/pkg/auth"
+	idempotency "go-rengan/pkg/idempotency"
 	tracing "go-rengan/pkg/tracing"
 	validator "go-rengan/pkg/validator"
+	"go-rengan/todo/events"
 	"go-rengan/todo/models"
 	"go-rengan/todo/service"
 	errorsutil "go-rengan/utils/errors"
@@ -17,33 +20,52 @@ import (
 )
 
 type HTTPHandler interface {
-	RegisterRoutes(router *chi.Mux)
+	RegisterRoutes(router chi.Router)
 	GetAll(w http.ResponseWriter, r *http.Request)
 	GetByID(w http.ResponseWriter, r *http.Request)
 	Create(w http.ResponseWriter, r *http.Request)
 	Update(w http.ResponseWriter, r *http.Request)
 	Delete(w http.ResponseWriter, r *http.Request)
+	Collaborators(w http.ResponseWriter, r *http.Request)
+	SetCollaborator(w http.ResponseWriter, r *http.Request)
+	RemoveCollaborator(w http.ResponseWriter, r *http.Request)
 }
 
 type HTTPHandlerImpl struct {
 	tracing     tracing.Tracing
 	todoService service.Service
+	idempotency idempotency.Idempotency
 }
 
 // New - make http handler
-func New(tracing tracing.Tracing, service service.Service) HTTPHandler {
+func New(tracing tracing.Tracing, service service.Service, idempotency idempotency.Idempotency) HTTPHandler {
 	return &HTTPHandlerImpl{
 		tracing:     tracing,
 		todoService: service,
+		idempotency: idempotency,
 	}
 }
 
-func (handler *HTTPHandlerImpl) RegisterRoutes(router *chi.Mux) {
-	router.Get("/todo", handler.GetAll)
-	router.Get("/todo/{id}", handler.GetByID)
-	router.Post("/todo", handler.Create)
-	router.Put("/todo/{id}", handler.Update)
-	router.Delete("/todo/{id}", handler.Delete)
+func (handler *HTTPHandlerImpl) RegisterRoutes(router chi.Router) {
+	read := router.With(auth.RequireScope(models.ScopeRead))
+	write := router.With(auth.RequireScope(models.ScopeWrite))
+	// The retries of the writes which are not idempotent by themselves replay the first response
+	idempotent := write.With(handler.idempotency.Middleware)
+
+	read.Get("/todo", handler.GetAll)
+	read.Get("/todo/{id}", handler.GetByID)
+	idempotent.Post("/todo", handler.Create)
+	idempotent.Put("/todo/{id}", handler.Update)
+	idempotent.Delete("/todo/{id}", handler.Delete)
+	read.Get("/todo/{id}/collaborators", handler.Collaborators)
+	write.Put("/todo/{id}/collaborators/{userId}", handler.SetCollaborator)
+	write.Delete("/todo/{id}/collaborators/{userId}", handler.RemoveCollaborator)
+}
+
+// RegisterPublicRoutes - serve the json schemas of the todo events at their dataschema uri
+func (handler *HTTPHandlerImpl) RegisterPublicRoutes(router chi.Router) {
+	// The embedded files are named schemas/<type>/v<version>.json, like the path of the dataschema uri
+	router.Handle("/schemas/*", http.FileServer(http.FS(events.Schemas)))
 }
 
 // GetAll - get all todo http handler
@@ -54,13 +76,15 @@ func (h *HTTPHandlerImpl) GetAll(w http.ResponseWriter, r *http.Request) {
 	qQuery := r.URL.Query().Get("q")
 	pageQueryStr := r.URL.Query().Get("page")
 	perPageQueryStr := r.URL.Query().Get("per_page")
+	sharedWithMeQueryStr := r.URL.Query().Get("shared_with_me")
 
 	err := validator.ValidateStruct(&models.TodoListRequest{
 		Keywords: &models.SearchForm{
 			Keywords: qQuery,
 		},
-		Page:    pageQueryStr,
-		PerPage: perPageQueryStr,
+		Page:         pageQueryStr,
+		PerPage:      perPageQueryStr,
+		SharedWithMe: sharedWithMeQueryStr,
 	})
 	if err != nil {
 		h.tracing.LogError(span, err)
@@ -85,7 +109,9 @@ func (h *HTTPHandlerImpl) GetAll(w http.ResponseWriter, r *http.Request) {
 	perPage := paginationutil.PerPage(perPageQuery)
 	offset := paginationutil.Offset(currentPage, perPage)
 
-	results, totalData, err := h.todoService.GetAll(ctx, qQuery, perPage, offset)
+	sharedWithMe, _ := strconv.ParseBool(sharedWithMeQueryStr)
+
+	results, totalData, err := h.todoService.GetAll(ctx, qQuery, sharedWithMe, perPage, offset)
 	if err != nil {
 		h.tracing.LogError(span, err)
 
@@ -202,6 +228,11 @@ func (h *HTTPHandlerImpl) Update(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if err.Error() == errorsutil.ErrForbidden.Error() {
+			responseutil.Forbidden(w, r, "You are not allowed to change this item")
+			return
+		}
+
 		responseutil.ErrorInternal(w, r, err)
 		return
 	}
@@ -231,6 +262,11 @@ func (h *HTTPHandlerImpl) Delete(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if err.Error() == errorsutil.ErrForbidden.Error() {
+			responseutil.Forbidden(w, r, "You are not allowed to change this item")
+			return
+		}
+
 		responseutil.ErrorInternal(w, r, err)
 		return
 	}
@@ -241,3 +277,110 @@ func (h *HTTPHandlerImpl) Delete(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 }
+
+// Collaborators - collaborators of todo by id http handler
+func (h *HTTPHandlerImpl) Collaborators(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.tracing.GetTracerProvider().Tracer("todoHandler").Start(r.Context(), "todoHandler.Collaborators")
+	defer span.End()
+
+	// Get and filter id param
+	id := chi.URLParam(r, "id")
+
+	results, err := h.todoService.Collaborators(ctx, id)
+	if err != nil {
+		h.tracing.LogError(span, err)
+
+		if err.Error() == errorsutil.ErrNotFound.Error() {
+			responseutil.NotFound(w, r, "Item not found")
+			return
+		}
+
+		responseutil.ErrorInternal(w, r, err)
+		return
+	}
+
+	responseutil.ResponseOK(w, r, &responseutil.Success{
+		Data: results,
+	})
+}
+
+// SetCollaborator - share todo by id with a user http handler
+func (h *HTTPHandlerImpl) SetCollaborator(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.tracing.GetTracerProvider().Tracer("todoHandler").Start(r.Context(), "todoHandler.SetCollaborator")
+	defer span.End()
+
+	// Get and filter id params
+	id := chi.URLParam(r, "id")
+	userID := chi.URLParam(r, "userId")
+
+	data := &models.CollaboratorRequest{}
+	if err := render.Bind(r, data); err != nil {
+		h.tracing.LogError(span, err)
+
+		if err.Error() == errorsutil.ErrEOF.Error() {
+			responseutil.ErrorBody(w, r, err)
+			return
+		}
+
+		responseutil.ErrorValidation(w, r, err)
+		return
+	}
+
+	collaborator := models.Collaborator{UserID: userID, Role: data.Role}
+	err := h.todoService.SetCollaborator(ctx, id, collaborator)
+	if err != nil {
+		h.tracing.LogError(span, err)
+
+		if err.Error() == errorsutil.ErrNotFound.Error() {
+			responseutil.NotFound(w, r, "Item not found")
+			return
+		}
+
+		if err.Error() == errorsutil.ErrForbidden.Error() {
+			responseutil.Forbidden(w, r, "Only the owner shares this item")
+			return
+		}
+
+		responseutil.ErrorInternal(w, r, err)
+		return
+	}
+
+	responseutil.ResponseOK(w, r, &responseutil.Success{
+		Data: collaborator,
+	})
+}
+
+// RemoveCollaborator - stop sharing todo by id with a user http handler
+func (h *HTTPHandlerImpl) RemoveCollaborator(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.tracing.GetTracerProvider().Tracer("todoHandler").Start(r.Context(), "todoHandler.RemoveCollaborator")
+	defer span.End()
+
+	// Get and filter id params
+	id := chi.URLParam(r, "id")
+	userID := chi.URLParam(r, "userId")
+
+	err := h.todoService.RemoveCollaborator(ctx, id, userID)
+	if err != nil {
+		h.tracing.LogError(span, err)
+
+		if err.Error() == errorsutil.ErrNotFound.Error() {
+			responseutil.NotFound(w, r, "Item not found")
+			return
+		}
+
+		if err.Error() == errorsutil.ErrForbidden.Error() {
+			responseutil.Forbidden(w, r, "Only the owner shares this item")
+			return
+		}
+
+		responseutil.ErrorInternal(w, r, err)
+		return
+	}
+
+	responseutil.ResponseOK(w, r, &responseutil.Success{
+		Data: responseutil.H{
+			"id":      id,
+			"user_id": userID,
+		},
+	})
+}
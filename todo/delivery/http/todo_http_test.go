@@ -5,14 +5,19 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+	idempotency "go-rengan/pkg/idempotency"
+	schema "go-rengan/pkg/schema"
+	httpserver "go-rengan/pkg/server/http"
 	tracing "go-rengan/pkg/tracing"
 	validator "go-rengan/pkg/validator"
 	errorsutil "go-rengan/utils/errors"
 
 	httpdelivery "go-rengan/todo/delivery/http"
+	"go-rengan/todo/events"
 	mockservice "go-rengan/todo/mocks/service"
 
 	"go-rengan/todo/models"
@@ -22,6 +27,8 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+var appConfig = config.AppConfig{ID: 1, Name: "go-rengan"}
+
 var WhenError400EOF string = "when return 400 bad request (error EOF)"
 var WhenError500Service string = "when return 500 internal error (error service)"
 var WhenError500Query string = "when return 500 internal error (error query)"
@@ -31,29 +38,32 @@ var WhenSuccess201Created string = "when return 201 created"
 var WhenSuccess200OK string = "when return 200 ok"
 
 func TestNew(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	validator.New()
 
-	tracing, err := tracing.New()
-	assert.NoError(t, err)
+	tracing := tracing.NewNoop()
 
 	mockservice := new(mockservice.Service)
 	mockservice.On("Create", mock.AnythingOfType("*models.Todo")).Return(&models.Todo{}, nil)
 
-	handler := httpdelivery.New(tracing, mockservice)
+	handler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 	router := chi.NewMux()
 	handler.RegisterRoutes(router)
 }
 
+// newRouter - router of the handler, the requests are made by a principal without scope restriction
+func newRouter(service *mockservice.Service) *chi.Mux {
+	router := chi.NewMux()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "user-1"})))
+		})
+	})
+	httpdelivery.New(tracing.NewNoop(), service, idempotency.NewNoop()).RegisterRoutes(router)
+	return router
+}
+
 // TestGetAll - testing GetAll [200]
 func TestGetAll(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	t.Run(WhenError400Validation, func(t *testing.T) {
 		validator.New()
 
@@ -61,12 +71,11 @@ func TestGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.GetAll)
@@ -87,13 +96,12 @@ func TestGetAll(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
-		mockservice.On("GetAll", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(nil, 1, errorsutil.ErrDefault)
+		mockservice.On("GetAll", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("bool"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(nil, 1, errorsutil.ErrDefault)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.GetAll)
@@ -117,13 +125,12 @@ func TestGetAll(t *testing.T) {
 		mockListTodo := make([]*models.Todo, 0)
 		mockListTodo = append(mockListTodo, &models.Todo{})
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
-		mockservice.On("GetAll", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(mockListTodo, 1, nil)
+		mockservice.On("GetAll", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("bool"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(mockListTodo, 1, nil)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.GetAll)
@@ -140,10 +147,6 @@ func TestGetAll(t *testing.T) {
 
 // TestCreate - testing create [201]
 func TestCreate(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	t.Run(WhenError400EOF, func(t *testing.T) {
 		validator.New()
 
@@ -151,12 +154,11 @@ func TestCreate(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Create)
@@ -180,12 +182,11 @@ func TestCreate(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Create)
@@ -209,13 +210,12 @@ func TestCreate(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("Create", mock.Anything, mock.AnythingOfType("*models.Todo")).Return(nil, errorsutil.ErrDefault)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Create)
@@ -242,13 +242,12 @@ func TestCreate(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("Create", mock.Anything, mock.AnythingOfType("*models.Todo")).Return(&models.Todo{}, nil)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Create)
@@ -265,10 +264,6 @@ func TestCreate(t *testing.T) {
 
 // TestGetByID - testing GetByID [200]
 func TestGetByID(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	t.Run(WhenError404NotFound, func(t *testing.T) {
 		validator.New()
 
@@ -276,13 +271,12 @@ func TestGetByID(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("GetByID", mock.Anything, mock.AnythingOfType("string")).Return(nil, errorsutil.ErrNotFound)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.GetByID)
@@ -302,13 +296,12 @@ func TestGetByID(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("GetByID", mock.Anything, mock.AnythingOfType("string")).Return(nil, errorsutil.ErrDefault)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.GetByID)
@@ -328,13 +321,12 @@ func TestGetByID(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("GetByID", mock.Anything, mock.AnythingOfType("string")).Return(&models.Todo{}, nil)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.GetByID)
@@ -351,10 +343,6 @@ func TestGetByID(t *testing.T) {
 
 // TestUpdate - testing update [200]
 func TestUpdate(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	t.Run(WhenError400EOF, func(t *testing.T) {
 		validator.New()
 
@@ -362,13 +350,12 @@ func TestUpdate(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("Update", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(&models.Todo{}, nil)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Update)
@@ -391,12 +378,11 @@ func TestUpdate(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Update)
@@ -422,13 +408,12 @@ func TestUpdate(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("Update", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(nil, errorsutil.ErrNotFound)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Update)
@@ -454,13 +439,12 @@ func TestUpdate(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("Update", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(nil, errorsutil.ErrDefault)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Update)
@@ -486,13 +470,12 @@ func TestUpdate(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("Update", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(&models.Todo{}, nil)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Update)
@@ -509,10 +492,6 @@ func TestUpdate(t *testing.T) {
 
 // TestDeleteSuccess - testing delete [200]
 func TestDelete(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	t.Run(WhenError404NotFound, func(t *testing.T) {
 		validator.New()
 
@@ -520,13 +499,12 @@ func TestDelete(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("Delete", mock.Anything, mock.AnythingOfType("string")).Return(errorsutil.ErrNotFound)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Delete)
@@ -542,13 +520,12 @@ func TestDelete(t *testing.T) {
 		assert.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("Delete", mock.Anything, mock.AnythingOfType("string")).Return(errorsutil.ErrDefault)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Delete)
@@ -568,13 +545,12 @@ func TestDelete(t *testing.T) {
 
 		req.Header.Set("Content-Type", "application/json")
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockservice := new(mockservice.Service)
 		mockservice.On("Delete", mock.Anything, mock.AnythingOfType("string")).Return(nil)
 
-		todoHandler := httpdelivery.New(tracing, mockservice)
+		todoHandler := httpdelivery.New(tracing, mockservice, idempotency.NewNoop())
 
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(todoHandler.Delete)
@@ -588,3 +564,95 @@ func TestDelete(t *testing.T) {
 		mockservice.AssertExpectations(t)
 	})
 }
+
+// TestSetCollaborator - testing set collaborator [200]
+func TestSetCollaborator(t *testing.T) {
+	t.Run(WhenError400Validation, func(t *testing.T) {
+		validator.New()
+
+		req, err := http.NewRequest(http.MethodPut, "/todo/1/collaborators/user-2", bytes.NewReader([]byte(`{"role": "owner"}`)))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		mockservice := new(mockservice.Service)
+
+		router := newRouter(mockservice)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		// Check the status code is what expected
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "role must be one of viewer editor")
+	})
+	t.Run("when return 403 forbidden (not the owner)", func(t *testing.T) {
+		validator.New()
+
+		req, err := http.NewRequest(http.MethodPut, "/todo/1/collaborators/user-2", bytes.NewReader([]byte(`{"role": "editor"}`)))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		mockservice := new(mockservice.Service)
+		mockservice.On("SetCollaborator", mock.Anything, "1", models.Collaborator{UserID: "user-2", Role: models.RoleEditor}).Return(errorsutil.ErrForbidden)
+
+		router := newRouter(mockservice)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		// Check the status code is what expected
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+	t.Run(WhenSuccess200OK, func(t *testing.T) {
+		validator.New()
+
+		req, err := http.NewRequest(http.MethodPut, "/todo/1/collaborators/user-2", bytes.NewReader([]byte(`{"role": "viewer"}`)))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		mockservice := new(mockservice.Service)
+		mockservice.On("SetCollaborator", mock.Anything, "1", models.Collaborator{UserID: "user-2", Role: models.RoleViewer}).Return(nil)
+
+		router := newRouter(mockservice)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		// Check the status code is what expected
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		// Check if the mock called
+		mockservice.AssertExpectations(t)
+	})
+}
+
+// TestRegisterPublicRoutes - testing the schemas of the todo events [200]
+func TestRegisterPublicRoutes(t *testing.T) {
+	newPublicRouter := func() *chi.Mux {
+		router := chi.NewMux()
+		httpdelivery.New(tracing.NewNoop(), new(mockservice.Service), idempotency.NewNoop()).(httpserver.PublicRoutes).RegisterPublicRoutes(router)
+		return router
+	}
+
+	t.Run(WhenSuccess200OK, func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, schema.URI(events.TodoCreated, events.Version), nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		newPublicRouter().ServeHTTP(rr, req)
+
+		// Check the status code is what expected
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"title": "todo.created"`)
+	})
+	t.Run("when return 404 not found (unknown schema)", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, schema.URI(events.TodoCreated, 99), nil)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		newPublicRouter().ServeHTTP(rr, req)
+
+		// Check the status code is what expected
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"embed"
+	"time"
+
+	"go-rengan/todo/models"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Topic - topic where every todo domain event is published, a topic exchange on RabbitMQ
+const Topic = "todo.events"
+
+// Version - current schema version of the todo events
+const Version = 1
+
+// Schemas - json schema of every todo event, named <type>/v<version>.json
+//
+//go:embed schemas
+var Schemas embed.FS
+
+// Routing keys of the todo domain events
+const (
+	TodoCreated = "todo.created"
+	TodoUpdated = "todo.updated"
+	TodoDeleted = "todo.deleted"
+)
+
+// TodoEvent - todo domain event
+type TodoEvent struct {
+	ID           string            `json:"id"`
+	Type         string            `json:"type"`
+	Version      int               `json:"version"`
+	OccurredAt   time.Time         `json:"occurred_at"`
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	Data         *models.Todo      `json:"data"`
+}
+
+// New - make todo event of the given type, the trace context is taken from ctx
+func New(ctx context.Context, eventType string, value *models.Todo) *TodoEvent {
+	traceContext := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, traceContext)
+
+	return &TodoEvent{
+		ID:           uuid.NewString(),
+		Type:         eventType,
+		Version:      Version,
+		OccurredAt:   time.Now().UTC(),
+		TraceContext: traceContext,
+		Data:         value,
+	}
+}
+
+// Context - put the trace context of the event into ctx
+func (e *TodoEvent) Context(ctx context.Context) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(e.TraceContext))
+}
@@ -0,0 +1,31 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	schema "go-rengan/pkg/schema"
+	"go-rengan/todo/events"
+	"go-rengan/todo/models"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSchemas(t *testing.T) {
+	registry, err := schema.New(events.Schemas)
+	assert.NoError(t, err)
+
+	for _, eventType := range []string{events.TodoCreated, events.TodoUpdated, events.TodoDeleted} {
+		t.Run("success when "+eventType+" matches its schema", func(t *testing.T) {
+			event := events.New(context.Background(), eventType, &models.Todo{ID: primitive.NewObjectID(), Title: "Buy milk"})
+			data, err := json.Marshal(event)
+			assert.NoError(t, err)
+
+			err = registry.Validate(eventType, schema.URI(eventType, events.Version), data)
+
+			assert.NoError(t, err)
+		})
+	}
+}
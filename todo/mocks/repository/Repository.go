@@ -5,6 +5,7 @@ package mocks
 import (
 	context "context"
 	models "go-rengan/todo/models"
+	repository "go-rengan/todo/repository"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -14,20 +15,20 @@ type Repository struct {
 	mock.Mock
 }
 
-// CountFindAll provides a mock function with given fields: ctx, keyword
-func (_m *Repository) CountFindAll(ctx context.Context, keyword string) (int, error) {
-	ret := _m.Called(ctx, keyword)
+// CountFindAll provides a mock function with given fields: ctx, scope, keyword
+func (_m *Repository) CountFindAll(ctx context.Context, scope repository.Scope, keyword string) (int, error) {
+	ret := _m.Called(ctx, scope, keyword)
 
 	var r0 int
-	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
-		r0 = rf(ctx, keyword)
+	if rf, ok := ret.Get(0).(func(context.Context, repository.Scope, string) int); ok {
+		r0 = rf(ctx, scope, keyword)
 	} else {
 		r0 = ret.Get(0).(int)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, keyword)
+	if rf, ok := ret.Get(1).(func(context.Context, repository.Scope, string) error); ok {
+		r1 = rf(ctx, scope, keyword)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -35,20 +36,20 @@ func (_m *Repository) CountFindAll(ctx context.Context, keyword string) (int, er
 	return r0, r1
 }
 
-// CountFindByID provides a mock function with given fields: ctx, id
-func (_m *Repository) CountFindByID(ctx context.Context, id string) (int, error) {
-	ret := _m.Called(ctx, id)
+// CountFindByID provides a mock function with given fields: ctx, scope, id
+func (_m *Repository) CountFindByID(ctx context.Context, scope repository.Scope, id string) (int, error) {
+	ret := _m.Called(ctx, scope, id)
 
 	var r0 int
-	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
-		r0 = rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, repository.Scope, string) int); ok {
+		r0 = rf(ctx, scope, id)
 	} else {
 		r0 = ret.Get(0).(int)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context, repository.Scope, string) error); ok {
+		r1 = rf(ctx, scope, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -56,13 +57,13 @@ func (_m *Repository) CountFindByID(ctx context.Context, id string) (int, error)
 	return r0, r1
 }
 
-// Delete provides a mock function with given fields: ctx, id
-func (_m *Repository) Delete(ctx context.Context, id string) error {
-	ret := _m.Called(ctx, id)
+// Delete provides a mock function with given fields: ctx, scope, id
+func (_m *Repository) Delete(ctx context.Context, scope repository.Scope, id string) error {
+	ret := _m.Called(ctx, scope, id)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
-		r0 = rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, repository.Scope, string) error); ok {
+		r0 = rf(ctx, scope, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -70,13 +71,13 @@ func (_m *Repository) Delete(ctx context.Context, id string) error {
 	return r0
 }
 
-// FindAll provides a mock function with given fields: ctx, keyword, limit, offset
-func (_m *Repository) FindAll(ctx context.Context, keyword string, limit int, offset int) ([]*models.Todo, error) {
-	ret := _m.Called(ctx, keyword, limit, offset)
+// FindAll provides a mock function with given fields: ctx, scope, keyword, limit, offset
+func (_m *Repository) FindAll(ctx context.Context, scope repository.Scope, keyword string, limit int, offset int) ([]*models.Todo, error) {
+	ret := _m.Called(ctx, scope, keyword, limit, offset)
 
 	var r0 []*models.Todo
-	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) []*models.Todo); ok {
-		r0 = rf(ctx, keyword, limit, offset)
+	if rf, ok := ret.Get(0).(func(context.Context, repository.Scope, string, int, int) []*models.Todo); ok {
+		r0 = rf(ctx, scope, keyword, limit, offset)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*models.Todo)
@@ -84,8 +85,8 @@ func (_m *Repository) FindAll(ctx context.Context, keyword string, limit int, of
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, int, int) error); ok {
-		r1 = rf(ctx, keyword, limit, offset)
+	if rf, ok := ret.Get(1).(func(context.Context, repository.Scope, string, int, int) error); ok {
+		r1 = rf(ctx, scope, keyword, limit, offset)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -93,13 +94,13 @@ func (_m *Repository) FindAll(ctx context.Context, keyword string, limit int, of
 	return r0, r1
 }
 
-// FindById provides a mock function with given fields: ctx, id
-func (_m *Repository) FindById(ctx context.Context, id string) (*models.Todo, error) {
-	ret := _m.Called(ctx, id)
+// FindById provides a mock function with given fields: ctx, scope, id
+func (_m *Repository) FindById(ctx context.Context, scope repository.Scope, id string) (*models.Todo, error) {
+	ret := _m.Called(ctx, scope, id)
 
 	var r0 *models.Todo
-	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Todo); ok {
-		r0 = rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, repository.Scope, string) *models.Todo); ok {
+		r0 = rf(ctx, scope, id)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Todo)
@@ -107,8 +108,8 @@ func (_m *Repository) FindById(ctx context.Context, id string) (*models.Todo, er
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context, repository.Scope, string) error); ok {
+		r1 = rf(ctx, scope, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -116,6 +117,34 @@ func (_m *Repository) FindById(ctx context.Context, id string) (*models.Todo, er
 	return r0, r1
 }
 
+// RemoveCollaborator provides a mock function with given fields: ctx, scope, id, userID
+func (_m *Repository) RemoveCollaborator(ctx context.Context, scope repository.Scope, id string, userID string) error {
+	ret := _m.Called(ctx, scope, id, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.Scope, string, string) error); ok {
+		r0 = rf(ctx, scope, id, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetCollaborator provides a mock function with given fields: ctx, scope, id, collaborator
+func (_m *Repository) SetCollaborator(ctx context.Context, scope repository.Scope, id string, collaborator models.Collaborator) error {
+	ret := _m.Called(ctx, scope, id, collaborator)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.Scope, string, models.Collaborator) error); ok {
+		r0 = rf(ctx, scope, id, collaborator)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Store provides a mock function with given fields: ctx, value
 func (_m *Repository) Store(ctx context.Context, value *models.Todo) (*models.Todo, error) {
 	ret := _m.Called(ctx, value)
@@ -139,13 +168,13 @@ func (_m *Repository) Store(ctx context.Context, value *models.Todo) (*models.To
 	return r0, r1
 }
 
-// Update provides a mock function with given fields: ctx, id, value
-func (_m *Repository) Update(ctx context.Context, id string, value *models.Todo) (*models.Todo, error) {
-	ret := _m.Called(ctx, id, value)
+// Update provides a mock function with given fields: ctx, scope, id, value
+func (_m *Repository) Update(ctx context.Context, scope repository.Scope, id string, value *models.Todo) (*models.Todo, error) {
+	ret := _m.Called(ctx, scope, id, value)
 
 	var r0 *models.Todo
-	if rf, ok := ret.Get(0).(func(context.Context, string, *models.Todo) *models.Todo); ok {
-		r0 = rf(ctx, id, value)
+	if rf, ok := ret.Get(0).(func(context.Context, repository.Scope, string, *models.Todo) *models.Todo); ok {
+		r0 = rf(ctx, scope, id, value)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.Todo)
@@ -153,8 +182,8 @@ func (_m *Repository) Update(ctx context.Context, id string, value *models.Todo)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, *models.Todo) error); ok {
-		r1 = rf(ctx, id, value)
+	if rf, ok := ret.Get(1).(func(context.Context, repository.Scope, string, *models.Todo) error); ok {
+		r1 = rf(ctx, scope, id, value)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -14,6 +14,29 @@ type Service struct {
 	mock.Mock
 }
 
+// Collaborators provides a mock function with given fields: ctx, id
+func (_m *Service) Collaborators(ctx context.Context, id string) ([]models.Collaborator, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 []models.Collaborator
+	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Collaborator); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Collaborator)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Create provides a mock function with given fields: ctx, value
 func (_m *Service) Create(ctx context.Context, value *models.Todo) (*models.Todo, error) {
 	ret := _m.Called(ctx, value)
@@ -51,13 +74,13 @@ func (_m *Service) Delete(ctx context.Context, id string) error {
 	return r0
 }
 
-// GetAll provides a mock function with given fields: ctx, keyword, limit, offset
-func (_m *Service) GetAll(ctx context.Context, keyword string, limit int, offset int) ([]*models.Todo, int, error) {
-	ret := _m.Called(ctx, keyword, limit, offset)
+// GetAll provides a mock function with given fields: ctx, keyword, sharedWithMe, limit, offset
+func (_m *Service) GetAll(ctx context.Context, keyword string, sharedWithMe bool, limit int, offset int) ([]*models.Todo, int, error) {
+	ret := _m.Called(ctx, keyword, sharedWithMe, limit, offset)
 
 	var r0 []*models.Todo
-	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) []*models.Todo); ok {
-		r0 = rf(ctx, keyword, limit, offset)
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool, int, int) []*models.Todo); ok {
+		r0 = rf(ctx, keyword, sharedWithMe, limit, offset)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*models.Todo)
@@ -65,15 +88,15 @@ func (_m *Service) GetAll(ctx context.Context, keyword string, limit int, offset
 	}
 
 	var r1 int
-	if rf, ok := ret.Get(1).(func(context.Context, string, int, int) int); ok {
-		r1 = rf(ctx, keyword, limit, offset)
+	if rf, ok := ret.Get(1).(func(context.Context, string, bool, int, int) int); ok {
+		r1 = rf(ctx, keyword, sharedWithMe, limit, offset)
 	} else {
 		r1 = ret.Get(1).(int)
 	}
 
 	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, string, int, int) error); ok {
-		r2 = rf(ctx, keyword, limit, offset)
+	if rf, ok := ret.Get(2).(func(context.Context, string, bool, int, int) error); ok {
+		r2 = rf(ctx, keyword, sharedWithMe, limit, offset)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -104,6 +127,34 @@ func (_m *Service) GetByID(ctx context.Context, id string) (*models.Todo, error)
 	return r0, r1
 }
 
+// RemoveCollaborator provides a mock function with given fields: ctx, id, userID
+func (_m *Service) RemoveCollaborator(ctx context.Context, id string, userID string) error {
+	ret := _m.Called(ctx, id, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetCollaborator provides a mock function with given fields: ctx, id, collaborator
+func (_m *Service) SetCollaborator(ctx context.Context, id string, collaborator models.Collaborator) error {
+	ret := _m.Called(ctx, id, collaborator)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.Collaborator) error); ok {
+		r0 = rf(ctx, id, collaborator)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Update provides a mock function with given fields: ctx, id, value
 func (_m *Service) Update(ctx context.Context, id string, value *models.Todo) (*models.Todo, error) {
 	ret := _m.Called(ctx, id, value)
@@ -11,11 +11,35 @@ import (
 
 // Todo - todo model
 type Todo struct {
-	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Title       string             `json:"title" bson:"title"`
-	Description string             `json:"description" bson:"description"`
-	CreatedAt   time.Time          `json:"created_at" bson:"createdAt"`
-	UpdatedAt   time.Time          `json:"updated_at" bson:"updatedAt"`
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Title         string             `json:"title" bson:"title"`
+	Description   string             `json:"description" bson:"description"`
+	OwnerID       string             `json:"owner_id" bson:"ownerId"`
+	Collaborators []Collaborator     `json:"collaborators,omitempty" bson:"collaborators,omitempty"`
+	CreatedAt     time.Time          `json:"created_at" bson:"createdAt"`
+	UpdatedAt     time.Time          `json:"updated_at" bson:"updatedAt"`
+}
+
+// Roles of the collaborators of a todo
+const (
+	// RoleViewer reads the todo
+	RoleViewer = "viewer"
+	// RoleEditor reads and updates the todo
+	RoleEditor = "editor"
+)
+
+// Scopes of the todo routes, a principal without scopes is allowed both
+const (
+	// ScopeRead lists and reads the todos
+	ScopeRead = "todo:read"
+	// ScopeWrite creates, changes, deletes and shares the todos
+	ScopeWrite = "todo:write"
+)
+
+// Collaborator - user the todo is shared with
+type Collaborator struct {
+	UserID string `json:"user_id" bson:"userId"`
+	Role   string `json:"role" bson:"role"`
 }
 
 // TodoRequest - todo request
@@ -28,14 +52,37 @@ func (request *TodoRequest) Bind(r *http.Request) error {
 	return validator.ValidateStruct(request)
 }
 
+// CollaboratorRequest - role granted to a collaborator
+type CollaboratorRequest struct {
+	Role string `form:"role" json:"role" validate:"required,oneof=viewer editor"`
+}
+
+func (request *CollaboratorRequest) Bind(r *http.Request) error {
+	return validator.ValidateStruct(request)
+}
+
 // TodoListRequest - form for list validation
 type TodoListRequest struct {
-	Keywords *SearchForm
-	Page     string `form:"page" json:"page" validate:"sgte=1"`
-	PerPage  string `form:"per_page" json:"per_page" validate:"sgte=1,slte=100"`
+	Keywords     *SearchForm
+	Page         string `form:"page" json:"page" validate:"sgte=1"`
+	PerPage      string `form:"per_page" json:"per_page" validate:"sgte=1,slte=100"`
+	SharedWithMe string `form:"shared_with_me" json:"shared_with_me" validate:"omitempty,boolean"`
 }
 
 // SearchForm - search list struct
 type SearchForm struct {
 	Keywords string `form:"q" json:"q" validate:"max=255"`
 }
+
+// TodoGetRPCRequest - todo.get rpc request
+type TodoGetRPCRequest struct {
+	ID string `json:"id"`
+}
+
+// TodoListRPCRequest - todo.list rpc request
+type TodoListRPCRequest struct {
+	Keywords     string `json:"q" validate:"max=255"`
+	SharedWithMe bool   `json:"shared_with_me"`
+	Page         int    `json:"page"`
+	PerPage      int    `json:"per_page"`
+}
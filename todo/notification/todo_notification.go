@@ -0,0 +1,133 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	config "go-rengan/pkg/config"
+	"go-rengan/pkg/mailer"
+	tracing "go-rengan/pkg/tracing"
+	"go-rengan/todo/models"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Template names of the todo notifications
+const (
+	TemplateTodoCreated  = "todo_created"
+	TemplateTodoReminder = "todo_reminder"
+)
+
+type Notification interface {
+	TodoCreated(ctx context.Context, to string, value *models.Todo) error
+	TodoReminder(ctx context.Context, to string, value *models.Todo) error
+}
+
+type NotificationImpl struct {
+	appName string
+	tracing tracing.Tracing
+	mailer  mailer.Mailer
+	text    map[string]*texttemplate.Template
+	html    map[string]*htmltemplate.Template
+}
+
+// templateData - data available in the notification templates
+type templateData struct {
+	AppName string
+	Todo    *models.Todo
+}
+
+// New - make todo notification, templates are parsed once
+func New(app config.AppConfig, tracing tracing.Tracing, mailer mailer.Mailer) (Notification, error) {
+	n := &NotificationImpl{
+		appName: app.Name,
+		tracing: tracing,
+		mailer:  mailer,
+		text:    map[string]*texttemplate.Template{},
+		html:    map[string]*htmltemplate.Template{},
+	}
+
+	// Each template defines its own "subject" and "body", so they are parsed separately
+	for _, name := range []string{TemplateTodoCreated, TemplateTodoReminder} {
+		text, err := texttemplate.ParseFS(templates, "templates/"+name+".txt")
+		if err != nil {
+			return nil, err
+		}
+		n.text[name] = text
+
+		html, err := htmltemplate.ParseFS(templates, "templates/"+name+".html")
+		if err != nil {
+			return nil, err
+		}
+		n.html[name] = html
+	}
+
+	return n, nil
+}
+
+// TodoCreated - send todo created email
+func (n *NotificationImpl) TodoCreated(ctx context.Context, to string, value *models.Todo) error {
+	return n.send(ctx, TemplateTodoCreated, to, value)
+}
+
+// TodoReminder - send todo reminder email
+func (n *NotificationImpl) TodoReminder(ctx context.Context, to string, value *models.Todo) error {
+	return n.send(ctx, TemplateTodoReminder, to, value)
+}
+
+func (n *NotificationImpl) send(ctx context.Context, name string, to string, value *models.Todo) error {
+	ctx, span := n.tracing.Tracer("TodoNotification").Start(ctx, "TodoNotification."+name)
+	defer span.End()
+
+	msg, err := n.render(name, to, value)
+	if err != nil {
+		n.tracing.LogError(span, err)
+		return err
+	}
+
+	err = n.mailer.Send(ctx, msg)
+	if err != nil {
+		n.tracing.LogError(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// render - render subject, text and html body of the template
+func (n *NotificationImpl) render(name string, to string, value *models.Todo) (*mailer.Message, error) {
+	data := &templateData{
+		AppName: n.appName,
+		Todo:    value,
+	}
+
+	subject := &bytes.Buffer{}
+	err := n.text[name].ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	textBody := &bytes.Buffer{}
+	err = n.text[name].ExecuteTemplate(textBody, "body", data)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := &bytes.Buffer{}
+	err = n.html[name].ExecuteTemplate(htmlBody, "body", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mailer.Message{
+		To:      []string{to},
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(textBody.String()),
+		HTML:    strings.TrimSpace(htmlBody.String()),
+	}, nil
+}
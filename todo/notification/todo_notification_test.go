@@ -0,0 +1,74 @@
+package notification_test
+
+import (
+	"context"
+	"testing"
+
+	config "go-rengan/pkg/config"
+	"go-rengan/pkg/mailer"
+	tracing "go-rengan/pkg/tracing"
+	"go-rengan/todo/models"
+	"go-rengan/todo/notification"
+	errorsutil "go-rengan/utils/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var appConfig = config.AppConfig{ID: 1, Name: "go-rengan"}
+
+type fakeMailer struct {
+	msg *mailer.Message
+	err error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg *mailer.Message) error {
+	m.msg = msg
+	return m.err
+}
+
+func TestTodoCreated(t *testing.T) {
+	t.Run("success when send", func(t *testing.T) {
+		tracing := tracing.NewNoop()
+
+		m := &fakeMailer{}
+		n, err := notification.New(appConfig, tracing, m)
+		assert.NoError(t, err)
+
+		err = n.TodoCreated(context.Background(), "user@example.com", &models.Todo{
+			Title:       "Milk & <eggs>",
+			Description: "From the store",
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"user@example.com"}, m.msg.To)
+		assert.Equal(t, "New todo: Milk & <eggs>", m.msg.Subject)
+		assert.Contains(t, m.msg.Text, "Milk & <eggs>\nFrom the store")
+		assert.Contains(t, m.msg.HTML, "<strong>Milk &amp; &lt;eggs&gt;</strong>")
+		assert.Contains(t, m.msg.HTML, "Sent by go-rengan")
+	})
+
+	t.Run("error when send", func(t *testing.T) {
+		tracing := tracing.NewNoop()
+
+		n, err := notification.New(appConfig, tracing, &fakeMailer{err: errorsutil.ErrDefault})
+		assert.NoError(t, err)
+
+		err = n.TodoCreated(context.Background(), "user@example.com", &models.Todo{})
+		assert.Error(t, err)
+	})
+}
+
+func TestTodoReminder(t *testing.T) {
+	tracing := tracing.NewNoop()
+
+	m := &fakeMailer{}
+	n, err := notification.New(appConfig, tracing, m)
+	assert.NoError(t, err)
+
+	err = n.TodoReminder(context.Background(), "user@example.com", &models.Todo{Title: "Buy milk"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Reminder: Buy milk", m.msg.Subject)
+	assert.Contains(t, m.msg.Text, "Don't forget your todo")
+	assert.Contains(t, m.msg.HTML, "Don't forget your todo")
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	auth "go-rengan/pkg/auth"
+	"go-rengan/todo/models"
+	"go-rengan/todo/repository"
+)
+
+// Action - what a principal does on a todo
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	// ActionShare - add, change and remove the collaborators
+	ActionShare Action = "share"
+)
+
+// Policy - permissions of the principals on the todos.
+// The owner does every action, an editor reads and updates, a viewer reads, an admin does every action on every todo
+type Policy interface {
+	// Scope - todos the principal may find to do the action on
+	Scope(principal *auth.Principal, action Action) repository.Scope
+	// Can - whether the principal may do the action on the todo
+	Can(principal *auth.Principal, action Action, todo *models.Todo) bool
+}
+
+type PolicyImpl struct{}
+
+// New - make todo policy
+func New() Policy {
+	return &PolicyImpl{}
+}
+
+// roles - roles of the collaborators allowed to do each action, the other actions are for the owner only
+var roles = map[Action][]string{
+	ActionRead:   {models.RoleViewer, models.RoleEditor},
+	ActionUpdate: {models.RoleEditor},
+}
+
+func (p *PolicyImpl) Scope(principal *auth.Principal, action Action) repository.Scope {
+	if principal.HasRole(auth.RoleAdmin) {
+		return repository.Scope{UserID: principal.Subject, Access: repository.Every}
+	}
+	if len(roles[action]) == 0 {
+		return repository.Scope{UserID: principal.Subject, Access: repository.Owned}
+	}
+	return repository.Scope{UserID: principal.Subject, Access: repository.Accessible}
+}
+
+func (p *PolicyImpl) Can(principal *auth.Principal, action Action, todo *models.Todo) bool {
+	if principal.HasRole(auth.RoleAdmin) || todo.OwnerID == principal.Subject {
+		return true
+	}
+
+	for _, collaborator := range todo.Collaborators {
+		if collaborator.UserID != principal.Subject {
+			continue
+		}
+		for _, role := range roles[action] {
+			if collaborator.Role == role {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,63 @@
+package policy_test
+
+import (
+	"testing"
+
+	auth "go-rengan/pkg/auth"
+	"go-rengan/todo/models"
+	"go-rengan/todo/policy"
+	"go-rengan/todo/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCan(t *testing.T) {
+	todo := &models.Todo{
+		OwnerID: "owner",
+		Collaborators: []models.Collaborator{
+			{UserID: "viewer", Role: models.RoleViewer},
+			{UserID: "editor", Role: models.RoleEditor},
+		},
+	}
+
+	allowed := map[string][]policy.Action{
+		"owner":    {policy.ActionRead, policy.ActionUpdate, policy.ActionDelete, policy.ActionShare},
+		"admin":    {policy.ActionRead, policy.ActionUpdate, policy.ActionDelete, policy.ActionShare},
+		"editor":   {policy.ActionRead, policy.ActionUpdate},
+		"viewer":   {policy.ActionRead},
+		"stranger": {},
+	}
+
+	p := policy.New()
+	for subject, actions := range allowed {
+		principal := &auth.Principal{Subject: subject}
+		if subject == "admin" {
+			principal.Roles = []string{auth.RoleAdmin}
+		}
+
+		for _, action := range []policy.Action{policy.ActionRead, policy.ActionUpdate, policy.ActionDelete, policy.ActionShare} {
+			assert.Equal(t, contains(actions, action), p.Can(principal, action, todo), "%s %s", subject, action)
+		}
+	}
+}
+
+func TestScope(t *testing.T) {
+	p := policy.New()
+	user := &auth.Principal{Subject: "user"}
+	admin := &auth.Principal{Subject: "admin", Roles: []string{auth.RoleAdmin}}
+
+	assert.Equal(t, repository.Scope{UserID: "user", Access: repository.Accessible}, p.Scope(user, policy.ActionRead))
+	assert.Equal(t, repository.Scope{UserID: "user", Access: repository.Accessible}, p.Scope(user, policy.ActionUpdate))
+	assert.Equal(t, repository.Scope{UserID: "user", Access: repository.Owned}, p.Scope(user, policy.ActionDelete))
+	assert.Equal(t, repository.Scope{UserID: "user", Access: repository.Owned}, p.Scope(user, policy.ActionShare))
+	assert.Equal(t, repository.Scope{UserID: "admin", Access: repository.Every}, p.Scope(admin, policy.ActionShare))
+}
+
+func contains(actions []policy.Action, action policy.Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
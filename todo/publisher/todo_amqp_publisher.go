@@ -2,47 +2,67 @@ package amqppublisher
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
-	pkgamqp "go-rengan/pkg/amqp"
+	broker "go-rengan/pkg/broker"
+	config "go-rengan/pkg/config"
 	logger "go-rengan/pkg/logger"
+	schema "go-rengan/pkg/schema"
 	tracing "go-rengan/pkg/tracing"
+	"go-rengan/todo/events"
+	"go-rengan/todo/models"
 
-	"github.com/streadway/amqp"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type AMQPPublisher interface {
-	Create(value string)
+	Created(ctx context.Context, value *models.Todo) error
+	Updated(ctx context.Context, value *models.Todo) error
+	Deleted(ctx context.Context, value *models.Todo) error
 }
 
 type AMQPPublisherImpl struct {
-	logger  logger.Logger
-	tracing tracing.Tracing
-	channel pkgamqp.AMQP
+	source    string
+	logger    logger.Logger
+	tracing   tracing.Tracing
+	publisher broker.Publisher
 }
 
 func New(
+	app config.AppConfig,
 	logger logger.Logger,
 	tracing tracing.Tracing,
-	channel pkgamqp.AMQP,
+	publisher broker.Publisher,
 ) AMQPPublisher {
 	return &AMQPPublisherImpl{
-		logger:  logger,
-		tracing: tracing,
-		channel: channel,
+		source:    fmt.Sprintf("/%s/todo", app.Name),
+		logger:    logger,
+		tracing:   tracing,
+		publisher: publisher,
 	}
 }
 
-// Create - publish amqp create
-func (publisherImpl *AMQPPublisherImpl) Create(value string) {
-	ctx := context.Background()
+// Created - publish todo.created event
+func (publisherImpl *AMQPPublisherImpl) Created(ctx context.Context, value *models.Todo) error {
+	return publisherImpl.publish(ctx, events.TodoCreated, value)
+}
+
+// Updated - publish todo.updated event
+func (publisherImpl *AMQPPublisherImpl) Updated(ctx context.Context, value *models.Todo) error {
+	return publisherImpl.publish(ctx, events.TodoUpdated, value)
+}
 
-	messageName := "send_email"
+// Deleted - publish todo.deleted event
+func (publisherImpl *AMQPPublisherImpl) Deleted(ctx context.Context, value *models.Todo) error {
+	return publisherImpl.publish(ctx, events.TodoDeleted, value)
+}
 
+// publish - publish todo event to the todo events topic
+func (publisherImpl *AMQPPublisherImpl) publish(ctx context.Context, routingKey string, value *models.Todo) error {
 	// Create a new span (child of the trace id) to inform the publishing of the message
 	tr := publisherImpl.tracing.Tracer("amqp")
-	spanName := fmt.Sprintf("AMQP - publish - %s", messageName)
+	spanName := fmt.Sprintf("AMQP - publish - %s", routingKey)
 
 	opts := []trace.SpanStartOption{
 		trace.WithSpanKind(trace.SpanKindProducer),
@@ -51,24 +71,29 @@ func (publisherImpl *AMQPPublisherImpl) Create(value string) {
 	ctx, span := tr.Start(ctx, spanName, opts...)
 	defer span.End()
 
-	channel := publisherImpl.channel.Get()
-	q, err := channel.QueueDeclare(messageName, true, false, false, false, nil)
+	event := events.New(ctx, routingKey, value)
+	body, err := json.Marshal(event)
 	if err != nil {
-		publisherImpl.logger.Error(err)
+		publisherImpl.tracing.LogError(span, err)
+		return err
 	}
 
-	// Inject the context in the headers
-	headers := pkgamqp.InjectAMQPHeaders(ctx)
-	body := value
-	msg := amqp.Publishing{
-		Headers:     headers,
-		ContentType: "text/plain",
-		Body:        []byte(body),
-	}
-
-	err = channel.Publish("", q.Name, false, false, msg)
+	// The message id is the key the consumers deduplicate redeliveries on
+	err = publisherImpl.publisher.Publish(ctx, events.Topic, &broker.Message{
+		ID:              event.ID,
+		Source:          publisherImpl.source,
+		Type:            event.Type,
+		DataContentType: "application/json",
+		DataSchema:      schema.URI(event.Type, event.Version),
+		Subject:         value.ID.Hex(),
+		Time:            event.OccurredAt,
+		Data:            body,
+	})
 	if err != nil {
-		publisherImpl.logger.Error(err)
+		publisherImpl.tracing.LogError(span, err)
+		return err
 	}
-	publisherImpl.logger.Println("Publisher send to queue name", messageName)
+	publisherImpl.logger.Debug(ctx, "event published", "topic", events.Topic, "type", routingKey, "message_id", event.ID)
+
+	return nil
 }
@@ -2,12 +2,13 @@ package repository
 
 import (
 	"context"
-	"os"
+	"time"
 
 	mongodb "go-rengan/pkg/mongodb"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"go-rengan/todo/models"
@@ -15,30 +16,84 @@ import (
 	timeutil "go-rengan/utils/time"
 )
 
-// Repository represent the todo repository contract
+// Access - which todos of the user a scope covers
+type Access int
+
+const (
+	// Accessible - the todos the user owns or which are shared with it
+	Accessible Access = iota
+	// Owned - the todos the user owns
+	Owned
+	// Shared - the todos shared with the user
+	Shared
+	// Every - every todo whoever owns it
+	Every
+)
+
+// Scope - todos a query may access
+type Scope struct {
+	UserID string
+	Access Access
+}
+
+// filter - filter restricted to the todos of the scope
+func (s Scope) filter(filter bson.M) bson.M {
+	switch s.Access {
+	case Every:
+		// no restriction
+	case Owned:
+		filter["ownerId"] = s.UserID
+	case Shared:
+		filter["collaborators.userId"] = s.UserID
+	default:
+		filter["$or"] = bson.A{
+			bson.M{"ownerId": s.UserID},
+			bson.M{"collaborators.userId": s.UserID},
+		}
+	}
+	return filter
+}
+
+// Repository represent the todo repository contract, the queries only see the todos of their scope
 type Repository interface {
-	FindAll(ctx context.Context, keyword string, limit int, offset int) ([]*models.Todo, error)
-	CountFindAll(ctx context.Context, keyword string) (int, error)
-	FindById(ctx context.Context, id string) (*models.Todo, error)
-	CountFindByID(ctx context.Context, id string) (int, error)
+	FindAll(ctx context.Context, scope Scope, keyword string, limit int, offset int) ([]*models.Todo, error)
+	CountFindAll(ctx context.Context, scope Scope, keyword string) (int, error)
+	FindById(ctx context.Context, scope Scope, id string) (*models.Todo, error)
+	CountFindByID(ctx context.Context, scope Scope, id string) (int, error)
 	Store(ctx context.Context, value *models.Todo) (*models.Todo, error)
-	Update(ctx context.Context, id string, value *models.Todo) (*models.Todo, error)
-	Delete(ctx context.Context, id string) error
+	Update(ctx context.Context, scope Scope, id string, value *models.Todo) (*models.Todo, error)
+	Delete(ctx context.Context, scope Scope, id string) error
+	SetCollaborator(ctx context.Context, scope Scope, id string, collaborator models.Collaborator) error
+	RemoveCollaborator(ctx context.Context, scope Scope, id string, userID string) error
 }
 
 type RepositoryImpl struct {
-	mongoDB mongodb.MongoDB
+	isolation mongodb.Isolation
 }
 
-// New will create an object that represent the Repository interface
-func New(mongoDB mongodb.MongoDB) Repository {
-	return &RepositoryImpl{
-		mongoDB: mongoDB,
+// New will create an object that represent the Repository interface and ensure the indexes of the owners and collaborators.
+// The todos are the ones of the tenant of ctx, isolated as the config sets
+func New(isolation mongodb.Isolation) (Repository, error) {
+	r := &RepositoryImpl{
+		isolation: isolation,
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := r.isolation.EnsureIndexes(ctx, "todo", []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ownerId", Value: 1}}},
+		{Keys: bson.D{{Key: "collaborators.userId", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
 }
 
 // FindAll - find all todo
-func (r *RepositoryImpl) FindAll(ctx context.Context, keyword string, limit int, offset int) ([]*models.Todo, error) {
+func (r *RepositoryImpl) FindAll(ctx context.Context, scope Scope, keyword string, limit int, offset int) ([]*models.Todo, error) {
 	var results []*models.Todo
 
 	// Pass these options to the Find method
@@ -46,9 +101,11 @@ func (r *RepositoryImpl) FindAll(ctx context.Context, keyword string, limit int,
 	findOptions.SetLimit(int64(limit))
 	findOptions.SetSkip(int64(offset))
 
-	client := r.mongoDB.Get()
-	collection := client.Database(os.Getenv("DB_NAME")).Collection("todo")
-	cur, err := collection.Find(ctx, bson.M{"title": bson.M{"$regex": keyword, "$options": "i"}}, findOptions)
+	collection, err := r.isolation.Collection(ctx, "todo")
+	if err != nil {
+		return []*models.Todo{}, err
+	}
+	cur, err := collection.Find(ctx, collection.Filter(scope.filter(bson.M{"title": bson.M{"$regex": keyword, "$options": "i"}})), findOptions)
 	if err != nil {
 		return []*models.Todo{}, err
 	}
@@ -78,11 +135,13 @@ func (r *RepositoryImpl) FindAll(ctx context.Context, keyword string, limit int,
 }
 
 // CountFindAll - count find all todo
-func (r *RepositoryImpl) CountFindAll(ctx context.Context, keyword string) (int, error) {
-	client := r.mongoDB.Get()
-	collection := client.Database(os.Getenv("DB_NAME")).Collection("todo")
+func (r *RepositoryImpl) CountFindAll(ctx context.Context, scope Scope, keyword string) (int, error) {
+	collection, err := r.isolation.Collection(ctx, "todo")
+	if err != nil {
+		return 0, err
+	}
 
-	total, err := collection.CountDocuments(ctx, bson.M{"title": bson.M{"$regex": keyword, "$options": "i"}})
+	total, err := collection.CountDocuments(ctx, collection.Filter(scope.filter(bson.M{"title": bson.M{"$regex": keyword, "$options": "i"}})))
 	if err != nil {
 		return int(total), err
 	}
@@ -91,17 +150,19 @@ func (r *RepositoryImpl) CountFindAll(ctx context.Context, keyword string) (int,
 }
 
 // FindById - find todo by id
-func (r *RepositoryImpl) FindById(ctx context.Context, id string) (*models.Todo, error) {
+func (r *RepositoryImpl) FindById(ctx context.Context, scope Scope, id string) (*models.Todo, error) {
 	docID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, errorsutil.ErrNotFound
 	}
 
-	client := r.mongoDB.Get()
-	collection := client.Database(os.Getenv("DB_NAME")).Collection("todo")
+	collection, err := r.isolation.Collection(ctx, "todo")
+	if err != nil {
+		return nil, err
+	}
 
 	result := &models.Todo{}
-	err = collection.FindOne(ctx, bson.M{"_id": docID}).Decode(&result)
+	err = collection.FindOne(ctx, collection.Filter(scope.filter(bson.M{"_id": docID}))).Decode(&result)
 	if err != nil {
 		if err.Error() == errorsutil.ErrNoMongoDoc.Error() {
 			return result, errorsutil.ErrNotFound
@@ -114,15 +175,17 @@ func (r *RepositoryImpl) FindById(ctx context.Context, id string) (*models.Todo,
 }
 
 // CountFindByID - find count todo by id
-func (r *RepositoryImpl) CountFindByID(ctx context.Context, id string) (int, error) {
+func (r *RepositoryImpl) CountFindByID(ctx context.Context, scope Scope, id string) (int, error) {
 	docID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return 0, errorsutil.ErrNotFound
 	}
 
-	client := r.mongoDB.Get()
-	collection := client.Database(os.Getenv("DB_NAME")).Collection("todo")
-	total, err := collection.CountDocuments(ctx, bson.M{"_id": docID})
+	collection, err := r.isolation.Collection(ctx, "todo")
+	if err != nil {
+		return 0, err
+	}
+	total, err := collection.CountDocuments(ctx, collection.Filter(scope.filter(bson.M{"_id": docID})))
 	if err != nil {
 		return 0, err
 	}
@@ -136,16 +199,19 @@ func (r *RepositoryImpl) CountFindByID(ctx context.Context, id string) (int, err
 
 // Store - store todo
 func (r *RepositoryImpl) Store(ctx context.Context, value *models.Todo) (*models.Todo, error) {
-	client := r.mongoDB.Get()
-	collection := client.Database(os.Getenv("DB_NAME")).Collection("todo")
+	collection, err := r.isolation.Collection(ctx, "todo")
+	if err != nil {
+		return &models.Todo{}, err
+	}
 
 	timeNow := timeutil.GetTimeNow()
-	res, err := collection.InsertOne(ctx, bson.M{
+	res, err := collection.InsertOne(ctx, collection.Document(bson.M{
 		"title":       value.Title,
 		"description": value.Description,
+		"ownerId":     value.OwnerID,
 		"createdAt":   timeNow,
 		"updatedAt":   timeNow,
-	})
+	}))
 	if err != nil {
 		return &models.Todo{}, err
 	}
@@ -154,6 +220,7 @@ func (r *RepositoryImpl) Store(ctx context.Context, value *models.Todo) (*models
 		ID:          res.InsertedID.(primitive.ObjectID),
 		Title:       value.Title,
 		Description: value.Description,
+		OwnerID:     value.OwnerID,
 		CreatedAt:   timeNow,
 		UpdatedAt:   timeNow,
 	}
@@ -162,14 +229,16 @@ func (r *RepositoryImpl) Store(ctx context.Context, value *models.Todo) (*models
 }
 
 // Update - update todo by id
-func (r *RepositoryImpl) Update(ctx context.Context, id string, value *models.Todo) (*models.Todo, error) {
+func (r *RepositoryImpl) Update(ctx context.Context, scope Scope, id string, value *models.Todo) (*models.Todo, error) {
 	docID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, errorsutil.ErrNotFound
 	}
 
-	client := r.mongoDB.Get()
-	collection := client.Database(os.Getenv("DB_NAME")).Collection("todo")
+	collection, err := r.isolation.Collection(ctx, "todo")
+	if err != nil {
+		return nil, err
+	}
 
 	timeNow := timeutil.GetTimeNow()
 	bsonValue := bson.D{
@@ -177,11 +246,15 @@ func (r *RepositoryImpl) Update(ctx context.Context, id string, value *models.To
 		{Key: "description", Value: value.Description},
 		{Key: "updatedAt", Value: timeNow},
 	}
-	_, err = collection.UpdateOne(ctx, bson.M{"_id": docID}, bson.D{{Key: "$set", Value: bsonValue}})
+	res, err := collection.UpdateOne(ctx, collection.Filter(scope.filter(bson.M{"_id": docID})), bson.D{{Key: "$set", Value: bsonValue}})
 	if err != nil {
 		return nil, err
 	}
 
+	if res.MatchedCount <= 0 {
+		return nil, errorsutil.ErrNotFound
+	}
+
 	result := &models.Todo{
 		ID: docID,
 	}
@@ -190,16 +263,18 @@ func (r *RepositoryImpl) Update(ctx context.Context, id string, value *models.To
 }
 
 // Delete - delete todo by id
-func (r *RepositoryImpl) Delete(ctx context.Context, id string) error {
-	client := r.mongoDB.Get()
-	collection := client.Database(os.Getenv("DB_NAME")).Collection("todo")
+func (r *RepositoryImpl) Delete(ctx context.Context, scope Scope, id string) error {
+	collection, err := r.isolation.Collection(ctx, "todo")
+	if err != nil {
+		return err
+	}
 
 	docID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return errorsutil.ErrNotFound
 	}
 
-	result, err := collection.DeleteOne(ctx, bson.M{"_id": docID})
+	result, err := collection.DeleteOne(ctx, collection.Filter(scope.filter(bson.M{"_id": docID})))
 	if err != nil {
 		return err
 	}
@@ -210,3 +285,73 @@ func (r *RepositoryImpl) Delete(ctx context.Context, id string) error {
 
 	return nil
 }
+
+// SetCollaborator - share todo by id with the user of the collaborator, or change its role when it is shared already.
+// The scope must not match on the collaborators, the role is set on the first collaborator the filter matches
+func (r *RepositoryImpl) SetCollaborator(ctx context.Context, scope Scope, id string, collaborator models.Collaborator) error {
+	docID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errorsutil.ErrNotFound
+	}
+
+	collection, err := r.isolation.Collection(ctx, "todo")
+	if err != nil {
+		return err
+	}
+
+	timeNow := timeutil.GetTimeNow()
+	res, err := collection.UpdateOne(ctx,
+		collection.Filter(scope.filter(bson.M{"_id": docID, "collaborators.userId": collaborator.UserID})),
+		bson.M{"$set": bson.M{"collaborators.$.role": collaborator.Role, "updatedAt": timeNow}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	res, err = collection.UpdateOne(ctx,
+		collection.Filter(scope.filter(bson.M{"_id": docID, "collaborators.userId": bson.M{"$ne": collaborator.UserID}})),
+		bson.M{"$push": bson.M{"collaborators": collaborator}, "$set": bson.M{"updatedAt": timeNow}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount <= 0 {
+		return errorsutil.ErrNotFound
+	}
+
+	return nil
+}
+
+// RemoveCollaborator - stop sharing todo by id with the user
+func (r *RepositoryImpl) RemoveCollaborator(ctx context.Context, scope Scope, id string, userID string) error {
+	docID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errorsutil.ErrNotFound
+	}
+
+	collection, err := r.isolation.Collection(ctx, "todo")
+	if err != nil {
+		return err
+	}
+
+	res, err := collection.UpdateOne(ctx,
+		collection.Filter(scope.filter(bson.M{"_id": docID, "collaborators.userId": userID})),
+		bson.M{
+			"$pull": bson.M{"collaborators": bson.M{"userId": userID}},
+			"$set":  bson.M{"updatedAt": timeutil.GetTimeNow()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount <= 0 {
+		return errorsutil.ErrNotFound
+	}
+
+	return nil
+}
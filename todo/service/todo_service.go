@@ -2,52 +2,104 @@ package service
 
 import (
 	"context"
+	auth "go-rengan/pkg/auth"
+	metrics "go-rengan/pkg/metrics"
 	tracing "go-rengan/pkg/tracing"
 	"go-rengan/todo/models"
+	"go-rengan/todo/policy"
 	amqpservice "go-rengan/todo/publisher"
 	"go-rengan/todo/repository"
+	errorsutil "go-rengan/utils/errors"
+
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
 )
 
-// Service represent the todo service
+// Service represent the todo service, it acts for the principal of ctx as the policy allows.
+// A todo the principal may not read is not found, a todo it may read but not change is forbidden
 type Service interface {
-	GetAll(ctx context.Context, keyword string, limit int, offset int) ([]*models.Todo, int, error)
+	// GetAll - todos the principal may read, only the ones shared with it when sharedWithMe is set
+	GetAll(ctx context.Context, keyword string, sharedWithMe bool, limit int, offset int) ([]*models.Todo, int, error)
 	GetByID(ctx context.Context, id string) (*models.Todo, error)
 	Create(ctx context.Context, value *models.Todo) (*models.Todo, error)
 	Update(ctx context.Context, id string, value *models.Todo) (*models.Todo, error)
 	Delete(ctx context.Context, id string) error
+	Collaborators(ctx context.Context, id string) ([]models.Collaborator, error)
+	SetCollaborator(ctx context.Context, id string, collaborator models.Collaborator) error
+	RemoveCollaborator(ctx context.Context, id string, userID string) error
 }
 
 type ServiceImpl struct {
 	tracing           tracing.Tracing
+	policy            policy.Policy
 	todoRepo          repository.Repository
 	todoAMQPPublisher amqpservice.AMQPPublisher
+	created           syncint64.Counter
+	updated           syncint64.Counter
+	deleted           syncint64.Counter
 }
 
 // New will create new an ServiceImpl object representation of Service interface
 func New(
 	tracing tracing.Tracing,
+	metric metrics.Metrics,
+	policy policy.Policy,
 	todoRepo repository.Repository,
 	todoAMQPPublisher amqpservice.AMQPPublisher,
 ) Service {
+	meter := metric.Meter("go-rengan/todo/service")
+
 	return &ServiceImpl{
 		tracing:           tracing,
+		policy:            policy,
 		todoRepo:          todoRepo,
 		todoAMQPPublisher: todoAMQPPublisher,
+		created:           metrics.Counter(meter, "todo.created", "Todos created"),
+		updated:           metrics.Counter(meter, "todo.updated", "Todos updated"),
+		deleted:           metrics.Counter(meter, "todo.deleted", "Todos deleted"),
 	}
 }
 
+// authorize - todo by id when the principal of ctx may do the action on it
+func (s *ServiceImpl) authorize(ctx context.Context, id string, action policy.Action) (*auth.Principal, *models.Todo, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, nil, auth.ErrUnauthenticated
+	}
+
+	res, err := s.todoRepo.FindById(ctx, s.policy.Scope(principal, policy.ActionRead), id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !s.policy.Can(principal, action, res) {
+		return nil, nil, errorsutil.ErrForbidden
+	}
+
+	return principal, res, nil
+}
+
 // GetAll - get all todo service
-func (s *ServiceImpl) GetAll(ctx context.Context, keyword string, limit int, offset int) ([]*models.Todo, int, error) {
+func (s *ServiceImpl) GetAll(ctx context.Context, keyword string, sharedWithMe bool, limit int, offset int) ([]*models.Todo, int, error) {
 	ctx, span := s.tracing.Tracer("TodoService").Start(ctx, "TodoService.GetAll")
 	defer span.End()
 
-	res, err := s.todoRepo.FindAll(ctx, keyword, limit, offset)
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, 0, auth.ErrUnauthenticated
+	}
+
+	scope := s.policy.Scope(principal, policy.ActionRead)
+	if sharedWithMe {
+		scope = repository.Scope{UserID: principal.Subject, Access: repository.Shared}
+	}
+
+	res, err := s.todoRepo.FindAll(ctx, scope, keyword, limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Count total
-	total, err := s.todoRepo.CountFindAll(ctx, keyword)
+	total, err := s.todoRepo.CountFindAll(ctx, scope, keyword)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -60,7 +112,7 @@ func (s *ServiceImpl) GetByID(ctx context.Context, id string) (*models.Todo, err
 	ctx, span := s.tracing.Tracer("TodoService").Start(ctx, "TodoService.GetByID")
 	defer span.End()
 
-	res, err := s.todoRepo.FindById(ctx, id)
+	_, res, err := s.authorize(ctx, id, policy.ActionRead)
 	if err != nil {
 		return nil, err
 	}
@@ -73,16 +125,26 @@ func (s *ServiceImpl) Create(ctx context.Context, value *models.Todo) (*models.T
 	ctx, span := s.tracing.Tracer("TodoService").Start(ctx, "TodoService.Create")
 	defer span.End()
 
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+
 	res, err := s.todoRepo.Store(ctx, &models.Todo{
 		Title:       value.Title,
 		Description: value.Description,
+		OwnerID:     principal.Subject,
 	})
 	if err != nil {
 		return nil, err
 	}
+	s.created.Add(ctx, 1)
 
-	// Send Email Queue
-	s.todoAMQPPublisher.Create("example.com")
+	// Publish todo.created event
+	err = s.todoAMQPPublisher.Created(ctx, res)
+	if err != nil {
+		s.tracing.LogError(span, err)
+	}
 
 	return res, nil
 }
@@ -92,18 +154,32 @@ func (s *ServiceImpl) Update(ctx context.Context, id string, value *models.Todo)
 	ctx, span := s.tracing.Tracer("TodoService").Start(ctx, "TodoService.Update")
 	defer span.End()
 
-	_, err := s.todoRepo.CountFindByID(ctx, id)
+	principal, _, err := s.authorize(ctx, id, policy.ActionUpdate)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = s.todoRepo.Update(ctx, id, &models.Todo{
+	scope := s.policy.Scope(principal, policy.ActionUpdate)
+	_, err = s.todoRepo.Update(ctx, scope, id, &models.Todo{
 		Title:       value.Title,
 		Description: value.Description,
 	})
 	if err != nil {
 		return nil, err
 	}
+	s.updated.Add(ctx, 1)
+
+	// Publish todo.updated event with the stored values
+	res, err := s.todoRepo.FindById(ctx, scope, id)
+	if err != nil {
+		s.tracing.LogError(span, err)
+		return nil, nil
+	}
+
+	err = s.todoAMQPPublisher.Updated(ctx, res)
+	if err != nil {
+		s.tracing.LogError(span, err)
+	}
 
 	return nil, nil
 }
@@ -113,10 +189,65 @@ func (s *ServiceImpl) Delete(ctx context.Context, id string) error {
 	ctx, span := s.tracing.Tracer("TodoService").Start(ctx, "TodoService.Delete")
 	defer span.End()
 
-	err := s.todoRepo.Delete(ctx, id)
+	principal, res, err := s.authorize(ctx, id, policy.ActionDelete)
+	if err != nil {
+		return err
+	}
+
+	err = s.todoRepo.Delete(ctx, s.policy.Scope(principal, policy.ActionDelete), id)
 	if err != nil {
 		return err
 	}
+	s.deleted.Add(ctx, 1)
+
+	// Publish todo.deleted event
+	err = s.todoAMQPPublisher.Deleted(ctx, res)
+	if err != nil {
+		s.tracing.LogError(span, err)
+	}
 
 	return nil
 }
+
+// Collaborators - collaborators of the todo, for the principals who may read it
+func (s *ServiceImpl) Collaborators(ctx context.Context, id string) ([]models.Collaborator, error) {
+	ctx, span := s.tracing.Tracer("TodoService").Start(ctx, "TodoService.Collaborators")
+	defer span.End()
+
+	_, res, err := s.authorize(ctx, id, policy.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Collaborators == nil {
+		return []models.Collaborator{}, nil
+	}
+
+	return res.Collaborators, nil
+}
+
+// SetCollaborator - share the todo with the user of the collaborator, or change its role
+func (s *ServiceImpl) SetCollaborator(ctx context.Context, id string, collaborator models.Collaborator) error {
+	ctx, span := s.tracing.Tracer("TodoService").Start(ctx, "TodoService.SetCollaborator")
+	defer span.End()
+
+	principal, _, err := s.authorize(ctx, id, policy.ActionShare)
+	if err != nil {
+		return err
+	}
+
+	return s.todoRepo.SetCollaborator(ctx, s.policy.Scope(principal, policy.ActionShare), id, collaborator)
+}
+
+// RemoveCollaborator - stop sharing the todo with the user
+func (s *ServiceImpl) RemoveCollaborator(ctx context.Context, id string, userID string) error {
+	ctx, span := s.tracing.Tracer("TodoService").Start(ctx, "TodoService.RemoveCollaborator")
+	defer span.End()
+
+	principal, _, err := s.authorize(ctx, id, policy.ActionShare)
+	if err != nil {
+		return err
+	}
+
+	return s.todoRepo.RemoveCollaborator(ctx, s.policy.Scope(principal, policy.ActionShare), id, userID)
+}
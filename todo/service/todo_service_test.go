@@ -2,42 +2,49 @@ package service_test
 
 import (
 	"context"
+	auth "go-rengan/pkg/auth"
+	config "go-rengan/pkg/config"
+	metrics "go-rengan/pkg/metrics"
 	tracing "go-rengan/pkg/tracing"
 	mockpublisher "go-rengan/todo/mocks/publisher"
 	mockrepository "go-rengan/todo/mocks/repository"
 	"go-rengan/todo/models"
+	"go-rengan/todo/policy"
+	"go-rengan/todo/repository"
 	"go-rengan/todo/service"
 	errorsutil "go-rengan/utils/errors"
-	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	metric_sdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
+var appConfig = config.AppConfig{ID: 1, Name: "go-rengan"}
+
 var DefaultID string = "1"
 
-func TestGetAll(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
+var userCtx = auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "user-1"})
+var userScope = repository.Scope{UserID: "user-1", Access: repository.Accessible}
+var ownedScope = repository.Scope{UserID: "user-1", Access: repository.Owned}
 
+func TestGetAll(t *testing.T) {
 	t.Run("success when find all", func(t *testing.T) {
 		mockList := make([]*models.Todo, 0)
 		mockList = append(mockList, &models.Todo{})
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("FindAll", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(mockList, nil)
-		mockRepository.On("CountFindAll", mock.Anything, mock.AnythingOfType("string")).Return(10, nil)
+		mockRepository.On("FindAll", mock.Anything, userScope, mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(mockList, nil)
+		mockRepository.On("CountFindAll", mock.Anything, userScope, mock.AnythingOfType("string")).Return(10, nil)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		results, count, err := service.GetAll(context.Background(), "keyword", 10, 0)
+		results, count, err := service.GetAll(userCtx, "keyword", false, 10, 0)
 
 		assert.NoError(t, err)
 		assert.Equal(t, count, 10)
@@ -45,18 +52,17 @@ func TestGetAll(t *testing.T) {
 	})
 
 	t.Run("error when find all", func(t *testing.T) {
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("FindAll", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(nil, errorsutil.ErrDefault)
-		mockRepository.On("CountFindAll", mock.Anything, mock.AnythingOfType("string")).Return(10, nil)
+		mockRepository.On("FindAll", mock.Anything, userScope, mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(nil, errorsutil.ErrDefault)
+		mockRepository.On("CountFindAll", mock.Anything, userScope, mock.AnythingOfType("string")).Return(10, nil)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		results, count, err := service.GetAll(context.Background(), "keyword", 10, 0)
+		results, count, err := service.GetAll(userCtx, "keyword", false, 10, 0)
 
 		assert.Nil(t, results)
 		assert.Equal(t, 0, count)
@@ -64,18 +70,17 @@ func TestGetAll(t *testing.T) {
 	})
 
 	t.Run("error when count find all", func(t *testing.T) {
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("FindAll", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(nil, nil)
-		mockRepository.On("CountFindAll", mock.Anything, mock.AnythingOfType("string")).Return(10, errorsutil.ErrDefault)
+		mockRepository.On("FindAll", mock.Anything, userScope, mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(nil, nil)
+		mockRepository.On("CountFindAll", mock.Anything, userScope, mock.AnythingOfType("string")).Return(10, errorsutil.ErrDefault)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		results, count, err := service.GetAll(context.Background(), "keyword", 10, 0)
+		results, count, err := service.GetAll(userCtx, "keyword", false, 10, 0)
 
 		assert.Nil(t, results)
 		assert.Equal(t, 0, count)
@@ -84,84 +89,134 @@ func TestGetAll(t *testing.T) {
 }
 
 func TestGetByID(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	t.Run("success when find by id", func(t *testing.T) {
-		var mockTodo = &models.Todo{}
+		var mockTodo = &models.Todo{OwnerID: "user-1"}
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("FindById", mock.Anything, mock.AnythingOfType("string")).Return(mockTodo, nil)
+		mockRepository.On("FindById", mock.Anything, userScope, mock.AnythingOfType("string")).Return(mockTodo, nil)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		result, err := service.GetByID(context.Background(), DefaultID)
+		result, err := service.GetByID(userCtx, DefaultID)
 
 		assert.NoError(t, err)
 		assert.Equal(t, mockTodo, result)
 	})
 
 	t.Run("error when find by id", func(t *testing.T) {
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("FindById", mock.Anything, mock.AnythingOfType("string")).Return(nil, errorsutil.ErrDefault)
+		mockRepository.On("FindById", mock.Anything, userScope, mock.AnythingOfType("string")).Return(nil, errorsutil.ErrDefault)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		result, err := service.GetByID(context.Background(), DefaultID)
+		result, err := service.GetByID(userCtx, DefaultID)
 
 		assert.Nil(t, result)
 		assert.Error(t, err)
 	})
+
+	t.Run("success when admin finds todo of any owner", func(t *testing.T) {
+		var mockTodo = &models.Todo{OwnerID: "user-2"}
+
+		tracing := tracing.NewNoop()
+
+		mockRepository := new(mockrepository.Repository)
+		mockRepository.On("FindById", mock.Anything, repository.Scope{UserID: "admin-1", Access: repository.Every}, DefaultID).Return(mockTodo, nil)
+
+		mockPublisher := new(mockpublisher.AMQPPublisher)
+
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
+
+		ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "admin-1", Roles: []string{auth.RoleAdmin}})
+		result, err := service.GetByID(ctx, DefaultID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, mockTodo, result)
+	})
+
+	t.Run("error when unauthenticated", func(t *testing.T) {
+		tracing := tracing.NewNoop()
+
+		mockRepository := new(mockrepository.Repository)
+		mockPublisher := new(mockpublisher.AMQPPublisher)
+
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
+
+		result, err := service.GetByID(context.Background(), DefaultID)
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, auth.ErrUnauthenticated)
+		mockRepository.AssertNotCalled(t, "FindById", mock.Anything, mock.Anything, mock.Anything)
+	})
 }
 
 func TestCreate(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	t.Run("success when create", func(t *testing.T) {
-		var mockTodo = &models.Todo{}
+		var mockTodo = &models.Todo{OwnerID: "user-1"}
+
+		tracing := tracing.NewNoop()
+
+		mockRepository := new(mockrepository.Repository)
+		mockRepository.On("Store", mock.Anything, mock.MatchedBy(func(todo *models.Todo) bool {
+			return todo.OwnerID == "user-1"
+		})).Return(mockTodo, nil)
+
+		mockPublisher := new(mockpublisher.AMQPPublisher)
+		mockPublisher.On("Created", mock.Anything, mockTodo).Return(nil)
+
+		reader := metric_sdk.NewManualReader()
+		service := service.New(tracing, metrics.NewWithReader(reader), policy.New(), mockRepository, mockPublisher)
+
+		result, err := service.Create(userCtx, &models.Todo{})
 
-		tracing, err := tracing.New()
 		assert.NoError(t, err)
+		assert.Equal(t, mockTodo, result)
+		mockPublisher.AssertExpectations(t)
+
+		rm, err := reader.Collect(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "todo.created", rm.ScopeMetrics[0].Metrics[0].Name)
+		assert.Equal(t, int64(1), rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+	})
+
+	t.Run("success when publish failed", func(t *testing.T) {
+		var mockTodo = &models.Todo{OwnerID: "user-1"}
+
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
 		mockRepository.On("Store", mock.Anything, mock.AnythingOfType("*models.Todo")).Return(mockTodo, nil)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
-		mockPublisher.On("Create", mock.AnythingOfType("string"))
+		mockPublisher.On("Created", mock.Anything, mockTodo).Return(errorsutil.ErrDefault)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		result, err := service.Create(context.Background(), &models.Todo{})
+		result, err := service.Create(userCtx, &models.Todo{})
 
 		assert.NoError(t, err)
 		assert.Equal(t, mockTodo, result)
 	})
 
 	t.Run("error when create", func(t *testing.T) {
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
 		mockRepository.On("Store", mock.Anything, mock.AnythingOfType("*models.Todo")).Return(nil, errorsutil.ErrDefault)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		result, err := service.Create(context.Background(), &models.Todo{})
+		result, err := service.Create(userCtx, &models.Todo{})
 
 		assert.Nil(t, result)
 		assert.Error(t, err)
@@ -169,61 +224,56 @@ func TestCreate(t *testing.T) {
 }
 
 func TestUpdate(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	t.Run("success when update", func(t *testing.T) {
-		var mockTodo = &models.Todo{}
+		var mockTodo = &models.Todo{OwnerID: "user-1"}
 
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("CountFindByID", mock.Anything, mock.AnythingOfType("string")).Return(10, nil)
-		mockRepository.On("Update", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(mockTodo, nil)
+		mockRepository.On("FindById", mock.Anything, userScope, mock.AnythingOfType("string")).Return(mockTodo, nil)
+		mockRepository.On("Update", mock.Anything, userScope, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(mockTodo, nil)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
+		mockPublisher.On("Updated", mock.Anything, mockTodo).Return(nil)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		result, err := service.Update(context.Background(), DefaultID, &models.Todo{})
+		result, err := service.Update(userCtx, DefaultID, &models.Todo{})
 
 		assert.NoError(t, err)
 		assert.Nil(t, result)
+		mockPublisher.AssertExpectations(t)
 	})
 
-	t.Run("error when count find by id", func(t *testing.T) {
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+	t.Run("error when find by id", func(t *testing.T) {
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("CountFindByID", mock.Anything, mock.AnythingOfType("string")).Return(0, errorsutil.ErrDefault)
-		mockRepository.On("Update", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(nil, nil)
+		mockRepository.On("FindById", mock.Anything, userScope, mock.AnythingOfType("string")).Return(nil, errorsutil.ErrDefault)
+		mockRepository.On("Update", mock.Anything, userScope, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(nil, nil)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		result, err := service.Update(context.Background(), DefaultID, &models.Todo{})
+		result, err := service.Update(userCtx, DefaultID, &models.Todo{})
 
 		assert.Nil(t, result)
 		assert.Error(t, err)
 	})
 
 	t.Run("error when update", func(t *testing.T) {
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("CountFindByID", mock.Anything, mock.AnythingOfType("string")).Return(10, nil)
-		mockRepository.On("Update", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(nil, errorsutil.ErrDefault)
+		mockRepository.On("FindById", mock.Anything, userScope, mock.AnythingOfType("string")).Return(&models.Todo{OwnerID: "user-1"}, nil)
+		mockRepository.On("Update", mock.Anything, userScope, mock.AnythingOfType("string"), mock.AnythingOfType("*models.Todo")).Return(nil, errorsutil.ErrDefault)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		result, err := service.Update(context.Background(), DefaultID, &models.Todo{})
+		result, err := service.Update(userCtx, DefaultID, &models.Todo{})
 
 		assert.Nil(t, result)
 		assert.Error(t, err)
@@ -231,39 +281,130 @@ func TestUpdate(t *testing.T) {
 }
 
 func TestDelete(t *testing.T) {
-	os.Setenv("APP_ID", "1")
-	os.Setenv("APP_NAME", "go-rengan")
-	os.Setenv("TRACER_PROVIDER_URL", "http://project2_secret_token@localhost:14317/2")
-
 	t.Run("success when delete", func(t *testing.T) {
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
+
+		var mockTodo = &models.Todo{OwnerID: "user-1"}
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("Delete", mock.Anything, mock.AnythingOfType("string")).Return(nil)
+		mockRepository.On("FindById", mock.Anything, userScope, mock.AnythingOfType("string")).Return(mockTodo, nil)
+		mockRepository.On("Delete", mock.Anything, ownedScope, mock.AnythingOfType("string")).Return(nil)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
+		mockPublisher.On("Deleted", mock.Anything, mockTodo).Return(nil)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		err = service.Delete(context.Background(), DefaultID)
+		err := service.Delete(userCtx, DefaultID)
 
 		assert.NoError(t, err)
+		mockPublisher.AssertExpectations(t)
+	})
+
+	t.Run("error when find by id", func(t *testing.T) {
+		tracing := tracing.NewNoop()
+
+		mockRepository := new(mockrepository.Repository)
+		mockRepository.On("FindById", mock.Anything, userScope, mock.AnythingOfType("string")).Return(nil, errorsutil.ErrNotFound)
+
+		mockPublisher := new(mockpublisher.AMQPPublisher)
+
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
+
+		err := service.Delete(userCtx, DefaultID)
+
+		assert.Error(t, err)
+		mockRepository.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
 	})
 
 	t.Run("error when delete", func(t *testing.T) {
-		tracing, err := tracing.New()
-		assert.NoError(t, err)
+		tracing := tracing.NewNoop()
 
 		mockRepository := new(mockrepository.Repository)
-		mockRepository.On("Delete", mock.Anything, mock.AnythingOfType("string")).Return(errorsutil.ErrDefault)
+		mockRepository.On("FindById", mock.Anything, userScope, mock.AnythingOfType("string")).Return(&models.Todo{OwnerID: "user-1"}, nil)
+		mockRepository.On("Delete", mock.Anything, ownedScope, mock.AnythingOfType("string")).Return(errorsutil.ErrDefault)
 
 		mockPublisher := new(mockpublisher.AMQPPublisher)
 
-		service := service.New(tracing, mockRepository, mockPublisher)
+		service := service.New(tracing, metrics.NewNoop(), policy.New(), mockRepository, mockPublisher)
 
-		err = service.Delete(context.Background(), DefaultID)
+		err := service.Delete(userCtx, DefaultID)
 
 		assert.Error(t, err)
 	})
 }
+
+func TestSharing(t *testing.T) {
+	sharedTodo := &models.Todo{
+		OwnerID: "user-2",
+		Collaborators: []models.Collaborator{
+			{UserID: "user-1", Role: models.RoleViewer},
+		},
+	}
+
+	t.Run("success when get all shared with me", func(t *testing.T) {
+		mockRepository := new(mockrepository.Repository)
+		sharedScope := repository.Scope{UserID: "user-1", Access: repository.Shared}
+		mockRepository.On("FindAll", mock.Anything, sharedScope, "", 10, 0).Return([]*models.Todo{sharedTodo}, nil)
+		mockRepository.On("CountFindAll", mock.Anything, sharedScope, "").Return(1, nil)
+
+		service := service.New(tracing.NewNoop(), metrics.NewNoop(), policy.New(), mockRepository, new(mockpublisher.AMQPPublisher))
+
+		results, count, err := service.GetAll(userCtx, "", true, 10, 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []*models.Todo{sharedTodo}, results)
+	})
+
+	t.Run("error when viewer updates", func(t *testing.T) {
+		mockRepository := new(mockrepository.Repository)
+		mockRepository.On("FindById", mock.Anything, userScope, DefaultID).Return(sharedTodo, nil)
+
+		service := service.New(tracing.NewNoop(), metrics.NewNoop(), policy.New(), mockRepository, new(mockpublisher.AMQPPublisher))
+
+		_, err := service.Update(userCtx, DefaultID, &models.Todo{})
+
+		assert.ErrorIs(t, err, errorsutil.ErrForbidden)
+		mockRepository.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("success when viewer lists collaborators", func(t *testing.T) {
+		mockRepository := new(mockrepository.Repository)
+		mockRepository.On("FindById", mock.Anything, userScope, DefaultID).Return(sharedTodo, nil)
+
+		service := service.New(tracing.NewNoop(), metrics.NewNoop(), policy.New(), mockRepository, new(mockpublisher.AMQPPublisher))
+
+		results, err := service.Collaborators(userCtx, DefaultID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, sharedTodo.Collaborators, results)
+	})
+
+	t.Run("success when owner shares", func(t *testing.T) {
+		collaborator := models.Collaborator{UserID: "user-2", Role: models.RoleEditor}
+
+		mockRepository := new(mockrepository.Repository)
+		mockRepository.On("FindById", mock.Anything, userScope, DefaultID).Return(&models.Todo{OwnerID: "user-1"}, nil)
+		mockRepository.On("SetCollaborator", mock.Anything, ownedScope, DefaultID, collaborator).Return(nil)
+
+		service := service.New(tracing.NewNoop(), metrics.NewNoop(), policy.New(), mockRepository, new(mockpublisher.AMQPPublisher))
+
+		err := service.SetCollaborator(userCtx, DefaultID, collaborator)
+
+		assert.NoError(t, err)
+		mockRepository.AssertExpectations(t)
+	})
+
+	t.Run("error when collaborator shares", func(t *testing.T) {
+		mockRepository := new(mockrepository.Repository)
+		mockRepository.On("FindById", mock.Anything, userScope, DefaultID).Return(sharedTodo, nil)
+
+		service := service.New(tracing.NewNoop(), metrics.NewNoop(), policy.New(), mockRepository, new(mockpublisher.AMQPPublisher))
+
+		err := service.RemoveCollaborator(userCtx, DefaultID, "user-3")
+
+		assert.ErrorIs(t, err, errorsutil.ErrForbidden)
+		mockRepository.AssertNotCalled(t, "RemoveCollaborator", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
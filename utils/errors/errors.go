@@ -6,3 +6,4 @@ var ErrDefault = errors.New("error")
 var ErrEOF = errors.New("EOF")
 var ErrNotFound = errors.New("not found")
 var ErrNoMongoDoc = errors.New("mongo: no documents in result")
+var ErrForbidden = errors.New("forbidden")
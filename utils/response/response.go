@@ -1,11 +1,11 @@
 package response
 
 import (
+	logger "go-rengan/pkg/logger"
 	validator "go-rengan/pkg/validator"
 	"net/http"
 
 	"github.com/go-chi/render"
-	"github.com/sirupsen/logrus"
 )
 
 // H is a shortcut for map[string]interface{}
@@ -51,7 +51,7 @@ func ErrorBody(w http.ResponseWriter, r *http.Request, err error) {
 
 // ErrorInternal - when error internal server
 func ErrorInternal(w http.ResponseWriter, r *http.Request, err error) {
-	logrus.Error(err)
+	logger.Default().Error(r.Context(), "internal server error", "method", r.Method, "path", r.URL.Path, "error", err)
 
 	render.Status(r, http.StatusInternalServerError)
 	render.JSON(w, r, H{
@@ -61,6 +61,16 @@ func ErrorInternal(w http.ResponseWriter, r *http.Request, err error) {
 	})
 }
 
+// BadRequest - when the request is malformed, outside of the body validation
+func BadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	render.Status(r, http.StatusBadRequest)
+	render.JSON(w, r, H{
+		"success": false,
+		"code":    http.StatusBadRequest,
+		"message": message,
+	})
+}
+
 // NotFound - when request not found
 func NotFound(w http.ResponseWriter, r *http.Request, message string) {
 	render.Status(r, http.StatusNotFound)
@@ -71,6 +81,67 @@ func NotFound(w http.ResponseWriter, r *http.Request, message string) {
 	})
 }
 
+// Unauthorized - when the request has no valid credential
+func Unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	render.Status(r, http.StatusUnauthorized)
+	render.JSON(w, r, H{
+		"success": false,
+		"code":    http.StatusUnauthorized,
+		"message": message,
+	})
+}
+
+// Forbidden - when the principal may not do the request
+func Forbidden(w http.ResponseWriter, r *http.Request, message string) {
+	render.Status(r, http.StatusForbidden)
+	render.JSON(w, r, H{
+		"success": false,
+		"code":    http.StatusForbidden,
+		"message": message,
+	})
+}
+
+// Conflict - when the request conflicts with another one
+func Conflict(w http.ResponseWriter, r *http.Request, message string) {
+	render.Status(r, http.StatusConflict)
+	render.JSON(w, r, H{
+		"success": false,
+		"code":    http.StatusConflict,
+		"message": message,
+	})
+}
+
+// UnprocessableEntity - when the request is well formed but cannot be processed
+func UnprocessableEntity(w http.ResponseWriter, r *http.Request, message string) {
+	render.Status(r, http.StatusUnprocessableEntity)
+	render.JSON(w, r, H{
+		"success": false,
+		"code":    http.StatusUnprocessableEntity,
+		"message": message,
+	})
+}
+
+// TooManyRequests - when the client sent more requests than its rate limit
+func TooManyRequests(w http.ResponseWriter, r *http.Request, message string) {
+	render.Status(r, http.StatusTooManyRequests)
+	render.JSON(w, r, H{
+		"success": false,
+		"code":    http.StatusTooManyRequests,
+		"message": message,
+	})
+}
+
+// ServiceUnavailable - when a dependency of the request is down
+func ServiceUnavailable(w http.ResponseWriter, r *http.Request, data *Success) {
+	render.Status(r, http.StatusServiceUnavailable)
+	render.JSON(w, r, H{
+		"success": false,
+		"code":    http.StatusServiceUnavailable,
+		"message": "Service unavailable",
+		"data":    data.Data,
+	})
+}
+
 // Created - when success created
 func Created(w http.ResponseWriter, r *http.Request, data *Success) {
 	render.Status(r, http.StatusCreated)